@@ -1,278 +1,434 @@
-// main.go 
-package main
-
-import (
-	"database/sql"
-	_ "github.com/go-sql-driver/mysql"
-	"encoding/json"
-	"fmt"
-	"log"
-	"time"
-
-	"crypto/sha256"
-	"encoding/hex"
-
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
-)
-
-func (mc *MyContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]byte, error) {
-    // Chamar a função QueryBanco para obter os dados
-    data, err := mc.QueryBanco(ctx)
-    if err != nil {
-        return nil, err
-    }
-
-    // Retornar os dados obtidos da consulta
-    return data, nil
-}
-
-
-// TripData struct para representar os dados de uma viagem
-type TripData struct {
-	DepartureDatetime string  `json:"Departure_Datetime"`
-	TotalDistanceKm   float64 `json:"totalDistance_km"`
-	TripID            int     `json:"TripID"`
-	ArrivalDatetime   string  `json:"Arrival_Datetime"`
-}
-
-// Transaction representa uma transação no livro-razão
-type Transaction struct {
-	Timestamp time.Time `json:"timestamp"`
-	Data      string    `json:"data"`
-}
-
-// Block representa um bloco contendo várias transações
-type Block struct {
-	Transactions []Transaction `json:"transactions"`
-}
-
-// Blockchain representa uma sequência de blocos
-type Blockchain struct {
-	Blocks []Block `json:"blocks"`
-}
-
-// Bloco atual
-var currentBlock *Block
-
-// Último carimbo de data/hora de transação
-var lastTransactionTimestamp time.Time
-
-// Máximo de transações por bloco
-const maxTransactionsPerBlock = 10
-
-// Limite de tempo do bloco (10 minutos)
-const blockTimeLimit = 10 * time.Minute
-
-// MyContract define o chaincode para consulta de dados do MySQL e transações
-type MyContract struct {
-	contractapi.Contract
-}
-
-func (mc *MyContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-    // Pode adicionar lógica de inicialização do ledger aqui, se necessário
-    fmt.Println("Inicializando o ledger")
-    return nil
-}
-
-func (mc *MyContract) RegisterDataOnBlockchain(ctx contractapi.TransactionContextInterface, data []byte) error {
-    // Gere um ID único para a transação
-    txID := ctx.GetStub().GetTxID()
-
-    // Registre os dados na blockchain usando o ID da transação como chave
-    err := ctx.GetStub().PutState(txID, data)
-    if err != nil {
-        return fmt.Errorf("falha ao registrar os dados na blockchain: %v", err)
-    }
-
-    return nil
-}
-
-// QueryBanco function to query data from MySQL and add transactions to the ledger
-func (mc *MyContract) QueryBanco(ctx contractapi.TransactionContextInterface) ([]byte, error) {
-	// Conexão com o MySQL
-	db, err := sql.Open("mysql", "root:movepass@tcp(localhost:3306)/moveuff")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
-	// Query SQL
-	queryToday := `
-	SELECT 
-		departure.id AS Departure_Datetime, 
-		trips.totalDistance_km, 
-		trips.id AS TripID, 
-		arrival.id AS Arrival_Datetime
-	FROM trip_x_parkingslot_departures AS departure
-	JOIN trips ON departure.Trips_id = trips.id
-	JOIN trip_x_parkingslot_arrivals AS arrival ON arrival.Trips_id = trips.id
-	WHERE DATE(departure.id) = CURDATE() AND DATE(arrival.id) = CURDATE()
-`
-
-	// Executar a query
-	rows, err := db.Query(queryToday)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer rows.Close()
-
-	// Variável para armazenar o array JSON
-	var resultArray []map[string]interface{}
-
-	// Variável para armazenar a soma de totalDistance_km
-	totalDistanceSum := 0.0
-
-	for rows.Next() {
-		var departureDatetime string
-		var totalDistanceKm float64
-		var tripID int
-		var arrivalDatetime string
-
-		// Ler os valores do resultado da query
-		err := rows.Scan(&departureDatetime, &totalDistanceKm, &tripID, &arrivalDatetime)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Criar um mapa com os dados da linha atual
-		rowData := map[string]interface{}{
-			"Departure_Datetime": departureDatetime,
-			"totalDistance_km":   totalDistanceKm,
-			"TripID":             tripID,
-			"Arrival_Datetime":   arrivalDatetime,
-		}
-
-		// Adicionar os dados ao array JSON
-		resultArray = append(resultArray, rowData)
-
-		// Somar o valor de totalDistance_km
-		totalDistanceSum += totalDistanceKm
-	}
-
-	// Verificar erros na iteração sobre as linhas
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-
-	// Imprimir o array JSON
-	jsonResult, err := json.MarshalIndent(resultArray, "", "    ")
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println(string(jsonResult))
-
-	// Imprimir a soma de totalDistance_km
-	fmt.Printf("Soma de totalDistance_km: %.2f\n", totalDistanceSum)
-
-    	err = mc.RegisterDataOnBlockchain(ctx, jsonResult)
-   	if err != nil {
-        	log.Fatal(err)
-   	}
-
-	return jsonResult, nil
-}
-
-
-// AdicionarTransacao adiciona uma transação ao bloco atual
-func (mc *MyContract) AdicionarTransacao(ctx contractapi.TransactionContextInterface, data string) error {
-	if currentBlock == nil {
-		currentBlock = &Block{
-			Transactions: []Transaction{},
-		}
-	}
-
-	transaction := Transaction{
-		Timestamp: time.Now(),
-		Data:      data,
-	}
-
-	currentBlock.Transactions = append(currentBlock.Transactions, transaction)
-	lastTransactionTimestamp = transaction.Timestamp
-
-	// Verificar se o número máximo de transações por bloco foi atingido
-	if len(currentBlock.Transactions) >= maxTransactionsPerBlock {
-		// Fechar o bloco e adicionar ao ledger
-		err := mc.FecharBloco(ctx)
-		if err != nil {
-			return fmt.Errorf("Erro ao fechar o bloco: %v", err)
-		}
-	}
-
-	return nil
-}
-
-// FecharBloco fecha o bloco atual se o limite de tempo ou número máximo de transações for atingido
-func (mc *MyContract) FecharBloco(ctx contractapi.TransactionContextInterface) error {
-	// Verificar se há transações no bloco atual
-	if currentBlock == nil || len(currentBlock.Transactions) == 0 {
-		return nil // Nenhum bloco a fechar
-	}
-
-	// Verificar se o tempo desde a última transação ultrapassou o limite
-	if time.Since(lastTransactionTimestamp) >= blockTimeLimit {
-		// Criar um novo bloco
-		currentBlock = &Block{
-			Transactions: []Transaction{},
-		}
-		return nil
-	}
-
-	// Obter a blockchain do estado
-	blockchainJSON, err := ctx.GetStub().GetState("blockchain")
-	if err != nil {
-		return fmt.Errorf("Erro ao obter blockchain do estado: %v", err)
-	}
-
-	var blockchain Blockchain
-	if blockchainJSON != nil {
-		err = json.Unmarshal(blockchainJSON, &blockchain)
-		if err != nil {
-			return fmt.Errorf("Erro ao deserializar blockchain do JSON: %v", err)
-		}
-	}
-
-	// Adicionar o bloco ao blockchain
-	blockchain.Blocks = append(blockchain.Blocks, *currentBlock)
-
-	// Serializar o blockchain para JSON
-	blockchainJSON, err = json.Marshal(blockchain)
-	if err != nil {
-		return fmt.Errorf("Erro ao serializar blockchain para JSON: %v", err)
-	}
-
-	// Calcular o hash do bloco usando SHA-256
-	hash := calcularHash(blockchainJSON)
-
-	// Imprimir o hash do bloco
-	fmt.Printf("Hash do Bloco: %s\n", hash)
-
-	// Adicionar o blockchain ao estado
-	err = ctx.GetStub().PutState("blockchain", blockchainJSON)
-	if err != nil {
-		return fmt.Errorf("Erro ao adicionar blockchain ao estado: %v", err)
-	}
-
-	currentBlock = &Block{
-		Transactions: []Transaction{},
-	}
-
-	return nil
-}
-
-// Função auxiliar para calcular o hash usando SHA-256
-func calcularHash(data []byte) string {
-	hasher := sha256.New()
-	hasher.Write(data)
-	return hex.EncodeToString(hasher.Sum(nil))
-}
-
-func main() {
-	Chaincodemove, err := contractapi.NewChaincode(&MyContract{})
-	if err != nil {
-		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
-	}
-
-	if err := Chaincodemove.Start(); err != nil {
-		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
-	}
-}
+package chaincode
+
+import (
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"Chaincodemove/config"
+	"Chaincodemove/source"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+func (ic *IngestContract) GetAllAssets(ctx txcontext.TransactionContextInterface) ([]byte, error) {
+    // Chamar a função QueryBanco para obter os dados
+    data, err := ic.QueryBanco(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    // Retornar os dados obtidos da consulta
+    return data, nil
+}
+
+
+// Transaction representa uma transação no livro-razão
+type Transaction struct {
+	Timestamp time.Time `json:"timestamp"`
+	Data      string    `json:"data"`
+}
+
+// Block representa um bloco contendo várias transações
+type Block struct {
+	Transactions []Transaction `json:"transactions"`
+}
+
+// Blockchain representa uma sequência de blocos
+type Blockchain struct {
+	Blocks []Block `json:"blocks"`
+}
+
+// Tipo de objeto da chave composta das âncoras de lotes lidos do banco
+const anchorObjectType = "anchor"
+
+// Tipos de objeto das chaves compostas dos lotes lidos do banco e dos blocos da aplicação.
+// As chaves simples guardam só dados de viagem, que GetAllTripData e as demais consultas
+// percorrem por faixa.
+const (
+	payloadObjectType = "payload"
+	batchObjectType   = "batch"
+)
+
+// Chave simples em que FecharBloco gravava os blocos antes de batchObjectType
+const legacyBlockchainKey = "blockchain"
+
+// BatchContract agrupa as transações em blocos da aplicação. Transações: "BatchContract:<nome>".
+type BatchContract struct {
+	contractBase
+}
+
+// GetEvaluateTransactions retorna as transações de BatchContract que só consultam o estado mundial
+func (bc *BatchContract) GetEvaluateTransactions() []string {
+	return []string{"GetBlocks"}
+}
+
+// GetEvaluateTransactions retorna as transações de IngestContract que só consultam o estado mundial
+func (ic *IngestContract) GetEvaluateTransactions() []string {
+	return []string{"GetSourceAnchor"}
+}
+
+// RegisterDataOnBlockchain grava o lote data na chave composta da transação. data é
+// string porque o contractapi não converte argumentos []byte.
+func (ic *IngestContract) RegisterDataOnBlockchain(ctx txcontext.TransactionContextInterface, data string) error {
+    // Gere um ID único para a transação
+    txID := ctx.GetStub().GetTxID()
+
+    // Registre os dados na blockchain na chave composta payload/<ID da transação>
+    key, err := ctx.GetStub().CreateCompositeKey(payloadObjectType, []string{txID})
+    if err != nil {
+        return txerror.Wrap(err, "falha ao criar a chave dos dados")
+    }
+    err = ctx.GetStub().PutState(key, []byte(data))
+    if err != nil {
+        return txerror.Wrap(err, "falha ao registrar os dados na blockchain")
+    }
+
+    return nil
+}
+
+// QueryBanco function to query data from MySQL and add transactions to the ledger
+func (ic *IngestContract) QueryBanco(ctx txcontext.TransactionContextInterface) ([]byte, error) {
+	// Conexão com o MySQL
+	cfg := ctx.Config()
+	db, err := sql.Open("mysql", cfg.DatabaseDSN)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao conectar ao banco de dados")
+	}
+	defer db.Close()
+
+	// A janela termina no dia da transação, e não em CURDATE(), para que o lote possa ser consultado de novo depois.
+	// O dia é o do fuso das datas do banco, e não o de UTC, que já virou à noite no Brasil.
+	window := source.LastDaysWindow(ctx.TxTime().In(cfg.Location()), cfg.QueryWindowDays)
+
+	// Executar a query
+	rows, err := source.QueryTrips(db, window)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao consultar as viagens")
+	}
+
+	// Somar o valor de totalDistance_km
+	totalDistanceSum := 0.0
+	for _, row := range rows {
+		totalDistanceSum += row.TotalDistanceKm
+	}
+
+	// Imprimir o array JSON
+	jsonResult, err := json.MarshalIndent(rows, "", "    ")
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter as viagens para JSON")
+	}
+	if cfg.LogLevel == config.LogLevelDebug {
+		fmt.Println(string(jsonResult))
+	}
+
+	// Imprimir a soma de totalDistance_km
+	fmt.Printf("Soma de totalDistance_km: %.2f\n", totalDistanceSum)
+
+	err = ic.RegisterDataOnBlockchain(ctx, string(jsonResult))
+	if err != nil {
+		return nil, err
+	}
+
+	// Ancorar o hash canônico das linhas lidas para permitir conferir o banco depois
+	err = anchorSourceBatch(ctx, rows, window)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResult, nil
+}
+
+
+// anchorSourceBatch grava o hash canônico, o número de linhas e a janela do lote lido do banco.
+// O companheiro cmd/verifyanchor recalcula o hash a partir do MySQL e compara com esta âncora.
+func anchorSourceBatch(ctx contractapi.TransactionContextInterface, rows []source.TripRow, window source.Window) error {
+	hash, err := source.CanonicalHash(rows)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao calcular o hash do lote")
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return txerror.Wrap(err, "falha ao obter o carimbo de data/hora da transação")
+	}
+
+	anchor := source.Anchor{
+		TxID:      ctx.GetStub().GetTxID(),
+		Hash:      hash,
+		RowCount:  len(rows),
+		Window:    window,
+		CreatedAt: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+	anchorJSON, err := json.Marshal(anchor)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao serializar a âncora para JSON")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(anchorObjectType, []string{anchor.TxID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da âncora")
+	}
+	err = ctx.GetStub().PutState(key, anchorJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao registrar a âncora na blockchain")
+	}
+
+	return nil
+}
+
+// GetSourceAnchor retorna a âncora do lote registrado pela transação com o ID fornecido
+func (ic *IngestContract) GetSourceAnchor(ctx txcontext.TransactionContextInterface, txID string) (*source.Anchor, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(anchorObjectType, []string{txID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da âncora")
+	}
+	anchorJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler a âncora do estado")
+	}
+	if anchorJSON == nil {
+		return nil, txerror.NotFound("a âncora da transação %s não existe", txID)
+	}
+
+	var anchor source.Anchor
+	err = json.Unmarshal(anchorJSON, &anchor)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao deserializar a âncora do JSON")
+	}
+
+	return &anchor, nil
+}
+
+// AdicionarTransacao adiciona uma transação ao bloco atual, com o carimbo de data/hora da
+// transação do Fabric, que é o mesmo em todos os peers
+func (bc *BatchContract) AdicionarTransacao(ctx txcontext.TransactionContextInterface, data string) error {
+	currentBlock, err := readCurrentBlock(ctx)
+	if err != nil {
+		return err
+	}
+
+	transaction := Transaction{
+		Timestamp: ctx.TxTime(),
+		Data:      data,
+	}
+
+	currentBlock.Transactions = append(currentBlock.Transactions, transaction)
+
+	// Verificar se o número máximo de transações por bloco foi atingido
+	cfg := ctx.Config()
+	if len(currentBlock.Transactions) < cfg.MaxTransactionsPerBlock {
+		return putCurrentBlock(ctx, currentBlock)
+	}
+
+	// Fechar o bloco e adicionar ao ledger. O bloco vai direto para closeBlock porque o
+	// GetState do Fabric não vê o que a própria transação gravou.
+	err = closeBlock(ctx, currentBlock)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao fechar o bloco")
+	}
+
+	return nil
+}
+
+// FecharBloco fecha o bloco atual se o limite de tempo ou número máximo de transações for atingido
+func (bc *BatchContract) FecharBloco(ctx txcontext.TransactionContextInterface) error {
+	currentBlock, err := readCurrentBlock(ctx)
+	if err != nil {
+		return err
+	}
+
+	return closeBlock(ctx, currentBlock)
+}
+
+// closeBlock acrescenta currentBlock aos blocos fechados e começa um bloco novo, ou só o
+// descarta se a última transação dele for mais antiga que o limite de tempo do bloco
+func closeBlock(ctx txcontext.TransactionContextInterface, currentBlock *Block) error {
+	// Verificar se há transações no bloco atual
+	if len(currentBlock.Transactions) == 0 {
+		return nil // Nenhum bloco a fechar
+	}
+
+	// Verificar se o tempo desde a última transação ultrapassou o limite
+	cfg := ctx.Config()
+	lastTransactionTimestamp := currentBlock.Transactions[len(currentBlock.Transactions)-1].Timestamp
+	if ctx.TxTime().Sub(lastTransactionTimestamp) >= cfg.BlockTimeLimit {
+		// Criar um novo bloco
+		return deleteCurrentBlock(ctx)
+	}
+
+	// Obter a blockchain do estado
+	blockchainJSON, err := readBlockchain(ctx)
+	if err != nil {
+		return err
+	}
+
+	var blockchain Blockchain
+	if blockchainJSON != nil {
+		err = json.Unmarshal(blockchainJSON, &blockchain)
+		if err != nil {
+			return txerror.Wrap(err, "Erro ao deserializar blockchain do JSON")
+		}
+	}
+
+	// Adicionar o bloco ao blockchain
+	blockchain.Blocks = append(blockchain.Blocks, *currentBlock)
+
+	// Serializar o blockchain para JSON
+	blockchainJSON, err = json.Marshal(blockchain)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao serializar blockchain para JSON")
+	}
+
+	// Calcular o hash do bloco usando SHA-256
+	hash := calcularHash(blockchainJSON)
+
+	// Imprimir o hash do bloco
+	fmt.Printf("Hash do Bloco: %s\n", hash)
+
+	// Adicionar o blockchain ao estado; a cópia antiga na chave simples deixa de valer
+	key, err := blockchainKey(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, blockchainJSON)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao adicionar blockchain ao estado")
+	}
+	err = ctx.GetStub().DelState(legacyBlockchainKey)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao remover a blockchain da chave antiga")
+	}
+
+	return deleteCurrentBlock(ctx)
+}
+
+// GetBlocks retorna o JSON dos blocos já fechados exatamente como está gravado no estado,
+// para que o hash impresso por FecharBloco possa ser recalculado
+func (bc *BatchContract) GetBlocks(ctx txcontext.TransactionContextInterface) (string, error) {
+	blockchainJSON, err := readBlockchain(ctx)
+	if err != nil {
+		return "", err
+	}
+	if blockchainJSON == nil {
+		return `{"blocks":[]}`, nil
+	}
+
+	return string(blockchainJSON), nil
+}
+
+// blockchainKey retorna a chave composta em que FecharBloco grava os blocos fechados
+func blockchainKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(batchObjectType, []string{"blockchain"})
+	if err != nil {
+		return "", txerror.Wrap(err, "Erro ao criar a chave da blockchain")
+	}
+
+	return key, nil
+}
+
+// currentBlockKey retorna a chave composta em que AdicionarTransacao grava o bloco atual,
+// ainda não fechado. Ele fica no estado mundial, e não na memória do processo, para que
+// todos os peers que endossam a transação vejam o mesmo bloco.
+func currentBlockKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(batchObjectType, []string{"current"})
+	if err != nil {
+		return "", txerror.Wrap(err, "Erro ao criar a chave do bloco atual")
+	}
+
+	return key, nil
+}
+
+// readCurrentBlock retorna o bloco atual, vazio se nenhuma transação foi adicionada desde
+// o último bloco fechado
+func readCurrentBlock(ctx contractapi.TransactionContextInterface) (*Block, error) {
+	key, err := currentBlockKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "Erro ao obter o bloco atual do estado")
+	}
+
+	block := &Block{Transactions: []Transaction{}}
+	if blockJSON == nil {
+		return block, nil
+	}
+	err = json.Unmarshal(blockJSON, block)
+	if err != nil {
+		return nil, txerror.Wrap(err, "Erro ao deserializar o bloco atual do JSON")
+	}
+
+	return block, nil
+}
+
+// putCurrentBlock grava o bloco atual no estado mundial
+func putCurrentBlock(ctx contractapi.TransactionContextInterface, block *Block) error {
+	key, err := currentBlockKey(ctx)
+	if err != nil {
+		return err
+	}
+	blockJSON, err := json.Marshal(block)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao serializar o bloco atual para JSON")
+	}
+	err = ctx.GetStub().PutState(key, blockJSON)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao gravar o bloco atual no estado")
+	}
+
+	return nil
+}
+
+// deleteCurrentBlock descarta o bloco atual; a próxima transação começa um bloco novo
+func deleteCurrentBlock(ctx contractapi.TransactionContextInterface) error {
+	key, err := currentBlockKey(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return txerror.Wrap(err, "Erro ao remover o bloco atual do estado")
+	}
+
+	return nil
+}
+
+// readBlockchain retorna o JSON dos blocos fechados, ou nil se nenhum bloco foi fechado.
+// Enquanto nenhum bloco novo for fechado, os blocos gravados na chave simples antiga
+// continuam sendo lidos de lá.
+func readBlockchain(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	key, err := blockchainKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockchainJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "Erro ao obter blockchain do estado")
+	}
+	if blockchainJSON != nil {
+		return blockchainJSON, nil
+	}
+
+	blockchainJSON, err = ctx.GetStub().GetState(legacyBlockchainKey)
+	if err != nil {
+		return nil, txerror.Wrap(err, "Erro ao obter blockchain do estado")
+	}
+
+	return blockchainJSON, nil
+}
+
+// Função auxiliar para calcular o hash usando SHA-256
+func calcularHash(data []byte) string {
+	hasher := sha256.New()
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
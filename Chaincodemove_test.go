@@ -0,0 +1,87 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+)
+
+// Os lotes lidos do banco e os blocos da aplicação ficam em chaves compostas e não
+// aparecem nas consultas que percorrem as viagens das chaves simples
+func TestBatchRecordsStayOutOfTrips(t *testing.T) {
+	contract := newContract(t)
+	var ledger map[string]interface{}
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger["MaxTransactionsPerBlock"] = 1
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "10", "2024-03-04T08:40:00Z")
+	submit(t, contract, nil, "IngestContract:RegisterDataOnBlockchain", `[{"TripID":1,"totalDistance_km":3}]`)
+	submit(t, contract, nil, "BatchContract:AdicionarTransacao", "viagem 1")
+
+	var blockchain chaincode.Blockchain
+	evaluate(t, contract, &blockchain, "BatchContract:GetBlocks")
+	if len(blockchain.Blocks) != 1 || len(blockchain.Blocks[0].Transactions) != 1 {
+		t.Errorf("blocos = %+v", blockchain)
+	}
+
+	var trips []*chaincode.TripData
+	evaluate(t, contract, &trips, "GetAllTripData")
+	if len(trips) != 1 || trips[0].ID != "t1" {
+		t.Errorf("viagens = %+v", trips)
+	}
+	var page chaincode.TripDataPage
+	evaluate(t, contract, &page, "GetTripDataPage", "", "10")
+	if len(page.Trips) != 1 || page.Bookmark != "" {
+		t.Errorf("página = %+v", page)
+	}
+	var savings []*chaincode.CO2Aggregate
+	evaluate(t, contract, &savings, "GetCO2Savings", "day")
+	if len(savings) != 1 || savings[0].DistanceKm != 10 {
+		t.Errorf("CO2 evitado = %+v", savings)
+	}
+	var certificate chaincode.CarbonCertificate
+	submit(t, contract, &certificate, "IssueCarbonCertificate", "2024-03")
+	if certificate.Trips != 1 {
+		t.Errorf("certificado = %+v", certificate)
+	}
+}
+
+// O bloco atual fica no estado mundial: uma transação só avaliada não entra nele, e as
+// transações levam o carimbo de data/hora do Fabric
+func TestAdicionarTransacaoKeepsCurrentBlockInState(t *testing.T) {
+	contract := newContract(t)
+	var ledger map[string]interface{}
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger["MaxTransactionsPerBlock"] = 2
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+
+	evaluate(t, contract, nil, "BatchContract:AdicionarTransacao", "descartada")
+	submit(t, contract, nil, "BatchContract:AdicionarTransacao", "viagem 1")
+	var blockchain chaincode.Blockchain
+	evaluate(t, contract, &blockchain, "BatchContract:GetBlocks")
+	if len(blockchain.Blocks) != 0 {
+		t.Fatalf("blocos antes do máximo = %+v", blockchain)
+	}
+
+	submit(t, contract, nil, "BatchContract:AdicionarTransacao", "viagem 2")
+	evaluate(t, contract, &blockchain, "BatchContract:GetBlocks")
+	if len(blockchain.Blocks) != 1 || len(blockchain.Blocks[0].Transactions) != 2 {
+		t.Fatalf("blocos = %+v", blockchain)
+	}
+	transactions := blockchain.Blocks[0].Transactions
+	if transactions[0].Data != "viagem 1" || transactions[1].Data != "viagem 2" {
+		t.Errorf("transações = %+v", transactions)
+	}
+	if transactions[0].Timestamp.IsZero() || transactions[1].Timestamp.Before(transactions[0].Timestamp) {
+		t.Errorf("carimbos = %v, %v", transactions[0].Timestamp, transactions[1].Timestamp)
+	}
+
+	// Fechado o bloco, a próxima transação começa outro
+	submit(t, contract, nil, "BatchContract:AdicionarTransacao", "viagem 3")
+	submit(t, contract, nil, "BatchContract:FecharBloco")
+	evaluate(t, contract, &blockchain, "BatchContract:GetBlocks")
+	if len(blockchain.Blocks) != 2 || len(blockchain.Blocks[1].Transactions) != 1 || blockchain.Blocks[1].Transactions[0].Data != "viagem 3" {
+		t.Errorf("blocos = %+v", blockchain)
+	}
+}
@@ -0,0 +1,38 @@
+package chaincode
+
+import (
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// operatorRole é o papel das identidades da operadora, que podem revisar e resolver
+// contestações e restaurar viagens excluídas
+const operatorRole = "operator"
+
+// requireRole falha se a identidade que assinou a transação não tiver o papel informado.
+// O papel de operadora só vale para as identidades dos MSPs de operadora gravados no
+// estado mundial por AdminContract.SetConfig (veja config.Config.IsOperatorMSP), já que
+// qualquer organização do canal pode emitir certificados com o atributo role=operator.
+func requireRole(ctx txcontext.TransactionContextInterface, role string) error {
+	if ctx.Role() != role {
+		return txerror.Unauthorized("a identidade não tem o papel %s exigido por esta transação", role)
+	}
+	if role == operatorRole && !ctx.Config().IsOperatorMSP(ctx.MSPID()) {
+		return txerror.Unauthorized("o MSP %s não é de uma operadora", ctx.MSPID())
+	}
+
+	return nil
+}
+
+// requireRiderOrOperator falha se a identidade não for a do ciclista informado (atributo
+// riderID) nem tiver o papel de operadora
+func requireRiderOrOperator(ctx txcontext.TransactionContextInterface, riderID string) error {
+	if riderID != "" && ctx.RiderID() == riderID {
+		return nil
+	}
+	if ctx.Role() != operatorRole || !ctx.Config().IsOperatorMSP(ctx.MSPID()) {
+		return txerror.Unauthorized("só o ciclista %s ou a operadora podem fazer esta transação", riderID)
+	}
+
+	return nil
+}
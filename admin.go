@@ -0,0 +1,60 @@
+package chaincode
+
+import (
+	"Chaincodemove/config"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// GetEvaluateTransactions retorna as transações de AdminContract que só consultam o estado mundial
+func (ac *AdminContract) GetEvaluateTransactions() []string {
+	return []string{"GetConfig", "GetPauseState"}
+}
+
+// GetConfig retorna a configuração em vigor: a gravada por SetConfig ou, antes da
+// primeira chamada, os limites dos blocos lidos do ambiente.
+func (ac *AdminContract) GetConfig(ctx txcontext.TransactionContextInterface) (*config.LedgerConfig, error) {
+	ledger := ctx.Config().Ledger()
+	return &ledger, nil
+}
+
+// SetConfig grava no estado mundial a configuração fornecida em JSON, no formato devolvido
+// por GetConfig. Ela vale a partir da próxima transação, em todos os contratos.
+//
+// A configuração traz os MSPs de operadora, e até a primeira chamada não há nenhum: ela
+// deve ser feita logo depois de instalar o chaincode, por uma identidade com o papel de
+// operadora de um dos MSPs da lista.
+func (ac *AdminContract) SetConfig(ctx txcontext.TransactionContextInterface, configJSON string) (*config.LedgerConfig, error) {
+	return txcontext.SetLedgerConfig(ctx, configJSON)
+}
+
+// GetPauseState retorna o estado da pausa de emergência
+func (ac *AdminContract) GetPauseState(ctx txcontext.TransactionContextInterface) (*txcontext.PauseState, error) {
+	pause := ctx.Pause()
+	return &pause, nil
+}
+
+// Pause liga a pausa de emergência: a partir da próxima transação, as transações de
+// TripContract e IngestContract que gravam no estado mundial falham com UNAVAILABLE,
+// e as consultas continuam funcionando.
+func (ac *AdminContract) Pause(ctx txcontext.TransactionContextInterface, reason string) (*txcontext.PauseState, error) {
+	return txcontext.Pause(ctx, reason)
+}
+
+// Resume desliga a pausa de emergência
+func (ac *AdminContract) Resume(ctx txcontext.TransactionContextInterface) (*txcontext.PauseState, error) {
+	return txcontext.Resume(ctx)
+}
+
+// validateTripDistance falha se a distância estiver fora dos limites da configuração
+func validateTripDistance(ctx txcontext.TransactionContextInterface, totalDistanceKm float64) error {
+	cfg := ctx.Config()
+	if totalDistanceKm < cfg.MinTripDistanceKm {
+		return txerror.Validation("a distância %.2f km é menor que o mínimo de %.2f km", totalDistanceKm, cfg.MinTripDistanceKm)
+	}
+	if cfg.MaxTripDistanceKm > 0 && totalDistanceKm > cfg.MaxTripDistanceKm {
+		return txerror.Validation("a distância %.2f km é maior que o máximo de %.2f km", totalDistanceKm, cfg.MaxTripDistanceKm)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package chaincode_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/config"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+func TestSetConfig(t *testing.T) {
+	contract := newContract(t)
+	var ledger config.LedgerConfig
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger.MaxTripDistanceKm = 50
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+
+	var stored config.LedgerConfig
+	evaluate(t, contract, &stored, "AdminContract:GetConfig")
+	if !reflect.DeepEqual(stored, ledger) {
+		t.Errorf("configuração = %+v, quer %+v", stored, ledger)
+	}
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	wantError(t, contract, txerror.CodeValidation, "UpdateTripData", "t1", "2024-03-04T08:00:00Z", "60", "1", "2024-03-04T08:20:00Z")
+
+	ledger.MaxTransactionsPerBlock = 0
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetConfig", toJSON(t, ledger))
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetConfig", "{")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:SetConfig", toJSON(t, stored))
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:GetConfig")
+}
+
+func TestPause(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	var pause txcontext.PauseState
+	submit(t, contract, &pause, "AdminContract:Pause", "incidente")
+	if !pause.Paused || pause.Reason != "incidente" {
+		t.Errorf("pausa = %+v", pause)
+	}
+	wantError(t, contract, txerror.CodeUnavailable, "CreateTripData", "t2", "2024-03-04T08:00:00Z", "4", "1", "2024-03-04T08:20:00Z")
+	evaluate(t, contract, nil, "ReadTripData", "t1")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:Pause", "de novo")
+
+	submit(t, contract, &pause, "AdminContract:Resume")
+	if pause.Paused {
+		t.Errorf("pausa depois de Resume = %+v", pause)
+	}
+	createTrip(t, contract, "t2", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:Resume")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:Pause", "")
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:Pause", "incidente")
+}
+
+// O atributo role=operator só vale para as identidades dos MSPs de operadora gravados
+// por SetConfig; o MSP de uma operadora configurado no ambiente não conta
+func TestOperatorMSPs(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,bike-rio")
+	t.Setenv("MOVEUFF_OPERATOR_BIKE_RIO_MSP_ID", "BikeRioMSP")
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	for _, mspID := range []string{"Org2MSP", "BikeRioMSP"} {
+		setIdentity(t, contract, mspID, operator)
+		wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:GetConfig")
+		wantError(t, contract, txerror.CodeUnauthorized, "AddCredits", "rider1", "10")
+		wantError(t, contract, txerror.CodeUnauthorized, "RedeemReward", "r1", "rider1")
+	}
+
+	setIdentity(t, contract, "Org1MSP", operator)
+	var ledger config.LedgerConfig
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger.OperatorMSPs = []string{"Org2MSP"}
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetConfig", toJSON(t, ledger))
+	ledger.OperatorMSPs = []string{"Org1MSP", "Org2MSP"}
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+
+	setIdentity(t, contract, "Org2MSP", operator)
+	submit(t, contract, nil, "AddCredits", "rider1", "10")
+}
+
+// Antes da primeira configuração não há MSP de operadora: só SetConfig é aceita, de uma
+// identidade com o papel de operadora cujo MSP esteja na lista gravada
+func TestBootstrapConfig(t *testing.T) {
+	contract, err := mock.New("Org1MSP")
+	if err != nil {
+		t.Fatalf("mock.New: %v", err)
+	}
+	setIdentity(t, contract, "Org1MSP", operator)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:GetConfig")
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:Pause", "incidente")
+	wantError(t, contract, txerror.CodeUnauthorized, "AddCredits", "rider1", "10")
+
+	ledger := config.Default().Ledger()
+	ledger.OperatorMSPs = []string{"Org1MSP"}
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:SetConfig", toJSON(t, ledger))
+	setIdentity(t, contract, "Org2MSP", operator)
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetConfig", toJSON(t, ledger))
+
+	setIdentity(t, contract, "Org1MSP", operator)
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+	submit(t, contract, nil, "AddCredits", "rider1", "10")
+
+	// Depois dela, os outros MSPs não podem mais reconfigurar o chaincode
+	setIdentity(t, contract, "Org2MSP", operator)
+	ledger.OperatorMSPs = []string{"Org2MSP"}
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:SetConfig", toJSON(t, ledger))
+}
+
+// Durante a pausa, toda transação que grava em TripContract, BatchContract e
+// IngestContract é recusada antes de rodar, e as consultas declaradas por eles e as
+// transações de AdminContract continuam aceitas
+func TestPauseCoversEveryTransaction(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "AdminContract:Pause", "incidente")
+
+	for _, c := range chaincode.NewContracts(nil) {
+		transactions := txcontext.NewTransactions(c)
+		names := map[string]bool{}
+		for _, name := range transactions.Names {
+			names[name] = true
+		}
+		evaluations := map[string]bool{}
+		if evaluation, ok := c.(contractapi.EvaluationContractInterface); ok {
+			for _, name := range evaluation.GetEvaluateTransactions() {
+				if !names[name] {
+					t.Errorf("%s declara a consulta %s, que não existe", transactions.Contract, name)
+				}
+				evaluations[name] = true
+			}
+		}
+
+		for _, name := range transactions.Names {
+			// Resume tiraria a pausa das transações seguintes
+			if name == "Resume" {
+				continue
+			}
+			// As transações recebem argumentos vazios: basta saber se a pausa as recusou
+			_, err := contract.Submit(transactions.Contract + ":" + name)
+			paused := txerror.CodeOf(err) == txerror.CodeUnavailable
+			wantPaused := transactions.Contract != "AdminContract" && !evaluations[name]
+			if paused != wantPaused {
+				t.Errorf("%s:%s durante a pausa: %v, quer recusada = %v", transactions.Contract, name, err, wantPaused)
+			}
+		}
+	}
+
+	submit(t, contract, nil, "AdminContract:Resume")
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+}
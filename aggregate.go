@@ -0,0 +1,425 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"Chaincodemove/config"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// aggregateObjectType é o tipo de objeto dos agregados:
+// aggregate/<granularidade>/<agrupamento>/<grupo>/<período>
+const aggregateObjectType = "aggregate"
+
+// Granularidades dos agregados. Os períodos são a semana ISO (AAAA-Wss) ou o mês (AAAA-MM),
+// que ordenados como texto ficam em ordem cronológica.
+const (
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// Agrupamentos dos agregados, além de groupBySlot: todas as viagens juntas ou por operadora
+const (
+	groupByTotal    = "total"
+	groupByOperator = "operator"
+)
+
+// aggregateGranularities e aggregateGroupings são as combinações mantidas a cada viagem gravada
+var (
+	aggregateGranularities = []string{GranularityWeek, GranularityMonth}
+	aggregateGroupings     = []string{groupByTotal, groupBySlot, groupByOperator}
+)
+
+// distanceHistogramEdges são os limites superiores, em km, das faixas do histograma de
+// distâncias dos agregados; a última faixa, sem limite, vai até MaxDistanceKm.
+var distanceHistogramEdges = []float64{0.5, 1, 2, 3, 5, 8, 13, 21, 34, 55}
+
+// TripAggregate acumula as viagens que partiram num período, num grupo. É atualizado a
+// cada viagem gravada, alterada, excluída ou restaurada e a cada contestação aceita, então
+// as consultas leem um registro por período e grupo em vez de todas as viagens. Conta a
+// versão mais recente de cada viagem (veja latestTripVersion). O histograma de distâncias
+// permite estimar os percentis.
+type TripAggregate struct {
+	Granularity   string  `json:"Granularity"`
+	GroupBy       string  `json:"GroupBy"`
+	Group         string  `json:"Group"`
+	Bucket        string  `json:"Bucket"`
+	Trips         int     `json:"Trips"`
+	DistanceKm    float64 `json:"DistanceKm"`
+	MaxDistanceKm float64 `json:"MaxDistanceKm"`
+	Histogram     []int   `json:"Histogram"`
+}
+
+// TimeSeriesPoint é um ponto da série devolvida por GetAggregates. Os percentis da
+// distância são estimados pelo histograma do agregado, interpolando dentro da faixa.
+type TimeSeriesPoint struct {
+	Bucket         string  `json:"Bucket"`
+	Group          string  `json:"Group"`
+	Trips          int     `json:"Trips"`
+	DistanceKm     float64 `json:"DistanceKm"`
+	MeanDistanceKm float64 `json:"MeanDistanceKm"`
+	P50DistanceKm  float64 `json:"P50DistanceKm"`
+	P90DistanceKm  float64 `json:"P90DistanceKm"`
+	P95DistanceKm  float64 `json:"P95DistanceKm"`
+}
+
+// GetAggregates retorna a série temporal das viagens por semana ou mês (granularity),
+// de from a to (AAAA-MM-DD, inclusivos; vazios não limitam), para todas as viagens
+// (groupBy total) ou por vaga de partida (slot) ou operadora (operator). Os pontos vêm
+// em ordem de período e de grupo.
+//
+// Quando uma viagem sai de um agregado, MaxDistanceKm não é recalculado: até
+// AdminContract:RebuildAggregates é um limite superior das distâncias.
+func (tc *TripContract) GetAggregates(ctx txcontext.TransactionContextInterface, granularity string, from string, to string, groupBy string) ([]*TimeSeriesPoint, error) {
+	if granularity != GranularityWeek && granularity != GranularityMonth {
+		return nil, txerror.Validation("granularidade inválida %q: use %s ou %s", granularity, GranularityWeek, GranularityMonth)
+	}
+	if groupBy != groupByTotal && groupBy != groupBySlot && groupBy != groupByOperator {
+		return nil, txerror.Validation("agrupamento inválido %q: use %s, %s ou %s", groupBy, groupByTotal, groupBySlot, groupByOperator)
+	}
+	var bounds [2]string
+	for i, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(tariffDateLayout, value)
+		if err != nil {
+			return nil, txerror.Validation("data inválida %q: use AAAA-MM-DD", value)
+		}
+		bounds[i] = aggregateBucket(granularity, date)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(aggregateObjectType, []string{granularity, groupBy})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter os agregados")
+	}
+	defer resultsIterator.Close()
+
+	points := []*TimeSeriesPoint{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var aggregate TripAggregate
+		err = json.Unmarshal(queryResponse.Value, &aggregate)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal do agregado")
+		}
+		if (bounds[0] != "" && aggregate.Bucket < bounds[0]) || (bounds[1] != "" && aggregate.Bucket > bounds[1]) {
+			continue
+		}
+		points = append(points, aggregate.point())
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Bucket != points[j].Bucket {
+			return points[i].Bucket < points[j].Bucket
+		}
+		return points[i].Group < points[j].Group
+	})
+
+	return points, nil
+}
+
+// RebuildAggregates apaga os agregados e os recalcula a partir da versão mais recente de
+// todas as viagens não excluídas, das chaves simples e das operadoras. Serve para incluir
+// as viagens gravadas antes dos agregados existirem e para recalcular MaxDistanceKm. Lê
+// todas as viagens numa transação só. Retorna quantas viagens foram agregadas.
+func (ac *AdminContract) RebuildAggregates(ctx txcontext.TransactionContextInterface) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(aggregateObjectType, []string{})
+	if err != nil {
+		return 0, txerror.Wrap(err, "falha ao obter os agregados")
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return 0, txerror.Wrap(err, "falha ao apagar o agregado %q", queryResponse.Key)
+		}
+	}
+
+	aggregates := map[string]*TripAggregate{}
+	trips := 0
+	add := func(tripData *TripData) error {
+		tripData, err := latestTripVersion(ctx, tripData)
+		if err != nil {
+			return err
+		}
+		keys, err := aggregateKeys(ctx, tripData)
+		if err != nil {
+			return err
+		}
+		for key, aggregate := range keys {
+			if existing, ok := aggregates[key]; ok {
+				aggregate = existing
+			}
+			aggregate.add(tripData.TotalDistanceKm)
+			aggregates[key] = aggregate
+		}
+		if len(keys) > 0 {
+			trips++
+		}
+		return nil
+	}
+
+	var addErr error
+	err = forEachTrip(ctx, func(tripData *TripData) {
+		if addErr == nil {
+			addErr = add(tripData)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if addErr != nil {
+		return 0, addErr
+	}
+
+	// As chaves são gravadas em ordem para que o conjunto de escrita seja o mesmo em todos os peers
+	keys := make([]string, 0, len(aggregates))
+	for key := range aggregates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := putAggregate(ctx, key, aggregates[key]); err != nil {
+			return 0, err
+		}
+	}
+
+	return trips, nil
+}
+
+// aggregateTripData soma a viagem que acabou de ser gravada aos seus agregados. Viagens
+// sem data de partida válida não entram nos agregados.
+func aggregateTripData(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	return updateAggregates(ctx, nil, tripData)
+}
+
+// updateAggregates tira dos agregados a versão removed da viagem e soma a versão added;
+// qualquer uma pode ser nil. Cada agregado é lido e gravado uma vez só, porque as leituras
+// do Fabric não veem o que a própria transação gravou. Um agregado que fica sem viagens é
+// apagado, e uma viagem que não está num agregado gravado (por ter sido gravada antes dos
+// agregados existirem) não é tirada dele.
+func updateAggregates(ctx txcontext.TransactionContextInterface, removed *TripData, added *TripData) error {
+	aggregates := map[string]*TripAggregate{}
+	load := func(key string, empty *TripAggregate) (*TripAggregate, bool, error) {
+		if aggregate, ok := aggregates[key]; ok {
+			return aggregate, true, nil
+		}
+		existingJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, false, txerror.Wrap(err, "falha ao ler do estado mundial")
+		}
+		if existingJSON == nil {
+			return empty, false, nil
+		}
+		aggregate := &TripAggregate{}
+		if err := json.Unmarshal(existingJSON, aggregate); err != nil {
+			return nil, false, txerror.Wrap(err, "falha ao fazer unmarshal do agregado")
+		}
+		aggregates[key] = aggregate
+		return aggregate, true, nil
+	}
+
+	if removed != nil {
+		keys, err := aggregateKeys(ctx, removed)
+		if err != nil {
+			return err
+		}
+		for key, empty := range keys {
+			aggregate, exists, err := load(key, empty)
+			if err != nil {
+				return err
+			}
+			if exists {
+				aggregate.remove(removed.TotalDistanceKm)
+			}
+		}
+	}
+	if added != nil {
+		keys, err := aggregateKeys(ctx, added)
+		if err != nil {
+			return err
+		}
+		for key, empty := range keys {
+			aggregate, _, err := load(key, empty)
+			if err != nil {
+				return err
+			}
+			aggregate.add(added.TotalDistanceKm)
+			aggregates[key] = aggregate
+		}
+	}
+
+	// As chaves são gravadas em ordem para que o conjunto de escrita seja o mesmo em todos os peers
+	keys := make([]string, 0, len(aggregates))
+	for key := range aggregates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if aggregates[key].Trips > 0 {
+			if err := putAggregate(ctx, key, aggregates[key]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return txerror.Wrap(err, "falha ao apagar o agregado %q", key)
+		}
+	}
+
+	return nil
+}
+
+// aggregateKeys retorna, para cada granularidade e agrupamento, a chave do agregado da
+// viagem e um agregado vazio para ela. O mapa fica vazio se a partida não for uma data válida.
+func aggregateKeys(ctx txcontext.TransactionContextInterface, tripData *TripData) (map[string]*TripAggregate, error) {
+	keys := map[string]*TripAggregate{}
+	departure, err := parseTripDatetime(tripData.DepartureDatetime)
+	if err != nil {
+		return keys, nil
+	}
+
+	for _, granularity := range aggregateGranularities {
+		bucket := aggregateBucket(granularity, departure)
+		for _, groupBy := range aggregateGroupings {
+			group := groupByTotal
+			switch groupBy {
+			case groupBySlot:
+				group = unknownGroup
+				if tripData.DepartureSlot != "" {
+					group = tripData.DepartureSlot
+				}
+			case groupByOperator:
+				group = config.DefaultOperatorID
+				if tripData.OperatorID != "" {
+					group = tripData.OperatorID
+				}
+			}
+
+			key, err := ctx.GetStub().CreateCompositeKey(aggregateObjectType, []string{granularity, groupBy, group, bucket})
+			if err != nil {
+				return nil, txerror.Wrap(err, "falha ao criar a chave do agregado")
+			}
+			keys[key] = &TripAggregate{
+				Granularity: granularity,
+				GroupBy:     groupBy,
+				Group:       group,
+				Bucket:      bucket,
+				Histogram:   make([]int, len(distanceHistogramEdges)+1),
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// aggregateBucket retorna o período da data na granularidade: AAAA-Wss ou AAAA-MM
+func aggregateBucket(granularity string, date time.Time) string {
+	if granularity == GranularityWeek {
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+
+	return date.Format("2006-01")
+}
+
+// add soma uma viagem ao agregado
+func (a *TripAggregate) add(distanceKm float64) {
+	a.Trips++
+	a.DistanceKm += distanceKm
+	if distanceKm > a.MaxDistanceKm {
+		a.MaxDistanceKm = distanceKm
+	}
+	bin := sort.SearchFloat64s(distanceHistogramEdges, distanceKm)
+	a.Histogram[bin]++
+}
+
+// remove tira uma viagem do agregado. MaxDistanceKm não é recalculado
+func (a *TripAggregate) remove(distanceKm float64) {
+	if a.Trips == 0 {
+		return
+	}
+	a.Trips--
+	a.DistanceKm -= distanceKm
+	if a.Trips == 0 {
+		a.DistanceKm = 0
+		a.MaxDistanceKm = 0
+	}
+	bin := sort.SearchFloat64s(distanceHistogramEdges, distanceKm)
+	if a.Histogram[bin] > 0 {
+		a.Histogram[bin]--
+	}
+}
+
+// point converte o agregado num ponto da série temporal
+func (a *TripAggregate) point() *TimeSeriesPoint {
+	point := &TimeSeriesPoint{
+		Bucket:     a.Bucket,
+		Group:      a.Group,
+		Trips:      a.Trips,
+		DistanceKm: a.DistanceKm,
+	}
+	if a.Trips > 0 {
+		point.MeanDistanceKm = a.DistanceKm / float64(a.Trips)
+		point.P50DistanceKm = a.percentile(0.5)
+		point.P90DistanceKm = a.percentile(0.9)
+		point.P95DistanceKm = a.percentile(0.95)
+	}
+
+	return point
+}
+
+// percentile estima o percentil p da distância: acha a faixa do histograma que contém a
+// posição do percentil e interpola linearmente entre os limites dela
+func (a *TripAggregate) percentile(p float64) float64 {
+	rank := math.Ceil(p * float64(a.Trips))
+	cumulative := 0
+	for bin, count := range a.Histogram {
+		if count == 0 || float64(cumulative+count) < rank {
+			cumulative += count
+			continue
+		}
+
+		lower := 0.0
+		if bin > 0 {
+			lower = distanceHistogramEdges[bin-1]
+		}
+		upper := a.MaxDistanceKm
+		if bin < len(distanceHistogramEdges) && distanceHistogramEdges[bin] < upper {
+			upper = distanceHistogramEdges[bin]
+		}
+		if upper < lower {
+			return upper
+		}
+
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(count)
+	}
+
+	return a.MaxDistanceKm
+}
+
+// putAggregate grava o agregado na chave fornecida
+func putAggregate(ctx txcontext.TransactionContextInterface, key string, aggregate *TripAggregate) error {
+	aggregateJSON, err := json.Marshal(aggregate)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter agregado para JSON")
+	}
+	err = ctx.GetStub().PutState(key, aggregateJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o agregado no estado mundial")
+	}
+
+	return nil
+}
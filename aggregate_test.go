@@ -0,0 +1,96 @@
+package chaincode_test
+
+import (
+	"math"
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/txerror"
+)
+
+// weekTotals retorna as viagens e a distância de cada semana, somando todas as viagens
+func weekTotals(t *testing.T, contract *mock.Backend) map[string][2]float64 {
+	t.Helper()
+	var points []*chaincode.TimeSeriesPoint
+	evaluate(t, contract, &points, "GetAggregates", "week", "", "", "total")
+	totals := map[string][2]float64{}
+	for _, point := range points {
+		totals[point.Bucket] = [2]float64{float64(point.Trips), math.Round(point.DistanceKm*1000) / 1000}
+	}
+	return totals
+}
+
+func wantWeekTotals(t *testing.T, contract *mock.Backend, step string, want map[string][2]float64) {
+	t.Helper()
+	got := weekTotals(t, contract)
+	if len(got) != len(want) {
+		t.Fatalf("%s: semanas = %v, quer %v", step, got, want)
+	}
+	for bucket, totals := range want {
+		if got[bucket] != totals {
+			t.Errorf("%s: semana %s = %v, quer %v", step, bucket, got[bucket], totals)
+		}
+	}
+}
+
+func TestAggregatesFollowTripChanges(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "0.7", "2024-03-04T08:10:00Z")
+	createTrip(t, contract, "t2", "2024-03-05T08:00:00Z", "4", "2024-03-05T08:20:00Z")
+	wantWeekTotals(t, contract, "criação", map[string][2]float64{"2024-W10": {2, 4.7}})
+
+	submit(t, contract, nil, "DeleteTripData", "t1", "duplicada")
+	wantWeekTotals(t, contract, "exclusão", map[string][2]float64{"2024-W10": {1, 4}})
+
+	submit(t, contract, nil, "RestoreTripData", "t1")
+	wantWeekTotals(t, contract, "restauração", map[string][2]float64{"2024-W10": {2, 4.7}})
+
+	submit(t, contract, nil, "UpdateTripData", "t2", "2024-03-12T08:00:00Z", "5", "2", "2024-03-12T08:20:00Z")
+	wantWeekTotals(t, contract, "alteração", map[string][2]float64{"2024-W10": {1, 0.7}, "2024-W11": {1, 5}})
+
+	submit(t, contract, nil, "OpenDispute", "d1", "t2", "distância errada", evidenceHash)
+	submit(t, contract, nil, "StartDisputeReview", "d1")
+	submit(t, contract, nil, "AcceptDispute", "d1", "3", "corrigida")
+	wantWeekTotals(t, contract, "contestação aceita", map[string][2]float64{"2024-W10": {1, 0.7}, "2024-W11": {1, 3}})
+
+	// Com uma correção aceita, a viagem não pode mais ser alterada, e os agregados não mudam
+	wantError(t, contract, txerror.CodeValidation, "UpdateTripData", "t2", "2024-03-12T08:00:00Z", "6", "2", "2024-03-12T08:20:00Z")
+	wantWeekTotals(t, contract, "alteração depois da correção", map[string][2]float64{"2024-W10": {1, 0.7}, "2024-W11": {1, 3}})
+
+	submit(t, contract, nil, "DeleteTripData", "t2", "duplicada")
+	wantWeekTotals(t, contract, "exclusão da viagem corrigida", map[string][2]float64{"2024-W10": {1, 0.7}})
+
+	var rebuilt int
+	submit(t, contract, &rebuilt, "AdminContract:RebuildAggregates")
+	if rebuilt != 1 {
+		t.Errorf("RebuildAggregates = %d, quer 1", rebuilt)
+	}
+	wantWeekTotals(t, contract, "reconstrução", map[string][2]float64{"2024-W10": {1, 0.7}})
+}
+
+func TestAggregatesByOperatorAndSlot(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "2", "2024-03-04T08:10:00Z")
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"900","TripID":900,"Departure_Datetime":"2024-03-06T08:00:00Z","Arrival_Datetime":"2024-03-06T08:20:00Z","totalDistance_km":3,"DepartureSlot":"s1"}`)
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"901","TripID":901,"Departure_Datetime":"2024-04-01T08:00:00Z","Arrival_Datetime":"2024-04-01T08:20:00Z","totalDistance_km":1,"DepartureSlot":"s1"}`)
+
+	var points []*chaincode.TimeSeriesPoint
+	evaluate(t, contract, &points, "GetAggregates", "month", "", "2024-03-31", "operator")
+	if len(points) != 2 || points[0].Group != "moveuff" || points[0].Trips != 1 || points[1].Group != "opa" || points[1].DistanceKm != 3 {
+		t.Errorf("março por operadora = %+v", points)
+	}
+	var slots []*chaincode.TimeSeriesPoint
+	evaluate(t, contract, &slots, "GetAggregates", "month", "2024-04-01", "", "slot")
+	if len(slots) != 1 || slots[0].Bucket != "2024-04" || slots[0].Group != "s1" || slots[0].Trips != 1 {
+		t.Errorf("abril por vaga = %+v", slots)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "GetAggregates", "day", "", "", "total")
+	wantError(t, contract, txerror.CodeValidation, "GetAggregates", "week", "", "", "rider")
+	wantError(t, contract, txerror.CodeValidation, "GetAggregates", "week", "março", "", "total")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:RebuildAggregates")
+}
@@ -0,0 +1,325 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Chave da configuração das regras de anomalia e tipo de objeto do índice das viagens
+// sinalizadas (trip-flag/<regra>/<operadora>/<ID>; a operadora fica vazia nas viagens
+// gravadas em chave simples)
+const (
+	anomalyRulesConfigKey = "anomaly-rules"
+	tripFlagObjectType    = "trip-flag"
+)
+
+// flaggedTripsPageSize é quantas viagens sinalizadas cada página de ListFlaggedTrips traz
+const flaggedTripsPageSize = 50
+
+// Regras de anomalia avaliadas quando uma viagem é gravada
+const (
+	RuleInvalidDatetime   = "invalid-datetime"
+	RuleZeroDuration      = "zero-duration"
+	RuleNegativeDistance  = "negative-distance"
+	RuleExcessiveDistance = "excessive-distance"
+	RuleImplausibleSpeed  = "implausible-speed"
+)
+
+// anomalyRuleNames lista as regras na ordem em que são avaliadas
+var anomalyRuleNames = []string{RuleInvalidDatetime, RuleZeroDuration, RuleNegativeDistance, RuleExcessiveDistance, RuleImplausibleSpeed}
+
+// AnomalyRules guarda os limites das regras de anomalia. Uma viagem que viola uma regra
+// é gravada mesmo assim, com a regra em Flags, para ser revisada pela operadora.
+type AnomalyRules struct {
+	// MinDurationSeconds: viagens mais curtas violam zero-duration (as de duração zero sempre violam)
+	MinDurationSeconds float64 `json:"MinDurationSeconds"`
+	// MaxDistanceKm: viagens mais longas violam excessive-distance; zero desliga a regra
+	MaxDistanceKm float64 `json:"MaxDistanceKm"`
+	// MaxSpeedKmh: viagens com velocidade média maior violam implausible-speed; zero desliga a regra
+	MaxSpeedKmh float64 `json:"MaxSpeedKmh"`
+	// Disabled lista as regras desligadas
+	Disabled []string `json:"Disabled"`
+}
+
+// TripFlag é uma regra de anomalia violada pela viagem
+type TripFlag struct {
+	Rule   string `json:"Rule"`
+	Detail string `json:"Detail"`
+}
+
+// FlaggedTrip é uma viagem sinalizada por uma regra, com a chave em que está gravada
+type FlaggedTrip struct {
+	Rule       string    `json:"Rule"`
+	OperatorID string    `json:"OperatorID,omitempty" metadata:",optional"`
+	TripData   *TripData `json:"TripData"`
+}
+
+// FlaggedTripPage é uma página de ListFlaggedTrips. Total conta as viagens sinalizadas
+// em todas as páginas.
+type FlaggedTripPage struct {
+	Rule     string         `json:"Rule,omitempty" metadata:",optional"`
+	Page     int            `json:"Page"`
+	PageSize int            `json:"PageSize"`
+	Total    int            `json:"Total"`
+	Trips    []*FlaggedTrip `json:"Trips"`
+}
+
+// defaultAnomalyRules é usada enquanto nenhuma configuração for gravada com SetAnomalyRules
+func defaultAnomalyRules() AnomalyRules {
+	return AnomalyRules{
+		MinDurationSeconds: 60,
+		MaxDistanceKm:      100,
+		MaxSpeedKmh:        40,
+		Disabled:           []string{},
+	}
+}
+
+// SetAnomalyRules grava as regras de anomalia fornecidas em JSON, no formato devolvido
+// por GetAnomalyRules. Valem para as viagens gravadas a partir da próxima transação; as
+// já gravadas só são reavaliadas quando alteradas por UpdateTripData ou corrigidas por
+// AcceptDispute. Como toda transação
+// de AdminContract, só a operadora pode chamá-la.
+func (ac *AdminContract) SetAnomalyRules(ctx txcontext.TransactionContextInterface, rulesJSON string) (*AnomalyRules, error) {
+	var rules AnomalyRules
+	err := json.Unmarshal([]byte(rulesJSON), &rules)
+	if err != nil {
+		return nil, txerror.Validation("falha ao fazer unmarshal das regras de anomalia: %v", err)
+	}
+	if rules.MinDurationSeconds < 0 || rules.MaxDistanceKm < 0 || rules.MaxSpeedKmh < 0 {
+		return nil, txerror.Validation("os limites das regras de anomalia não podem ser negativos")
+	}
+	for _, rule := range rules.Disabled {
+		if !knownAnomalyRule(rule) {
+			return nil, txerror.Validation("regra de anomalia desconhecida %q", rule)
+		}
+	}
+	if rules.Disabled == nil {
+		rules.Disabled = []string{}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(txcontext.ConfigObjectType, []string{anomalyRulesConfigKey})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave das regras de anomalia")
+	}
+	storedJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter as regras de anomalia para JSON")
+	}
+	err = ctx.GetStub().PutState(key, storedJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar as regras de anomalia no estado mundial")
+	}
+
+	return &rules, nil
+}
+
+// GetAnomalyRules retorna as regras de anomalia em uso.
+func (tc *TripContract) GetAnomalyRules(ctx txcontext.TransactionContextInterface) (*AnomalyRules, error) {
+	return readAnomalyRules(ctx)
+}
+
+// readAnomalyRules retorna as regras gravadas por SetAnomalyRules ou, antes disso, as padrão
+func readAnomalyRules(ctx txcontext.TransactionContextInterface) (*AnomalyRules, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(txcontext.ConfigObjectType, []string{anomalyRulesConfigKey})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave das regras de anomalia")
+	}
+	rulesJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+
+	rules := defaultAnomalyRules()
+	if rulesJSON == nil {
+		return &rules, nil
+	}
+	err = json.Unmarshal(rulesJSON, &rules)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal das regras de anomalia")
+	}
+
+	return &rules, nil
+}
+
+// ListFlaggedTrips retorna a página page (a partir de 1) das viagens sinalizadas pela
+// regra, ou por qualquer regra se rule for vazia. Uma viagem que viola várias regras
+// aparece uma vez por regra, pela última correção aceita, se houver; as viagens excluídas
+// não aparecem. Só a operadora revisa as viagens sinalizadas.
+func (tc *TripContract) ListFlaggedTrips(ctx txcontext.TransactionContextInterface, rule string, page int) (*FlaggedTripPage, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if rule != "" && !knownAnomalyRule(rule) {
+		return nil, txerror.Validation("regra de anomalia desconhecida %q", rule)
+	}
+	if page < 1 {
+		return nil, txerror.Validation("a página deve ser 1 ou maior")
+	}
+
+	attributes := []string{}
+	if rule != "" {
+		attributes = append(attributes, rule)
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripFlagObjectType, attributes)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as viagens sinalizadas")
+	}
+	defer resultsIterator.Close()
+
+	result := &FlaggedTripPage{Rule: rule, Page: page, PageSize: flaggedTripsPageSize, Trips: []*FlaggedTrip{}}
+	first := (page - 1) * flaggedTripsPageSize
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 3 {
+			return nil, txerror.Internal("chave de viagem sinalizada inválida %q", queryResponse.Key)
+		}
+		// As viagens são lidas antes de contadas, porque o índice das excluídas antes de
+		// DeleteTripData removê-lo continua no estado mundial
+		tripData, err := readFlaggedTrip(ctx, keyParts[1], keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		if tripData == nil {
+			continue
+		}
+		result.Total++
+		if result.Total <= first || len(result.Trips) == flaggedTripsPageSize {
+			continue
+		}
+		result.Trips = append(result.Trips, &FlaggedTrip{Rule: keyParts[0], OperatorID: keyParts[1], TripData: tripData})
+	}
+
+	return result, nil
+}
+
+// flagTripData avalia as regras de anomalia na viagem que vai ser gravada, guarda as
+// regras violadas em Flags e grava o índice de cada uma. Não recusa a viagem.
+func flagTripData(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	rules, err := readAnomalyRules(ctx)
+	if err != nil {
+		return err
+	}
+	tripData.Flags = rules.evaluate(tripData)
+
+	return indexTripFlags(ctx, tripData)
+}
+
+// indexTripFlags grava o índice de cada regra em Flags da viagem
+func indexTripFlags(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	for _, flag := range tripData.Flags {
+		key, err := ctx.GetStub().CreateCompositeKey(tripFlagObjectType, []string{flag.Rule, tripData.OperatorID, tripData.ID})
+		if err != nil {
+			return txerror.Wrap(err, "falha ao criar o índice da viagem sinalizada")
+		}
+		// O valor só marca a existência da chave; o Fabric não grava valores vazios
+		err = ctx.GetStub().PutState(key, []byte{0x00})
+		if err != nil {
+			return txerror.Wrap(err, "falha ao gravar o índice da viagem sinalizada no estado mundial")
+		}
+	}
+
+	return nil
+}
+
+// evaluate retorna as regras ligadas que a viagem viola, na ordem de anomalyRuleNames
+func (r *AnomalyRules) evaluate(tripData *TripData) []TripFlag {
+	var flags []TripFlag
+	flag := func(rule string, format string, args ...interface{}) {
+		if !r.disabled(rule) {
+			flags = append(flags, TripFlag{Rule: rule, Detail: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	km := tripData.TotalDistanceKm
+	departure, departureErr := parseTripDatetime(tripData.DepartureDatetime)
+	arrival, arrivalErr := parseTripDatetime(tripData.ArrivalDatetime)
+	validTimes := departureErr == nil && arrivalErr == nil && !arrival.Before(departure)
+	if !validTimes {
+		flag(RuleInvalidDatetime, "partida %q e chegada %q", tripData.DepartureDatetime, tripData.ArrivalDatetime)
+	}
+	seconds := arrival.Sub(departure).Seconds()
+	if validTimes && (seconds == 0 || seconds < r.MinDurationSeconds) {
+		flag(RuleZeroDuration, "duração de %.0f s, mínimo de %.0f s", seconds, r.MinDurationSeconds)
+	}
+	if km < 0 {
+		flag(RuleNegativeDistance, "distância de %.2f km", km)
+	}
+	if r.MaxDistanceKm > 0 && km > r.MaxDistanceKm {
+		flag(RuleExcessiveDistance, "distância de %.2f km, máximo de %.2f km", km, r.MaxDistanceKm)
+	}
+	if validTimes && seconds > 0 && r.MaxSpeedKmh > 0 {
+		if speed := km / (seconds / 3600); speed > r.MaxSpeedKmh {
+			flag(RuleImplausibleSpeed, "velocidade média de %.1f km/h, máximo de %.1f km/h", speed, r.MaxSpeedKmh)
+		}
+	}
+
+	return flags
+}
+
+// disabled informa se a regra está desligada
+func (r *AnomalyRules) disabled(rule string) bool {
+	for _, disabled := range r.Disabled {
+		if disabled == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unflagTripData remove o índice das regras que a viagem gravada viola, antes de ela ser
+// reavaliada com novos dados ou excluída. Flags não é alterado.
+func unflagTripData(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	for _, flag := range tripData.Flags {
+		key, err := ctx.GetStub().CreateCompositeKey(tripFlagObjectType, []string{flag.Rule, tripData.OperatorID, tripData.ID})
+		if err != nil {
+			return txerror.Wrap(err, "falha ao criar o índice da viagem sinalizada")
+		}
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return txerror.Wrap(err, "falha ao remover o índice da viagem sinalizada do estado mundial")
+		}
+	}
+
+	return nil
+}
+
+// knownAnomalyRule informa se rule é uma das regras de anomalia
+func knownAnomalyRule(rule string) bool {
+	for _, name := range anomalyRuleNames {
+		if name == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readFlaggedTrip retorna a última versão da viagem sinalizada da chave da operadora ou,
+// com a operadora vazia, da chave simples. A viagem é nil se foi excluída.
+func readFlaggedTrip(ctx txcontext.TransactionContextInterface, operatorID string, id string) (*TripData, error) {
+	ref := id
+	if operatorID != "" {
+		ref = operatorID + tripRefSeparator + id
+	}
+	tripData, _, err := readStoredTripData(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if tripData == nil {
+		return nil, txerror.NotFound("os dados de viagem sinalizados %s não existem", ref)
+	}
+	deleted, err := tripDataDeleted(ctx, ref)
+	if err != nil || deleted {
+		return nil, err
+	}
+
+	return latestTripVersion(ctx, tripData)
+}
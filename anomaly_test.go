@@ -0,0 +1,126 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestFlaggedTrips(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "normal", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	createTrip(t, contract, "parada", "2024-03-04T08:00:00Z", "0", "2024-03-04T08:00:00Z")
+	createTrip(t, contract, "longa", "2024-03-04T08:00:00Z", "150", "2024-03-04T13:00:00Z")
+	createTrip(t, contract, "rapida", "2024-03-04T08:00:00Z", "30", "2024-03-04T08:10:00Z")
+	createTrip(t, contract, "sem-data", "ontem", "4", "hoje")
+
+	var page chaincode.FlaggedTripPage
+	evaluate(t, contract, &page, "ListFlaggedTrips", "", "1")
+	flagged := map[string]string{}
+	for _, trip := range page.Trips {
+		flagged[trip.TripData.ID] += trip.Rule + " "
+	}
+	want := map[string]string{
+		"parada":   chaincode.RuleZeroDuration + " ",
+		"longa":    chaincode.RuleExcessiveDistance + " ",
+		"rapida":   chaincode.RuleImplausibleSpeed + " ",
+		"sem-data": chaincode.RuleInvalidDatetime + " ",
+	}
+	if page.Total != len(want) || len(flagged) != len(want) {
+		t.Fatalf("viagens sinalizadas = %v (total %d), quer %v", flagged, page.Total, want)
+	}
+	for id, rules := range want {
+		if flagged[id] != rules {
+			t.Errorf("regras de %s = %q, quer %q", id, flagged[id], rules)
+		}
+	}
+
+	var tripData chaincode.TripData
+	evaluate(t, contract, &tripData, "ReadTripData", "rapida")
+	if len(tripData.Flags) != 1 || tripData.Flags[0].Rule != chaincode.RuleImplausibleSpeed {
+		t.Errorf("Flags de rapida = %+v", tripData.Flags)
+	}
+
+	// A correção reavalia as regras e tira a viagem do índice da regra que deixou de violar
+	submit(t, contract, nil, "UpdateTripData", "rapida", "2024-03-04T08:00:00Z", "3", "1", "2024-03-04T08:10:00Z")
+	var speed chaincode.FlaggedTripPage
+	evaluate(t, contract, &speed, "ListFlaggedTrips", chaincode.RuleImplausibleSpeed, "1")
+	if speed.Total != 0 || len(speed.Trips) != 0 {
+		t.Errorf("viagens com velocidade implausível depois da correção = %+v", speed.Trips)
+	}
+
+	// Regras desligadas não sinalizam as viagens novas
+	var rules chaincode.AnomalyRules
+	evaluate(t, contract, &rules, "GetAnomalyRules")
+	rules.Disabled = []string{chaincode.RuleZeroDuration}
+	submit(t, contract, nil, "AdminContract:SetAnomalyRules", toJSON(t, rules))
+	createTrip(t, contract, "parada2", "2024-03-04T09:00:00Z", "0", "2024-03-04T09:00:00Z")
+	var zero chaincode.FlaggedTripPage
+	evaluate(t, contract, &zero, "ListFlaggedTrips", chaincode.RuleZeroDuration, "1")
+	if zero.Total != 1 || zero.Trips[0].TripData.ID != "parada" {
+		t.Errorf("viagens de duração zero = %+v", zero.Trips)
+	}
+}
+
+func TestAnomalyRulesErrors(t *testing.T) {
+	contract := newContract(t)
+
+	wantError(t, contract, txerror.CodeValidation, "ListFlaggedTrips", "lenta", "1")
+	wantError(t, contract, txerror.CodeValidation, "ListFlaggedTrips", "", "0")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetAnomalyRules", "{")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetAnomalyRules", `{"MaxSpeedKmh":-1}`)
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetAnomalyRules", `{"Disabled":["lenta"]}`)
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "ListFlaggedTrips", "", "1")
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:SetAnomalyRules", `{}`)
+}
+
+// As viagens excluídas saem da revisão até serem restauradas, e a contestação aceita
+// reavalia as regras com a distância corrigida
+func TestFlaggedTripsDeleteAndDispute(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "longa", "2024-03-04T08:00:00Z", "150", "2024-03-04T13:00:00Z")
+	createTrip(t, contract, "longa2", "2024-03-04T08:00:00Z", "120", "2024-03-04T13:00:00Z")
+
+	wantFlagged := func(when string, want ...string) {
+		t.Helper()
+		var page chaincode.FlaggedTripPage
+		evaluate(t, contract, &page, "ListFlaggedTrips", chaincode.RuleExcessiveDistance, "1")
+		var ids []string
+		for _, trip := range page.Trips {
+			ids = append(ids, trip.TripData.ID)
+		}
+		if page.Total != len(want) || strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: viagens sinalizadas = %v (total %d), quer %v", when, ids, page.Total, want)
+		}
+	}
+	wantFlagged("criação", "longa", "longa2")
+
+	submit(t, contract, nil, "DeleteTripData", "longa", "duplicada")
+	wantFlagged("exclusão", "longa2")
+	submit(t, contract, nil, "RestoreTripData", "longa")
+	wantFlagged("restauração", "longa", "longa2")
+
+	submit(t, contract, nil, "OpenDispute", "d1", "longa2", "distância errada", evidenceHash)
+	submit(t, contract, nil, "StartDisputeReview", "d1")
+	submit(t, contract, nil, "AcceptDispute", "d1", "12", "corrigida pelo GPS")
+	wantFlagged("contestação aceita", "longa")
+	var latest chaincode.TripData
+	evaluate(t, contract, &latest, "ReadLatestTripData", "longa2")
+	if len(latest.Flags) != 0 {
+		t.Errorf("Flags da correção = %+v, quer nenhuma", latest.Flags)
+	}
+
+	// A correção que ainda viola a regra continua sinalizada, com o detalhe novo
+	submit(t, contract, nil, "OpenDispute", "d2", "longa", "distância errada", evidenceHash)
+	submit(t, contract, nil, "StartDisputeReview", "d2")
+	submit(t, contract, nil, "AcceptDispute", "d2", "110", "corrigida pelo GPS")
+	var page chaincode.FlaggedTripPage
+	evaluate(t, contract, &page, "ListFlaggedTrips", chaincode.RuleExcessiveDistance, "1")
+	if len(page.Trips) != 1 || page.Trips[0].TripData.TotalDistanceKm != 110 || !strings.Contains(page.Trips[0].TripData.Flags[0].Detail, "110") {
+		t.Errorf("viagens sinalizadas depois da segunda correção = %+v", page.Trips)
+	}
+}
@@ -1,17 +1,149 @@
+//go:generate go run ./cmd/openapigen -out api/openapi.json
+
 package chaincode
 
 import (
         "database/sql"
         "encoding/json"
-        "fmt"
+        "strconv"
+        "strings"
+        "time"
 
         _ "github.com/go-sql-driver/mysql"
         "github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+        "Chaincodemove/config"
+        "Chaincodemove/source"
+        "Chaincodemove/txcontext"
+        "Chaincodemove/txerror"
 )
 
-// MyContract é o contrato inteligente para o Hyperledger Fabric
-type MyContract struct {
+// contractBase reúne o que os contratos do chaincode compartilham: a configuração, o
+// contexto de transação txcontext.TransactionContext e os ganchos BeforeTransaction e
+// UnknownTransaction
+type contractBase struct {
         contractapi.Contract
+        Config *config.Config // DSN do banco e demais parâmetros; carregada do ambiente quando nil
+
+        requiredRole string // papel exigido de quem chama qualquer transação do contrato; vazio libera todas
+        pausable     bool   // se as transações que gravam falham durante a pausa de emergência
+        transactions txcontext.Transactions
+}
+
+func newContractBase(cfg *config.Config, requiredRole string, pausable bool) contractBase {
+        return contractBase{
+                Contract:     contractapi.Contract{TransactionContextHandler: new(txcontext.TransactionContext)},
+                Config:       cfg,
+                requiredRole: requiredRole,
+                pausable:     pausable,
+        }
+}
+
+// configuration retorna a configuração do contrato, carregando-a do ambiente na primeira chamada
+func (cb *contractBase) configuration() (*config.Config, error) {
+        if cb.Config == nil {
+                cfg, err := config.Load()
+                if err != nil {
+                        return nil, txerror.Wrap(err, "falha ao carregar a configuração")
+                }
+                cb.Config = cfg
+        }
+
+        return cb.Config, nil
+}
+
+// GetBeforeTransaction retorna o gancho chamado pelo contractapi antes de cada transação
+func (cb *contractBase) GetBeforeTransaction() interface{} {
+        return cb.beforeTransaction
+}
+
+// beforeTransaction resolve a identidade, o carimbo de data/hora e a configuração no
+// contexto e recusa a transação se quem chama não tiver o papel exigido pelo contrato
+// ou se ela gravar no estado mundial durante a pausa de emergência
+func (cb *contractBase) beforeTransaction(ctx txcontext.TransactionContextInterface) error {
+        cfg, err := cb.configuration()
+        if err != nil {
+                return err
+        }
+        if err := ctx.Resolve(cfg); err != nil {
+                return err
+        }
+        if cb.requiredRole != "" && !cb.bootstrapping(ctx) {
+                if err := requireRole(ctx, cb.requiredRole); err != nil {
+                        return err
+                }
+        }
+        if pause := ctx.Pause(); cb.pausable && pause.Paused && cb.transactions.Mutating(ctx) {
+                return txerror.Unavailable("o chaincode está pausado desde %s (%s); só consultas são aceitas", pause.ChangedAt, pause.Reason)
+        }
+
+        return nil
+}
+
+// bootstrapping informa se a transação é a primeira AdminContract:SetConfig. Antes dela o
+// estado mundial não tem MSPs de operadora e ninguém passa por requireRole; a identidade
+// com o papel de operadora que instala o chaincode grava a lista, que precisa incluir o
+// seu próprio MSP (veja txcontext.SetLedgerConfig).
+func (cb *contractBase) bootstrapping(ctx txcontext.TransactionContextInterface) bool {
+        return len(ctx.Config().OperatorMSPs) == 0 && ctx.Role() == operatorRole && cb.transactions.Called(ctx) == "SetConfig"
+}
+
+// GetUnknownTransaction retorna o gancho chamado pelo contractapi quando a função pedida
+// não existe no contrato
+func (cb *contractBase) GetUnknownTransaction() interface{} {
+        return cb.transactions.Unknown
+}
+
+// TripContract é o contrato dos dados de viagem: criação, leitura, alteração e exclusão,
+// contestações, donos, recompensas e CO2 evitado. É o contrato padrão do chaincode, então
+// as suas transações também podem ser chamadas sem o prefixo "TripContract:".
+type TripContract struct {
+        contractBase
+}
+
+// IngestContract é o contrato que lê as viagens dos bancos das operadoras, ou as recebe
+// delas, e as grava no livro-razão. Transações: "IngestContract:<nome>".
+type IngestContract struct {
+        contractBase
+}
+
+// GetEvaluateTransactions retorna as transações de TripContract que só consultam o
+// estado mundial. Elas continuam aceitas durante a pausa de emergência.
+func (tc *TripContract) GetEvaluateTransactions() []string {
+        return []string{
+                "CalculateTripCO2", "GetAggregates", "GetAllAssets", "GetAllRewards", "GetAllTariffs",
+                "GetAllTripData", "GetAnomalyRules", "GetChargesByRider", "GetCO2Savings", "GetCreditBalance",
+                "GetDisputesByTrip", "GetEmissionFactors", "GetOperatorSummaries", "GetPassCharges",
+                "GetPassesByRider", "GetRedemptionsByRider", "GetReservationsByRider", "GetTripDataByOperator",
+                "GetTripDataHistory", "GetTripDataPage", "GetTripDataVersions", "ListDeletedTrips",
+                "ListFlaggedTrips", "ReadCarbonCertificate", "ReadCharge", "ReadDispute", "ReadLatestTripData",
+                "ReadOperatorTripData", "ReadPass", "ReadReservation", "ReadReward", "ReadSettlement", "ReadTariff",
+                "ReadTripData", "TripDataExists",
+        }
+}
+
+// AdminContract é o contrato das operações de administração: configuração do estado
+// mundial, pausa de emergência, migração do esquema, fatores de emissão e reconstrução dos
+// agregados. Transações: "AdminContract:<nome>"; só a operadora pode chamá-las, e a pausa
+// não se aplica a elas.
+type AdminContract struct {
+        contractBase
+}
+
+// NewContracts cria os contratos do chaincode, na ordem em que devem ser passados a
+// contractapi.NewChaincode; o primeiro, TripContract, é o contrato padrão. Todos
+// compartilham cfg, que pode ser nil para carregar a configuração do ambiente.
+func NewContracts(cfg *config.Config) []contractapi.ContractInterface {
+        trip := &TripContract{contractBase: newContractBase(cfg, "", true)}
+        trip.transactions = txcontext.NewTransactions(trip)
+        batch := &BatchContract{contractBase: newContractBase(cfg, "", true)}
+        batch.transactions = txcontext.NewTransactions(batch)
+        ingest := &IngestContract{contractBase: newContractBase(cfg, "", true)}
+        ingest.transactions = txcontext.NewTransactions(ingest)
+        admin := &AdminContract{contractBase: newContractBase(cfg, operatorRole, false)}
+        admin.transactions = txcontext.NewTransactions(admin)
+
+        return []contractapi.ContractInterface{trip, batch, ingest, admin}
 }
 
 // TripData estrutura para representar os dados de uma viagem
@@ -21,13 +153,21 @@ type TripData struct {
         TotalDistanceKm   float64 `json:"totalDistance_km"`
         TripID            int     `json:"TripID"`
         ArrivalDatetime   string  `json:"Arrival_Datetime"`
+        RiderID           string  `json:"RiderID,omitempty" metadata:",optional"`
+        DepartureSlot     string  `json:"DepartureSlot,omitempty" metadata:",optional"`
+        VehicleID         string  `json:"VehicleID,omitempty" metadata:",optional"`
+        SchemaVersion     int     `json:"SchemaVersion"`
+        OwnerMSP          string  `json:"OwnerMSP,omitempty" metadata:",optional"`
+        OperatorID        string  `json:"OperatorID,omitempty" metadata:",optional"`
+        // Flags são as regras de anomalia violadas quando a viagem foi gravada (veja SetAnomalyRules)
+        Flags []TripFlag `json:"Flags,omitempty" metadata:",optional"`
 }
 
 // GetAllAssets retorna todos os ativos encontrados no estado mundial
-func (mc *MyContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]*TripData, error) {
+func (tc *TripContract) GetAllAssets(ctx txcontext.TransactionContextInterface) ([]*TripData, error) {
         resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
         if err != nil {
-                return nil, fmt.Errorf("falha ao obter ativos: %v", err)
+                return nil, txerror.Wrap(err, "falha ao obter ativos")
         }
         defer resultsIterator.Close()
 
@@ -35,94 +175,65 @@ func (mc *MyContract) GetAllAssets(ctx contractapi.TransactionContextInterface)
         for resultsIterator.HasNext() {
                 queryResponse, err := resultsIterator.Next()
                 if err != nil {
-                        return nil, fmt.Errorf("falha ao iterar sobre resultados de consulta: %v", err)
+                        return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
                 }
 
-                var asset TripData
-                err = json.Unmarshal(queryResponse.Value, &asset)
+                asset, ok, err := plainTripData(queryResponse.Key, queryResponse.Value)
+                if err != nil {
+                        return nil, err
+                }
+                if !ok {
+                        continue
+                }
+                deleted, err := tripDataDeleted(ctx, queryResponse.Key)
                 if err != nil {
-                        return nil, fmt.Errorf("falha ao fazer unmarshal dos dados de viagem: %v", err)
+                        return nil, err
                 }
-                assets = append(assets, &asset)
+                if deleted {
+                        continue
+                }
+                assets = append(assets, asset)
         }
 
         return assets, nil
 }
 
-// InitLedger inicializa o estado mundial com dados de uma consulta SQL
-func (mc *MyContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-        assets := []TripData{
-                {ID: "asset1", DepartureDatetime: "blue", TotalDistanceKm: 5, TripID: 1, ArrivalDatetime: "test"},
-                {ID: "asset2", DepartureDatetime: "red", TotalDistanceKm: 8, TripID: 2, ArrivalDatetime: "sample"},
+// InitLedger inicializa o estado mundial com as viagens da janela configurada lidas do
+// banco, usando o TripID do banco como ID. Cada viagem é gravada como em CreateTripData,
+// com a organização de quem chama como dona; as que já existem ou foram excluídas são
+// puladas. Só a operadora inicializa o estado mundial.
+func (tc *TripContract) InitLedger(ctx txcontext.TransactionContextInterface) error {
+        if err := requireRole(ctx, operatorRole); err != nil {
+                return err
         }
+        cfg := ctx.Config()
+        ownerMSP := ctx.MSPID()
+        // A janela termina no dia da transação no fuso das datas do banco, como em QueryBanco
+        now := ctx.TxTime().In(cfg.Location())
 
-        db, err := sql.Open("mysql", "root:movepass@tcp(192.168.10.24:3306)/moveuff")
+        db, err := sql.Open("mysql", cfg.DatabaseDSN)
         if err != nil {
-                return fmt.Errorf("falha ao conectar ao banco de dados: %v", err)
+                return txerror.Wrap(err, "falha ao conectar ao banco de dados")
         }
         defer db.Close()
 
-        queryToday := `
-        SELECT
-            departure.id AS Departure_Datetime,
-            trips.totalDistance_km,
-            trips.id AS TripID,
-            arrival.id AS Arrival_Datetime
-        FROM trip_x_parkingslot_departures AS departure
-        JOIN trips ON departure.Trips_id = trips.id
-        JOIN trip_x_parkingslot_arrivals AS arrival ON arrival.Trips_id = trips.id
-        WHERE DATE(departure.id) = CURDATE() AND DATE(arrival.id) = CURDATE()
-    `
-
-        rows, err := db.Query(queryToday)
+        rows, err := source.QueryTrips(db, source.LastDaysWindow(now, cfg.QueryWindowDays))
         if err != nil {
-                return fmt.Errorf("falha ao executar a query: %v", err)
-        }
-        defer rows.Close()
-
-        var tripDataList []map[string]interface{}
-
-        for rows.Next() {
-                var departureDatetime string
-                var totalDistanceKm float64
-                var tripID int
-                var arrivalDatetime string
-
-                err := rows.Scan(&departureDatetime, &totalDistanceKm, &tripID, &arrivalDatetime)
-                if err != nil {
-                        return fmt.Errorf("falha ao ler os valores do resultado: %v", err)
-                }
-
-                rowData := map[string]interface{}{
-                        "Departure_Datetime": departureDatetime,
-                        "totalDistance_km":   totalDistanceKm,
-                        "TripID":             tripID,
-                        "Arrival_Datetime":   arrivalDatetime,
-                }
-
-                tripDataList = append(tripDataList, rowData)
-        }
-
-        for i, data := range tripDataList {
-                asset := TripData{
-                        ID:                fmt.Sprintf("asset%d", i+1),
-                        DepartureDatetime: data["Departure_Datetime"].(string),
-                        TotalDistanceKm:   data["totalDistance_km"].(float64),
-                        TripID:            data["TripID"].(int),
-                        ArrivalDatetime:   data["Arrival_Datetime"].(string),
-                }
-                assets = append(assets, asset)
+                return txerror.Wrap(err, "falha ao consultar as viagens")
         }
 
-        for _, asset := range assets {
-                assetJSON, err := json.Marshal(asset)
-                if err != nil {
-                        return fmt.Errorf("falha ao converter ativo para JSON: %v", err)
+        for _, row := range rows {
+                tripData := &TripData{
+                        ID:                strconv.Itoa(row.TripID),
+                        DepartureDatetime: row.DepartureDatetime,
+                        TotalDistanceKm:   row.TotalDistanceKm,
+                        TripID:            row.TripID,
+                        ArrivalDatetime:   row.ArrivalDatetime,
+                        SchemaVersion:     tripSchemaVersion,
+                        OwnerMSP:          ownerMSP,
                 }
-
-                err = ctx.GetStub().PutState(asset.ID, assetJSON)
-                if err != nil {
-                        return fmt.Errorf("falha ao colocar no estado mundial: %v", err)
+                if _, err := putPlainTripData(ctx, tripData); err != nil {
+                        return err
                 }
         }
 
@@ -132,103 +243,214 @@ func (mc *MyContract) InitLedger(ctx contractapi.TransactionContextInterface) er
 // colar
 
 // CreateTripData emite novos dados de viagem para o estado mundial com os detalhes fornecidos.
-func (mc *MyContract) CreateTripData(ctx contractapi.TransactionContextInterface, id string, departureDatetime string, totalDistanceKm float64, tripID int, arrivalDatetime string) error {
-        exists, err := mc.TripDataExists(ctx, id)
+func (tc *TripContract) CreateTripData(ctx txcontext.TransactionContextInterface, id string, departureDatetime string, totalDistanceKm float64, tripID int, arrivalDatetime string) error {
+        if strings.Contains(id, tripRefSeparator) {
+                return txerror.Validation("o ID %q não pode ter %q, que separa a operadora nas referências de viagem", id, tripRefSeparator)
+        }
+        deleted, err := tripDataDeleted(ctx, id)
+        if err != nil {
+                return err
+        }
+        if deleted {
+                return txerror.AlreadyExists("os dados de viagem %s já existem e foram excluídos; use RestoreTripData", id)
+        }
+        exists, err := tc.TripDataExists(ctx, id)
         if err != nil {
-                return fmt.Errorf("falha ao verificar a existência de dados de viagem: %v", err)
+                return txerror.Wrap(err, "falha ao verificar a existência de dados de viagem")
         }
         if exists {
-                return fmt.Errorf("os dados de viagem %s já existem", id)
+                return txerror.AlreadyExists("os dados de viagem %s já existem", id)
         }
 
+        // A organização de quem cria a viagem é a dona dela
+        ownerMSP := ctx.MSPID()
+
         tripData := TripData{
                 ID:                id,
                 DepartureDatetime: departureDatetime,
                 TotalDistanceKm:   totalDistanceKm,
                 TripID:            tripID,
                 ArrivalDatetime:   arrivalDatetime,
+                SchemaVersion:     tripSchemaVersion,
+                OwnerMSP:          ownerMSP,
         }
-        tripDataJSON, err := json.Marshal(tripData)
+        created, err := putPlainTripData(ctx, &tripData)
         if err != nil {
-                return fmt.Errorf("falha ao converter dados de viagem para JSON: %v", err)
+                return err
+        }
+        if !created {
+                return txerror.AlreadyExists("os dados de viagem %s já existem", id)
         }
 
-        return ctx.GetStub().PutState(id, tripDataJSON)
+        return nil
 }
 
-// ReadTripData retorna os dados de viagem armazenados no estado mundial com o ID fornecido.
-func (mc *MyContract) ReadTripData(ctx contractapi.TransactionContextInterface, id string) (*TripData, error) {
-        tripDataJSON, err := ctx.GetStub().GetState(id)
+// putPlainTripData grava os dados de viagem na chave simples, se ainda não existirem nem
+// tiverem sido excluídos, com as regras de anomalia que eles violam, soma-os aos
+// agregados e define a política de endosso da chave. Retorna false se já existiam.
+func putPlainTripData(ctx txcontext.TransactionContextInterface, tripData *TripData) (bool, error) {
+        // Uma viagem excluída continua gravada na chave, então a leitura também a encontra
+        existing, err := ctx.GetStub().GetState(tripData.ID)
+        if err != nil {
+                return false, txerror.Wrap(err, "falha ao ler do estado mundial")
+        }
+        if existing != nil {
+                return false, nil
+        }
+
+        if err := flagTripData(ctx, tripData); err != nil {
+                return false, err
+        }
+        tripDataJSON, err := json.Marshal(tripData)
+        if err != nil {
+                return false, txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
+        }
+
+        err = ctx.GetStub().PutState(tripData.ID, tripDataJSON)
         if err != nil {
-                return nil, fmt.Errorf("falha ao ler do estado mundial: %v", err)
+                return false, txerror.Wrap(err, "falha ao colocar no estado mundial")
         }
-        if tripDataJSON == nil {
-                return nil, fmt.Errorf("os dados de viagem %s não existem", id)
+        if err := aggregateTripData(ctx, tripData); err != nil {
+                return false, err
         }
+        if err := setTripEndorsementPolicy(ctx, tripData.ID, tripData.OwnerMSP); err != nil {
+                return false, err
+        }
+
+        return true, nil
+}
 
-        var tripData TripData
-        err = json.Unmarshal(tripDataJSON, &tripData)
+// ReadTripData retorna os dados de viagem armazenados no estado mundial com o ID fornecido.
+// As viagens gravadas em chave de operadora são lidas pela referência <operadora>:<ID>,
+// aceita também pelas demais transações que recebem o ID de uma viagem.
+func (tc *TripContract) ReadTripData(ctx txcontext.TransactionContextInterface, id string) (*TripData, error) {
+        tripData, _, err := readTripData(ctx, id)
         if err != nil {
-                return nil, fmt.Errorf("falha ao fazer unmarshal dos dados de viagem: %v", err)
+                return nil, err
         }
-	
-        return &tripData, nil
+        if tripData == nil {
+                return nil, txerror.NotFound("os dados de viagem %s não existem", id)
+        }
+
+        return tripData, nil
 }
 
 // UpdateTripData atualiza dados de viagem existentes no estado mundial com os parâmetros fornecidos.
-func (mc *MyContract) UpdateTripData(ctx contractapi.TransactionContextInterface, id string, departureDatetime string, totalDistanceKm float64, tripID int, arrivalDatetime string) error {
-        exists, err := mc.TripDataExists(ctx, id)
+// A distância corrigida precisa estar dentro dos limites da configuração. Viagens já
+// corrigidas por contestações só são corrigidas de novo por outra contestação.
+func (tc *TripContract) UpdateTripData(ctx txcontext.TransactionContextInterface, id string, departureDatetime string, totalDistanceKm float64, tripID int, arrivalDatetime string) error {
+        if err := validateTripDistance(ctx, totalDistanceKm); err != nil {
+                return err
+        }
+        original, key, err := readTripData(ctx, id)
+        if err != nil {
+                return err
+        }
+        if original == nil {
+                return txerror.NotFound("os dados de viagem %s não existem", id)
+        }
+        if err := requireTripOwner(ctx, original); err != nil {
+                return err
+        }
+        // As correções aceitas guardam a viagem inteira, e a última delas prevalece sobre o
+        // registro original nas cobranças, no CO2 e nos agregados: alterar o registro não
+        // teria efeito, então uma nova correção precisa passar por uma contestação
+        amended, _, err := amendedTripVersion(ctx, tripRef(original))
         if err != nil {
-                return fmt.Errorf("falha ao verificar a existência de dados de viagem: %v", err)
+                return err
         }
-        if !exists {
-                return fmt.Errorf("os dados de viagem %s não existem", id)
+        if amended != nil {
+                return txerror.Validation("os dados de viagem %s foram corrigidos por uma contestação aceita; abra uma contestação para corrigi-los de novo", id)
         }
 
-        // Sobrescrever dados de viagem originais com novos dados de viagem
+        // Sobrescrever dados de viagem originais com novos dados de viagem, mantendo o dono
+        // e os campos que a transação não recebe; a política de endosso da chave exige o
+        // peer da organização dona
         tripData := TripData{
-                ID:                id,
+                ID:                original.ID,
                 DepartureDatetime: departureDatetime,
                 TotalDistanceKm:   totalDistanceKm,
                 TripID:            tripID,
                 ArrivalDatetime:   arrivalDatetime,
+                RiderID:           original.RiderID,
+                DepartureSlot:     original.DepartureSlot,
+                VehicleID:         original.VehicleID,
+                SchemaVersion:     tripSchemaVersion,
+                OwnerMSP:          original.OwnerMSP,
+                OperatorID:        original.OperatorID,
+        }
+        // As regras de anomalia são reavaliadas com os novos dados, e o índice das
+        // regras que a viagem deixou de violar é removido
+        if err := unflagTripData(ctx, original); err != nil {
+                return err
+        }
+        if err := flagTripData(ctx, &tripData); err != nil {
+                return err
         }
         tripDataJSON, err := json.Marshal(tripData)
         if err != nil {
-                return fmt.Errorf("falha ao converter dados de viagem para JSON: %v", err)
+                return txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
         }
 
-        return ctx.GetStub().PutState(id, tripDataJSON)
+        err = ctx.GetStub().PutState(key, tripDataJSON)
+        if err != nil {
+                return txerror.Wrap(err, "falha ao colocar no estado mundial")
+        }
+
+        // Os agregados trocam a versão anterior pela nova
+        return updateAggregates(ctx, original, &tripData)
 }
 
-// DeleteTripData exclui dados de viagem fornecidos do estado mundial.
-func (mc *MyContract) DeleteTripData(ctx contractapi.TransactionContextInterface, id string) error {
-        exists, err := mc.TripDataExists(ctx, id)
+// DeleteTripData exclui dados de viagem fornecidos. O registro não é apagado do estado mundial:
+// uma lápide guarda quem excluiu, quando e por quê, e esconde a viagem das consultas.
+func (tc *TripContract) DeleteTripData(ctx txcontext.TransactionContextInterface, id string, reason string) error {
+        if reason == "" {
+                return txerror.Validation("o motivo da exclusão é obrigatório")
+        }
+        tripData, err := tc.ReadTripData(ctx, id)
         if err != nil {
-                return fmt.Errorf("falha ao verificar a existência de dados de viagem: %v", err)
+                return err
         }
-        if !exists {
-                return fmt.Errorf("os dados de viagem %s não existem", id)
+        if err := requireTripOwner(ctx, tripData); err != nil {
+                return err
+        }
+        latest, err := latestTripVersion(ctx, tripData)
+        if err != nil {
+                return err
+        }
+        if err := updateAggregates(ctx, latest, nil); err != nil {
+                return err
+        }
+        // A viagem excluída sai da revisão de anomalias até ser restaurada
+        if err := unflagTripData(ctx, latest); err != nil {
+                return err
         }
 
-        return ctx.GetStub().DelState(id)
+        return putTombstone(ctx, tripData, reason)
 }
 
-// TripDataExists retorna true quando dados de viagem com o ID fornecido existem no estado mundial.
-func (mc *MyContract) TripDataExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-        tripDataJSON, err := ctx.GetStub().GetState(id)
+// TripDataExists retorna true quando dados de viagem com o ID fornecido existem no estado mundial e não foram excluídos.
+func (tc *TripContract) TripDataExists(ctx txcontext.TransactionContextInterface, id string) (bool, error) {
+        tripData, _, err := readTripData(ctx, id)
         if err != nil {
-                return false, fmt.Errorf("falha ao ler do estado mundial: %v", err)
+                return false, err
         }
 
-        return tripDataJSON != nil, nil
+        return tripData != nil, nil
 }
 
 // TransferTripData atualiza o campo tripID dos dados de viagem com o ID fornecido no estado mundial e retorna o antigo trip ID.
-func (mc *MyContract) TransferTripData(ctx contractapi.TransactionContextInterface, id string, newTripID int) (int, error) {
-        tripData, err := mc.ReadTripData(ctx, id)
+// Como as demais alterações da viagem, só a organização dona pode fazê-la.
+func (tc *TripContract) TransferTripData(ctx txcontext.TransactionContextInterface, id string, newTripID int) (int, error) {
+        tripData, key, err := readTripData(ctx, id)
         if err != nil {
-                return 0, fmt.Errorf("falha ao transferir dados de viagem: %v", err)
+                return 0, txerror.Wrap(err, "falha ao transferir dados de viagem")
+        }
+        if tripData == nil {
+                return 0, txerror.NotFound("os dados de viagem %s não existem", id)
+        }
+        if err := requireTripOwner(ctx, tripData); err != nil {
+                return 0, err
         }
 
         oldTripID := tripData.TripID
@@ -236,22 +458,28 @@ func (mc *MyContract) TransferTripData(ctx contractapi.TransactionContextInterfa
 
         tripDataJSON, err := json.Marshal(tripData)
         if err != nil {
-                return 0, fmt.Errorf("falha ao converter dados de viagem para JSON: %v", err)
+                return 0, txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
         }
 
-        err = ctx.GetStub().PutState(id, tripDataJSON)
+        err = ctx.GetStub().PutState(key, tripDataJSON)
         if err != nil {
-                return 0, fmt.Errorf("falha ao transferir dados de viagem para o estado mundial: %v", err)
+                return 0, txerror.Wrap(err, "falha ao transferir dados de viagem para o estado mundial")
         }
 
         return oldTripID, nil
 }
 
-// GetAllTripData retorna todos os dados de viagem encontrados no estado mundial.
-func (mc *MyContract) GetAllTripData(ctx contractapi.TransactionContextInterface) ([]*TripData, error) {
+// GetAllTripData retorna todos os dados de viagem encontrados no estado mundial em chaves
+// simples; as viagens das operadoras são listadas por GetTripDataByOperator.
+func (tc *TripContract) GetAllTripData(ctx txcontext.TransactionContextInterface) ([]*TripData, error) {
+        return readPlainTripData(ctx)
+}
+
+// readPlainTripData retorna os dados de viagem não excluídos gravados em chaves simples
+func readPlainTripData(ctx txcontext.TransactionContextInterface) ([]*TripData, error) {
         resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
         if err != nil {
-                return nil, fmt.Errorf("falha ao obter dados de viagem: %v", err)
+                return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
         }
         defer resultsIterator.Close()
 
@@ -259,16 +487,74 @@ func (mc *MyContract) GetAllTripData(ctx contractapi.TransactionContextInterface
         for resultsIterator.HasNext() {
                 queryResponse, err := resultsIterator.Next()
                 if err != nil {
-                        return nil, fmt.Errorf("falha ao iterar sobre resultados de consulta: %v", err)
+                        return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
                 }
 
-                var tripData TripData
-                err = json.Unmarshal(queryResponse.Value, &tripData)
+                tripData, ok, err := plainTripData(queryResponse.Key, queryResponse.Value)
                 if err != nil {
-                        return nil, fmt.Errorf("falha ao fazer unmarshal dos dados de viagem: %v", err)
+                        return nil, err
                 }
-                tripDataList = append(tripDataList, &tripData)
+                if !ok {
+                        continue
+                }
+                // Viagens excluídas continuam no estado mundial, mas não aparecem nas consultas
+                deleted, err := tripDataDeleted(ctx, queryResponse.Key)
+                if err != nil {
+                        return nil, err
+                }
+                if deleted {
+                        continue
+                }
+                tripDataList = append(tripDataList, tripData)
         }
 
         return tripDataList, nil
 }
+
+// TripDataHistoryEntry representa uma versão dos dados de viagem no histórico do livro-razão
+type TripDataHistoryEntry struct {
+        TxID      string    `json:"TxID"`
+        Timestamp string    `json:"Timestamp"`
+        IsDelete  bool      `json:"IsDelete"`
+        Value     *TripData `json:"Value,omitempty" metadata:",optional"`
+}
+
+// GetTripDataHistory retorna todas as versões gravadas dos dados de viagem com o ID fornecido, da mais recente para a mais antiga.
+func (tc *TripContract) GetTripDataHistory(ctx txcontext.TransactionContextInterface, id string) ([]*TripDataHistoryEntry, error) {
+        key, err := tripKey(ctx, id)
+        if err != nil {
+                return nil, err
+        }
+        resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+        if err != nil {
+                return nil, txerror.Wrap(err, "falha ao obter o histórico dos dados de viagem")
+        }
+        defer resultsIterator.Close()
+
+        history := []*TripDataHistoryEntry{}
+        for resultsIterator.HasNext() {
+                modification, err := resultsIterator.Next()
+                if err != nil {
+                        return nil, txerror.Wrap(err, "falha ao iterar sobre o histórico")
+                }
+
+                entry := &TripDataHistoryEntry{
+                        TxID:      modification.TxId,
+                        Timestamp: modification.Timestamp.AsTime().UTC().Format(time.RFC3339),
+                        IsDelete:  modification.IsDelete,
+                }
+                if !modification.IsDelete {
+                        tripData, err := unmarshalTripData(id, modification.Value)
+                        if err != nil {
+                                return nil, err
+                        }
+                        entry.Value = tripData
+                }
+                history = append(history, entry)
+        }
+        if len(history) == 0 {
+                return nil, txerror.NotFound("os dados de viagem %s não existem", id)
+        }
+
+        return history, nil
+}
@@ -0,0 +1,14 @@
+// Package backend define como as ferramentas cliente executam transações do contrato.
+// O pacote gateway implementa Backend sobre uma rede Fabric e o pacote mock sobre uma
+// rede simulada dentro do próprio processo.
+package backend
+
+// Backend executa transações do contrato e devolve o resultado bruto (em geral JSON)
+type Backend interface {
+	// Submit endossa e confirma uma transação que altera o livro-razão
+	Submit(name string, args ...string) ([]byte, error)
+	// Evaluate executa uma consulta sem confirmar nada no livro-razão
+	Evaluate(name string, args ...string) ([]byte, error)
+	// Close libera as conexões abertas
+	Close() error
+}
@@ -0,0 +1,133 @@
+// Package gateway executa as transações do contrato numa rede Fabric através do Fabric Gateway.
+package gateway
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	gatewaypb "github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"Chaincodemove/config"
+)
+
+// Backend implementa backend.Backend sobre uma conexão com o Fabric Gateway
+type Backend struct {
+	conn     *grpc.ClientConn
+	gateway  *client.Gateway
+	contract *client.Contract
+}
+
+// New conecta ao peer configurado com a identidade configurada
+func New(cfg *config.Config) (*Backend, error) {
+	if err := cfg.ValidateGateway(); err != nil {
+		return nil, err
+	}
+
+	tlsCertPEM, err := os.ReadFile(cfg.GatewayTLSCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler o certificado TLS do peer: %v", err)
+	}
+	tlsCert, err := identity.CertificateFromPEM(tlsCertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificado TLS do peer inválido: %v", err)
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(tlsCert)
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, cfg.GatewayHostOverride)
+
+	certPEM, err := os.ReadFile(cfg.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler o certificado do usuário: %v", err)
+	}
+	cert, err := identity.CertificateFromPEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificado do usuário inválido: %v", err)
+	}
+	id, err := identity.NewX509Identity(cfg.MSPID, cert)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao criar a identidade: %v", err)
+	}
+
+	keyPEM, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler a chave privada do usuário: %v", err)
+	}
+	privateKey, err := identity.PrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("chave privada do usuário inválida: %v", err)
+	}
+	sign, err := identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao criar o assinador: %v", err)
+	}
+
+	conn, err := grpc.Dial(cfg.GatewayEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("falha ao conectar ao peer %s: %v", cfg.GatewayEndpoint, err)
+	}
+
+	gateway, err := client.Connect(
+		id,
+		client.WithSign(sign),
+		client.WithClientConnection(conn),
+		client.WithEvaluateTimeout(5*time.Second),
+		client.WithEndorseTimeout(15*time.Second),
+		client.WithSubmitTimeout(5*time.Second),
+		client.WithCommitStatusTimeout(1*time.Minute),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("falha ao conectar ao gateway: %v", err)
+	}
+
+	return &Backend{
+		conn:     conn,
+		gateway:  gateway,
+		contract: gateway.GetNetwork(cfg.Channel).GetContract(cfg.ChaincodeName),
+	}, nil
+}
+
+// Submit endossa e confirma a transação e espera o commit no livro-razão
+func (b *Backend) Submit(name string, args ...string) ([]byte, error) {
+	result, err := b.contract.SubmitTransaction(name, args...)
+	return result, withDetails(err)
+}
+
+// Evaluate executa a consulta num peer sem confirmar a transação
+func (b *Backend) Evaluate(name string, args ...string) ([]byte, error) {
+	result, err := b.contract.EvaluateTransaction(name, args...)
+	return result, withDetails(err)
+}
+
+// withDetails acrescenta ao erro as mensagens devolvidas pelos peers. O Gateway só as
+// entrega nos detalhes do status gRPC, e é nelas que vem o código de txerror.
+func withDetails(err error) error {
+	if err == nil {
+		return nil
+	}
+	var messages []string
+	for _, detail := range status.Convert(err).Details() {
+		if errorDetail, ok := detail.(*gatewaypb.ErrorDetail); ok {
+			messages = append(messages, fmt.Sprintf("%s (%s): %s", errorDetail.GetAddress(), errorDetail.GetMspId(), errorDetail.GetMessage()))
+		}
+	}
+	if len(messages) == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s", err, strings.Join(messages, "; "))
+}
+
+// Close fecha o gateway e a conexão gRPC
+func (b *Backend) Close() error {
+	b.gateway.Close()
+	return b.conn.Close()
+}
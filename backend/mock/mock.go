@@ -0,0 +1,470 @@
+// Package mock executa o contrato dentro do próprio processo, sobre um estado mundial em memória.
+//
+// O shim do chaincode usa fabric-protos-go e o Fabric Gateway usa fabric-protos-go-apiv2;
+// as duas registram as mesmas mensagens protobuf e não podem ser ligadas no mesmo binário.
+// Por isso este pacote fica separado de backend e os comandos só o incluem com -tags mock.
+package mock
+
+import (
+	"container/list"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/config"
+)
+
+// Backend implementa backend.Backend sobre o contrato em memória.
+// Serve para testes locais sem peer, orderer nem banco de dados.
+type Backend struct {
+	stub  *mockStub
+	mspID string
+}
+
+// New cria a rede simulada. As transações são assinadas por uma
+// identidade autoassinada do MSP informado.
+func New(mspID string) (*Backend, error) {
+	cc, err := contractapi.NewChaincode(chaincode.NewContracts(nil)...)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao criar o chaincode: %v", err)
+	}
+	creator, err := mockCreator(mspID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := &mockStub{MockStub: shimtest.NewMockStub("Chaincodemove", cc), cc: cc}
+	stub.Creator = creator
+
+	return &Backend{stub: stub, mspID: mspID}, nil
+}
+
+// Bootstrap grava a primeira configuração do estado mundial, como a organização que
+// instala o chaincode faria com AdminContract:SetConfig: os valores do ambiente, com o MSP
+// da rede simulada como o único MSP de operadora. A identidade das próximas transações
+// não muda.
+func (b *Backend) Bootstrap() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	ledger := cfg.Ledger()
+	ledger.OperatorMSPs = []string{b.mspID}
+	ledgerJSON, err := json.Marshal(ledger)
+	if err != nil {
+		return fmt.Errorf("falha ao serializar a configuração: %v", err)
+	}
+
+	creator := b.stub.Creator
+	defer func() { b.stub.Creator = creator }()
+	if err := b.SetIdentity(b.mspID, OperatorAttributes); err != nil {
+		return err
+	}
+	_, err = b.Submit("AdminContract:SetConfig", string(ledgerJSON))
+	return err
+}
+
+// OperatorAttributes são os atributos da identidade da operadora, que pode chamar todas
+// as transações. Os comandos compilados com -tags mock a usam quando -mock-attrs não é
+// informado.
+var OperatorAttributes = map[string]string{"role": "operator"}
+
+// ParseAttributes lê atributos de identidade no formato nome=valor,nome=valor, como os
+// de -mock-attrs
+func ParseAttributes(value string) (map[string]string, error) {
+	attributes := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		name, attributeValue, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("atributo inválido %q: use nome=valor", pair)
+		}
+		attributes[name] = attributeValue
+	}
+	return attributes, nil
+}
+
+// SetIdentity troca a identidade que assina as próximas transações. Os atributos
+// são gravados no certificado como os da Fabric CA e lidos pelo contrato com
+// GetClientIdentity().GetAttributeValue, por exemplo role=operator.
+func (b *Backend) SetIdentity(mspID string, attributes map[string]string) error {
+	creator, err := mockCreator(mspID, attributes)
+	if err != nil {
+		return err
+	}
+	b.stub.Creator = creator
+	return nil
+}
+
+// Submit executa a transação e mantém as alterações no estado em memória. Se a transação
+// falhar, as alterações que ela fez são descartadas, como o peer faria.
+func (b *Backend) Submit(name string, args ...string) ([]byte, error) {
+	state, history := b.snapshot()
+	payload, err := b.invoke(name, args)
+	if err != nil {
+		b.restore(state)
+		b.stub.history = history
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// Evaluate executa a transação e descarta as alterações que ela tenha feito no estado
+func (b *Backend) Evaluate(name string, args ...string) ([]byte, error) {
+	state, history := b.snapshot()
+	defer func() {
+		b.restore(state)
+		b.stub.history = history
+	}()
+
+	return b.invoke(name, args)
+}
+
+// Close não tem conexões a fechar
+func (b *Backend) Close() error {
+	return nil
+}
+
+// LoadState substitui o estado em memória pelo estado salvo em path por SaveState.
+// Um arquivo inexistente equivale a um estado vazio.
+func (b *Backend) LoadState(path string) error {
+	stateJSON, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao ler o estado simulado: %v", err)
+	}
+
+	state := map[string][]byte{}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		return fmt.Errorf("falha ao deserializar o estado simulado: %v", err)
+	}
+	b.restore(state)
+
+	return nil
+}
+
+// SaveState grava o estado em memória em path, para ser recarregado por LoadState
+func (b *Backend) SaveState(path string) error {
+	stateJSON, err := json.MarshalIndent(b.stub.State, "", "  ")
+	if err != nil {
+		return fmt.Errorf("falha ao serializar o estado simulado: %v", err)
+	}
+	if err := os.WriteFile(path, stateJSON, 0o600); err != nil {
+		return fmt.Errorf("falha ao gravar o estado simulado: %v", err)
+	}
+
+	return nil
+}
+
+func (b *Backend) invoke(name string, args []string) ([]byte, error) {
+	invokeArgs := [][]byte{[]byte(name)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := b.stub.invoke(fmt.Sprintf("mock-%d", time.Now().UnixNano()), invokeArgs)
+	if response.Status != shim.OK {
+		return nil, errors.New(response.Message)
+	}
+
+	return response.Payload, nil
+}
+
+// snapshot copia o estado em memória e o histórico das chaves, para restaurá-los depois
+func (b *Backend) snapshot() (map[string][]byte, map[string][]*queryresult.KeyModification) {
+	state := make(map[string][]byte, len(b.stub.State))
+	for key, value := range b.stub.State {
+		state[key] = value
+	}
+	history := make(map[string][]*queryresult.KeyModification, len(b.stub.history))
+	for key, modifications := range b.stub.history {
+		history[key] = modifications[:len(modifications):len(modifications)]
+	}
+
+	return state, history
+}
+
+// restore troca o estado em memória, mantendo a lista ordenada de chaves usada pelas consultas por faixa
+func (b *Backend) restore(state map[string][]byte) {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.stub.State = state
+	b.stub.Keys = list.New()
+	for _, key := range keys {
+		b.stub.Keys.PushBack(key)
+	}
+}
+
+// mockStub corrige diferenças entre o MockStub e um peer de verdade que afetam o contrato:
+// consultas por faixa aberta ("", "") não devolvem chaves compostas, o histórico das chaves
+// é registrado, as consultas paginadas por chave composta funcionam, e os argumentos ficam acessíveis para que o chaincode seja invocado com
+// este stub, e não com o MockStub.
+type mockStub struct {
+	*shimtest.MockStub
+	cc      shim.Chaincode
+	args    [][]byte
+	history map[string][]*queryresult.KeyModification
+}
+
+func (s *mockStub) invoke(txID string, args [][]byte) peer.Response {
+	s.args = args
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+
+	return s.cc.Invoke(s)
+}
+
+// GetArgs retorna os argumentos da invocação atual
+func (s *mockStub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs retorna os argumentos da invocação atual como strings
+func (s *mockStub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// GetFunctionAndParameters separa o nome da transação dos parâmetros
+func (s *mockStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetArgsSlice retorna os argumentos da invocação atual concatenados
+func (s *mockStub) GetArgsSlice() ([]byte, error) {
+	var slice []byte
+	for _, arg := range s.args {
+		slice = append(slice, arg...)
+	}
+	return slice, nil
+}
+
+// PutState grava o valor e registra a alteração no histórico da chave
+func (s *mockStub) PutState(key string, value []byte) error {
+	if err := s.MockStub.PutState(key, value); err != nil {
+		return err
+	}
+	s.record(key, value, false)
+	return nil
+}
+
+// DelState apaga a chave e registra a exclusão no histórico
+func (s *mockStub) DelState(key string) error {
+	if err := s.MockStub.DelState(key); err != nil {
+		return err
+	}
+	s.record(key, nil, true)
+	return nil
+}
+
+// GetHistoryForKey devolve as alterações da chave, da mais recente para a mais antiga, como o peer
+func (s *mockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.history[key]
+	reversed := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		reversed[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: reversed}, nil
+}
+
+func (s *mockStub) record(key string, value []byte, isDelete bool) {
+	if s.history == nil {
+		s.history = map[string][]*queryresult.KeyModification{}
+	}
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{
+		TxId:      s.TxID,
+		Value:     value,
+		Timestamp: s.TxTimestamp,
+		IsDelete:  isDelete,
+	})
+}
+
+// historyIterator percorre o histórico registrado de uma chave
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.modifications) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errors.New("não há mais resultados")
+	}
+	next := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return next, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+// GetStateByRange ignora as chaves compostas nas consultas por faixa aberta, como faz o peer
+func (s *mockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	// No peer, endKey vazio significa intervalo aberto; o MockStub só trata assim
+	// quando startKey também é vazio
+	if endKey == "" && startKey != "" {
+		endKey = string(utf8.MaxRune)
+	}
+	iterator, err := s.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &simpleKeyIterator{StateQueryIteratorInterface: iterator}, nil
+}
+
+// simpleKeyIterator pula as chaves do espaço de chaves compostas (iniciadas por 0x00)
+type simpleKeyIterator struct {
+	shim.StateQueryIteratorInterface
+	next *queryresult.KV
+}
+
+func (it *simpleKeyIterator) HasNext() bool {
+	for it.next == nil && it.StateQueryIteratorInterface.HasNext() {
+		kv, err := it.StateQueryIteratorInterface.Next()
+		if err != nil {
+			return false
+		}
+		if !strings.HasPrefix(kv.Key, "\x00") {
+			it.next = kv
+		}
+	}
+	return it.next != nil
+}
+
+func (it *simpleKeyIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("não há mais resultados")
+	}
+	next := it.next
+	it.next = nil
+	return next, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination devolve até pageSize chaves compostas a
+// partir de bookmark, que o MockStub não implementa. Como no peer com LevelDB, o marcador
+// é a chave do próximo resultado, vazio quando não há mais.
+func (s *mockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iterator, err := s.MockStub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &sliceIterator{}
+	metadata := &peer.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if pageSize > 0 && int32(len(page.kvs)) == pageSize {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+
+	return page, metadata, nil
+}
+
+// sliceIterator percorre os resultados já lidos de uma página
+type sliceIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *sliceIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *sliceIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("não há mais resultados")
+	}
+	next := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return next, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// attributesOID é a extensão onde a Fabric CA grava os atributos da identidade
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// mockCreator gera um certificado autoassinado e o serializa como a identidade do criador da transação
+func mockCreator(mspID string, attributes map[string]string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao gerar a chave da identidade simulada: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "movectl-mock", Organization: []string{mspID}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if len(attributes) > 0 {
+		attributesJSON, err := json.Marshal(map[string]map[string]string{"attrs": attributes})
+		if err != nil {
+			return nil, fmt.Errorf("falha ao serializar os atributos da identidade simulada: %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributesOID, Value: attributesJSON}}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao gerar o certificado da identidade simulada: %v", err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("falha ao serializar a identidade simulada: %v", err)
+	}
+
+	return creator, nil
+}
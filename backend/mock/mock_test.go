@@ -0,0 +1,239 @@
+package mock
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// partialWriteChaincode grava a chave pedida e, se o segundo argumento for "fail", falha depois de gravar
+type partialWriteChaincode struct{}
+
+func (partialWriteChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+func (partialWriteChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if err := stub.PutState(args[0], []byte("valor")); err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(args) > 1 && args[1] == "fail" {
+		return shim.Error("falha depois de gravar")
+	}
+	return shim.Success(nil)
+}
+
+func newPartialWriteBackend() *Backend {
+	cc := partialWriteChaincode{}
+	return &Backend{stub: &mockStub{MockStub: shimtest.NewMockStub("test", cc), cc: cc}}
+}
+
+func TestSubmitKeepsWritesOfSuccessfulTransactions(t *testing.T) {
+	b := newPartialWriteBackend()
+
+	if _, err := b.Submit("Put", "a"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if value := b.stub.State["a"]; string(value) != "valor" {
+		t.Fatalf("a = %q, quer %q", value, "valor")
+	}
+}
+
+func TestSubmitDiscardsWritesOfFailedTransactions(t *testing.T) {
+	b := newPartialWriteBackend()
+	if _, err := b.Submit("Put", "a"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	if _, err := b.Submit("Put", "b", "fail"); err == nil {
+		t.Fatal("Submit não falhou")
+	}
+	if _, ok := b.stub.State["b"]; ok {
+		t.Error("a escrita da transação que falhou ficou no estado")
+	}
+	if _, ok := b.stub.history["b"]; ok {
+		t.Error("a escrita da transação que falhou ficou no histórico")
+	}
+	if _, ok := b.stub.State["a"]; !ok {
+		t.Error("a escrita da transação anterior foi descartada")
+	}
+	if b.stub.Keys.Len() != 1 {
+		t.Errorf("%d chaves ordenadas, quer 1", b.stub.Keys.Len())
+	}
+}
+
+func TestEvaluateDiscardsWrites(t *testing.T) {
+	b := newPartialWriteBackend()
+
+	if _, err := b.Evaluate("Put", "a"); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if _, ok := b.stub.State["a"]; ok {
+		t.Error("a escrita da consulta ficou no estado")
+	}
+	if _, ok := b.stub.history["a"]; ok {
+		t.Error("a escrita da consulta ficou no histórico")
+	}
+}
+
+func TestSaveStateAndLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	b := newPartialWriteBackend()
+	if _, err := b.Submit("Put", "a"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := b.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	loaded := newPartialWriteBackend()
+	if err := loaded.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if value := loaded.stub.State["a"]; string(value) != "valor" {
+		t.Errorf("a = %q, quer %q", value, "valor")
+	}
+
+	if err := newPartialWriteBackend().LoadState(filepath.Join(t.TempDir(), "inexistente.json")); err != nil {
+		t.Errorf("LoadState de arquivo inexistente: %v", err)
+	}
+}
+
+func TestOpenRangeQuerySkipsCompositeKeys(t *testing.T) {
+	b := newPartialWriteBackend()
+	b.stub.MockTransactionStart("tx")
+	if err := b.stub.PutState("simples", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	key, err := b.stub.CreateCompositeKey("tipo", []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.stub.PutState(key, []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	b.stub.MockTransactionEnd("tx")
+
+	iterator, err := b.stub.GetStateByRange("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, kv.Key)
+	}
+	if len(keys) != 1 || keys[0] != "simples" {
+		t.Errorf("chaves = %q, quer [simples]", keys)
+	}
+}
+
+func TestPartialCompositeKeyPagination(t *testing.T) {
+	b := newPartialWriteBackend()
+	b.stub.MockTransactionStart("tx")
+	var keys []string
+	for _, id := range []string{"a", "b", "c"} {
+		key, err := b.stub.CreateCompositeKey("tipo", []string{id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.stub.PutState(key, []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	b.stub.MockTransactionEnd("tx")
+
+	// O marcador é a chave do próximo resultado, e fica vazio na última página
+	bookmark := ""
+	for _, want := range []struct {
+		values   string
+		bookmark string
+	}{{"ab", keys[2]}, {"c", ""}} {
+		iterator, metadata, err := b.stub.GetStateByPartialCompositeKeyWithPagination("tipo", []string{}, 2, bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := ""
+		for iterator.HasNext() {
+			kv, err := iterator.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			values += string(kv.Value)
+		}
+		if values != want.values || metadata.Bookmark != want.bookmark || int(metadata.FetchedRecordsCount) != len(want.values) {
+			t.Errorf("página = %q, %q, %d; quer %q, %q", values, metadata.Bookmark, metadata.FetchedRecordsCount, want.values, want.bookmark)
+		}
+		bookmark = metadata.Bookmark
+	}
+}
+
+func TestNewRegistersEveryContract(t *testing.T) {
+	b, err := New("Org1MSP")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	blocks, err := b.Evaluate("BatchContract:GetBlocks")
+	if err != nil {
+		t.Fatalf("BatchContract:GetBlocks: %v", err)
+	}
+	if string(blocks) != `{"blocks":[]}` {
+		t.Errorf("GetBlocks = %s", blocks)
+	}
+
+	// Bootstrap grava a configuração como operadora, mas não troca a identidade
+	if err := b.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if _, err := b.Evaluate("AdminContract:GetConfig"); err == nil || !strings.Contains(err.Error(), "UNAUTHORIZED") {
+		t.Errorf("AdminContract:GetConfig sem o papel de operadora: %v", err)
+	}
+
+	if err := b.SetIdentity("Org1MSP", map[string]string{"role": "operator"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"TripContract:GetAllTripData", "AdminContract:GetConfig"} {
+		if _, err := b.Evaluate(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := b.Evaluate("IngestContract:GetSourceAnchor", "tx"); err == nil || !strings.Contains(err.Error(), "a âncora da transação tx não existe") {
+		t.Errorf("IngestContract:GetSourceAnchor: %v", err)
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+		ok    bool
+	}{
+		{"role=operator", map[string]string{"role": "operator"}, true},
+		{"role=operator, riderID=r1", map[string]string{"role": "operator", "riderID": "r1"}, true},
+		{"role=", map[string]string{"role": ""}, true},
+		{"role", nil, false},
+		{"=operator", nil, false},
+		{"role=operator,", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseAttributes(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseAttributes(%q): %v", tt.value, err)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAttributes(%q) = %v, quer %v", tt.value, got, tt.want)
+		}
+	}
+}
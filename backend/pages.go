@@ -0,0 +1,36 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// TripPageSize é quantas viagens cada chamada de GetTripDataPage traz
+const TripPageSize = 200
+
+// ForEachTripPage lê as viagens do livro-razão página a página com GetTripDataPage e
+// chama fn com a lista JSON de cada página, sem guardar as páginas já lidas
+func ForEachTripPage(b Backend, fn func(trips []byte) error) error {
+	bookmark := ""
+	for {
+		result, err := b.Evaluate("GetTripDataPage", bookmark, strconv.Itoa(TripPageSize))
+		if err != nil {
+			return fmt.Errorf("falha na transação GetTripDataPage: %v", err)
+		}
+		var page struct {
+			Trips    json.RawMessage `json:"Trips"`
+			Bookmark string          `json:"Bookmark"`
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
+			return fmt.Errorf("falha ao ler a página de viagens: %v", err)
+		}
+		if err := fn(page.Trips); err != nil {
+			return err
+		}
+		if page.Bookmark == "" {
+			return nil
+		}
+		bookmark = page.Bookmark
+	}
+}
@@ -0,0 +1,468 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipo de objeto dos certificados de carbono e chave da tabela de fatores de emissão na
+// configuração (txcontext.ConfigObjectType)
+const (
+	carbonCertificateObjectType = "carbon-certificate"
+	emissionFactorsConfigKey    = "emission-factors"
+)
+
+// Agrupamentos aceitos por GetCO2Savings
+const (
+	groupByDay   = "day"
+	groupByRider = "rider"
+	groupBySlot  = "slot"
+)
+
+// unknownGroup agrupa viagens sem data válida, ciclista ou vaga registrados
+const unknownGroup = "desconhecido"
+
+// tripDatetimeLayout é o formato em que o MySQL devolve as colunas DATETIME
+const tripDatetimeLayout = "2006-01-02 15:04:05"
+
+// EmissionFactorTable guarda os fatores de emissão (kg de CO2 por km) de cada modal.
+// O CO2 evitado por uma viagem é a distância vezes a diferença entre o fator do
+// modal substituído (BaselineMode) e o fator do modal usado na viagem (TripMode).
+type EmissionFactorTable struct {
+	Factors      map[string]float64 `json:"Factors"`
+	BaselineMode string             `json:"BaselineMode"`
+	TripMode     string             `json:"TripMode"`
+}
+
+// TripCO2 representa o CO2 evitado por uma viagem
+type TripCO2 struct {
+	ID           string  `json:"ID"`
+	DistanceKm   float64 `json:"DistanceKm"`
+	CO2AvoidedKg float64 `json:"CO2AvoidedKg"`
+}
+
+// CO2Aggregate soma o CO2 evitado das viagens de um grupo (dia, ciclista ou vaga)
+type CO2Aggregate struct {
+	Key          string  `json:"Key"`
+	Trips        int     `json:"Trips"`
+	DistanceKm   float64 `json:"DistanceKm"`
+	CO2AvoidedKg float64 `json:"CO2AvoidedKg"`
+}
+
+// TripHash liga uma viagem ao hash SHA-256 do registro gravado no estado mundial
+type TripHash struct {
+	ID   string `json:"ID"`
+	Hash string `json:"Hash"`
+}
+
+// CarbonCertificate congela o CO2 evitado num período junto com os hashes das viagens que o compõem
+type CarbonCertificate struct {
+	Period       string              `json:"Period"`
+	Trips        int                 `json:"Trips"`
+	DistanceKm   float64             `json:"DistanceKm"`
+	CO2AvoidedKg float64             `json:"CO2AvoidedKg"`
+	Factors      EmissionFactorTable `json:"Factors"`
+	TripHashes   []TripHash          `json:"TripHashes"`
+	Hash         string              `json:"Hash"`
+	IssuedAt     string              `json:"IssuedAt"`
+}
+
+// defaultEmissionFactors é usada enquanto nenhuma tabela for gravada com SetEmissionFactors
+func defaultEmissionFactors() EmissionFactorTable {
+	return EmissionFactorTable{
+		Factors: map[string]float64{
+			"car":  0.192,
+			"bus":  0.105,
+			"bike": 0,
+		},
+		BaselineMode: "car",
+		TripMode:     "bike",
+	}
+}
+
+// kgPerKm retorna quantos kg de CO2 cada km percorrido evita
+func (t EmissionFactorTable) kgPerKm() float64 {
+	return t.Factors[t.BaselineMode] - t.Factors[t.TripMode]
+}
+
+// SetEmissionFactors grava a tabela de fatores de emissão fornecida em JSON.
+// Como toda transação de AdminContract, só a operadora pode chamá-la.
+func (ac *AdminContract) SetEmissionFactors(ctx txcontext.TransactionContextInterface, tableJSON string) error {
+	var table EmissionFactorTable
+	err := json.Unmarshal([]byte(tableJSON), &table)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao fazer unmarshal da tabela de fatores de emissão")
+	}
+	for mode, factor := range table.Factors {
+		if factor < 0 {
+			return txerror.Validation("o fator de emissão do modal %s não pode ser negativo", mode)
+		}
+	}
+	if _, ok := table.Factors[table.BaselineMode]; !ok {
+		return txerror.Validation("o modal de referência %q não está na tabela", table.BaselineMode)
+	}
+	if _, ok := table.Factors[table.TripMode]; !ok {
+		return txerror.Validation("o modal da viagem %q não está na tabela", table.TripMode)
+	}
+	if table.kgPerKm() < 0 {
+		return txerror.Validation("o modal %s emite mais que o modal de referência %s", table.TripMode, table.BaselineMode)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(txcontext.ConfigObjectType, []string{emissionFactorsConfigKey})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da tabela de fatores")
+	}
+	storedJSON, err := json.Marshal(table)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter tabela de fatores para JSON")
+	}
+	err = ctx.GetStub().PutState(key, storedJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a tabela de fatores no estado mundial")
+	}
+
+	return nil
+}
+
+// GetEmissionFactors retorna a tabela de fatores de emissão em uso.
+func (tc *TripContract) GetEmissionFactors(ctx txcontext.TransactionContextInterface) (*EmissionFactorTable, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(txcontext.ConfigObjectType, []string{emissionFactorsConfigKey})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da tabela de fatores")
+	}
+	tableJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+
+	table := defaultEmissionFactors()
+	if tableJSON == nil {
+		return &table, nil
+	}
+	err = json.Unmarshal(tableJSON, &table)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da tabela de fatores de emissão")
+	}
+
+	return &table, nil
+}
+
+// CalculateTripCO2 retorna o CO2 evitado pela viagem com o ID fornecido, pela distância
+// da última correção aceita numa contestação, se houver.
+func (tc *TripContract) CalculateTripCO2(ctx txcontext.TransactionContextInterface, id string) (*TripCO2, error) {
+	tripData, err := tc.ReadLatestTripData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	table, err := tc.GetEmissionFactors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TripCO2{
+		ID:           tripRef(tripData),
+		DistanceKm:   tripData.TotalDistanceKm,
+		CO2AvoidedKg: tripData.TotalDistanceKm * table.kgPerKm(),
+	}, nil
+}
+
+// GetCO2Savings retorna o CO2 evitado agregado por dia, ciclista ou vaga de partida, sobre
+// as viagens das chaves simples e das operadoras. As viagens corrigidas por contestações
+// entram pela última correção aceita.
+func (tc *TripContract) GetCO2Savings(ctx txcontext.TransactionContextInterface, groupBy string) ([]*CO2Aggregate, error) {
+	if groupBy != groupByDay && groupBy != groupByRider && groupBy != groupBySlot {
+		return nil, txerror.Validation("agrupamento inválido %q: use %s, %s ou %s", groupBy, groupByDay, groupByRider, groupBySlot)
+	}
+
+	table, err := tc.GetEmissionFactors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := map[string]*CO2Aggregate{}
+	var latestErr error
+	err = forEachTrip(ctx, func(tripData *TripData) {
+		if latestErr != nil {
+			return
+		}
+		tripData, latestErr = latestTripVersion(ctx, tripData)
+		if latestErr != nil {
+			return
+		}
+		key := unknownGroup
+		switch groupBy {
+		case groupByDay:
+			if departure, err := parseTripDatetime(tripData.DepartureDatetime); err == nil {
+				key = departure.Format("2006-01-02")
+			}
+		case groupByRider:
+			if tripData.RiderID != "" {
+				key = tripData.RiderID
+			}
+		case groupBySlot:
+			if tripData.DepartureSlot != "" {
+				key = tripData.DepartureSlot
+			}
+		}
+
+		aggregate, ok := aggregates[key]
+		if !ok {
+			aggregate = &CO2Aggregate{Key: key}
+			aggregates[key] = aggregate
+		}
+		aggregate.Trips++
+		aggregate.DistanceKm += tripData.TotalDistanceKm
+		aggregate.CO2AvoidedKg += tripData.TotalDistanceKm * table.kgPerKm()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if latestErr != nil {
+		return nil, latestErr
+	}
+
+	result := make([]*CO2Aggregate, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		result = append(result, aggregate)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+
+	return result, nil
+}
+
+// IssueCarbonCertificate congela o CO2 evitado pelas viagens (das chaves simples e das
+// operadoras) que partiram no período fornecido (AAAA, AAAA-MM ou AAAA-MM-DD). Cada
+// viagem só entra num certificado: o período não pode se sobrepor ao de um certificado
+// já emitido (2024-03 se sobrepõe a 2024 e a 2024-03-05). Só a operadora emite certificados.
+func (tc *TripContract) IssueCarbonCertificate(ctx txcontext.TransactionContextInterface, period string) (*CarbonCertificate, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(carbonCertificateObjectType, []string{period})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do certificado")
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("o certificado de carbono do período %s já foi emitido", period)
+	}
+	overlapping, err := overlappingCertificate(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping != "" {
+		return nil, txerror.AlreadyExists("o período %s se sobrepõe ao do certificado de carbono %s, já emitido", period, overlapping)
+	}
+
+	table, err := tc.GetEmissionFactors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuedAt := ctx.TxTime()
+
+	certificate := CarbonCertificate{
+		Period:     period,
+		Factors:    *table,
+		TripHashes: []TripHash{},
+		IssuedAt:   issuedAt.Format(time.RFC3339),
+	}
+
+	// O hash de cada viagem é calculado sobre os bytes gravados, e não sobre a struct
+	// re-serializada, para que qualquer alteração posterior no registro seja detectável.
+	// As viagens corrigidas por contestações entram pela última correção aceita, e o hash
+	// é o do registro dessa versão.
+	addTrip := func(ref string, id string, tripDataJSON []byte) error {
+		tripData, err := unmarshalTripData(id, tripDataJSON)
+		if err != nil {
+			return err
+		}
+		departure, err := parseTripDatetime(tripData.DepartureDatetime)
+		if err != nil || !strings.HasPrefix(departure.Format("2006-01-02"), period) {
+			return nil
+		}
+		deleted, err := tripDataDeleted(ctx, ref)
+		if err != nil || deleted {
+			return err
+		}
+		amended, versionJSON, err := amendedTripVersion(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if amended != nil {
+			tripData, tripDataJSON = amended, versionJSON
+		}
+
+		tripHash := sha256.Sum256(tripDataJSON)
+		certificate.TripHashes = append(certificate.TripHashes, TripHash{
+			ID:   ref,
+			Hash: hex.EncodeToString(tripHash[:]),
+		})
+		certificate.Trips++
+		certificate.DistanceKm += tripData.TotalDistanceKm
+		certificate.CO2AvoidedKg += tripData.TotalDistanceKm * table.kgPerKm()
+		return nil
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, ok, err := plainTripData(queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if err := addTrip(queryResponse.Key, queryResponse.Key, queryResponse.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	operatorIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+	}
+	defer operatorIterator.Close()
+	for operatorIterator.HasNext() {
+		queryResponse, err := operatorIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return nil, txerror.Internal("chave de dados de viagem inválida %q", queryResponse.Key)
+		}
+		if err := addTrip(keyParts[0]+tripRefSeparator+keyParts[1], keyParts[1], queryResponse.Value); err != nil {
+			return nil, err
+		}
+	}
+	if certificate.Trips == 0 {
+		return nil, txerror.NotFound("nenhuma viagem encontrada no período %s", period)
+	}
+
+	// As consultas já devolvem as chaves ordenadas, então o hash do certificado é determinístico
+	hasher := sha256.New()
+	for _, tripHash := range certificate.TripHashes {
+		hasher.Write([]byte(tripHash.ID))
+		hasher.Write([]byte(tripHash.Hash))
+	}
+	certificate.Hash = hex.EncodeToString(hasher.Sum(nil))
+
+	certificateJSON, err := json.Marshal(certificate)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter certificado para JSON")
+	}
+	err = ctx.GetStub().PutState(key, certificateJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar o certificado no estado mundial")
+	}
+
+	return &certificate, nil
+}
+
+// ReadCarbonCertificate retorna o certificado de carbono emitido para o período fornecido.
+func (tc *TripContract) ReadCarbonCertificate(ctx txcontext.TransactionContextInterface, period string) (*CarbonCertificate, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(carbonCertificateObjectType, []string{period})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do certificado")
+	}
+	certificateJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if certificateJSON == nil {
+		return nil, txerror.NotFound("o certificado de carbono do período %s não existe", period)
+	}
+
+	var certificate CarbonCertificate
+	err = json.Unmarshal(certificateJSON, &certificate)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal do certificado")
+	}
+
+	return &certificate, nil
+}
+
+// overlappingCertificate retorna o período de um certificado já emitido que se sobrepõe
+// ao período fornecido, ou vazio se não houver. Dois períodos se sobrepõem quando um é
+// prefixo do outro.
+func overlappingCertificate(ctx txcontext.TransactionContextInterface, period string) (string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(carbonCertificateObjectType, []string{})
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao obter os certificados de carbono")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return "", txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 1 {
+			return "", txerror.Internal("chave de certificado de carbono inválida %q", queryResponse.Key)
+		}
+		if strings.HasPrefix(period, keyParts[0]) || strings.HasPrefix(keyParts[0], period) {
+			return keyParts[0], nil
+		}
+	}
+
+	return "", nil
+}
+
+// validatePeriod aceita um ano (AAAA), um mês (AAAA-MM) ou um dia (AAAA-MM-DD)
+func validatePeriod(period string) error {
+	for _, layout := range []string{"2006", "2006-01", "2006-01-02"} {
+		if len(period) == len(layout) {
+			if _, err := time.Parse(layout, period); err == nil {
+				return nil
+			}
+		}
+	}
+
+	return txerror.Validation("período inválido %q: use AAAA, AAAA-MM ou AAAA-MM-DD", period)
+}
+
+// parseTripDatetime interpreta as datas de partida e chegada das viagens,
+// que vêm do MySQL como "AAAA-MM-DD hh:mm:ss" ou foram gravadas em RFC3339.
+// As datas sem fuso ficam em UTC com a hora do relógio, que é o que importa para
+// períodos e faixas de horário; para compará-las com o carimbo de data/hora da
+// transação, use parseTripDatetimeIn.
+func parseTripDatetime(value string) (time.Time, error) {
+	return parseTripDatetimeIn(value, time.UTC)
+}
+
+// parseTripDatetimeIn interpreta as datas de viagem como parseTripDatetime, mas lê as
+// datas sem fuso no fuso fornecido
+func parseTripDatetimeIn(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(tripDatetimeLayout, value, location); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, txerror.Validation("data de viagem inválida %q", value)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,115 @@
+package chaincode_test
+
+import (
+	"math"
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestIssueCarbonCertificate(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "10", "2024-03-04T08:40:00Z")
+	createTrip(t, contract, "t2", "2024-04-01T08:00:00Z", "3", "2024-04-01T08:20:00Z")
+	submit(t, contract, nil, "CreateOperatorTripData", "opa", "900", "2024-03-05 08:00:00", "5", "900", "2024-03-05 08:20:00")
+
+	var certificate chaincode.CarbonCertificate
+	submit(t, contract, &certificate, "IssueCarbonCertificate", "2024-03")
+	if certificate.Trips != 2 || certificate.DistanceKm != 15 || certificate.Hash == "" {
+		t.Errorf("certificado = %+v", certificate)
+	}
+	if math.Abs(certificate.CO2AvoidedKg-15*0.192) > 1e-9 {
+		t.Errorf("CO2 evitado = %v, quer %v", certificate.CO2AvoidedKg, 15*0.192)
+	}
+	if len(certificate.TripHashes) != 2 || certificate.TripHashes[0].ID != "t1" || certificate.TripHashes[1].ID != "opa:900" {
+		t.Errorf("hashes = %+v", certificate.TripHashes)
+	}
+
+	var read chaincode.CarbonCertificate
+	evaluate(t, contract, &read, "ReadCarbonCertificate", "2024-03")
+	if read.Hash != certificate.Hash {
+		t.Errorf("hash lido = %s, quer %s", read.Hash, certificate.Hash)
+	}
+}
+
+func TestIssueCarbonCertificateErrors(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "10", "2024-03-04T08:40:00Z")
+	submit(t, contract, nil, "IssueCarbonCertificate", "2024-03")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "IssueCarbonCertificate", "2024-03")
+	wantError(t, contract, txerror.CodeAlreadyExists, "IssueCarbonCertificate", "2024")
+	wantError(t, contract, txerror.CodeAlreadyExists, "IssueCarbonCertificate", "2024-03-04")
+	wantError(t, contract, txerror.CodeNotFound, "IssueCarbonCertificate", "2025")
+	wantError(t, contract, txerror.CodeValidation, "IssueCarbonCertificate", "março")
+	wantError(t, contract, txerror.CodeNotFound, "ReadCarbonCertificate", "2025")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "IssueCarbonCertificate", "2025")
+}
+
+func TestCO2Savings(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "AdminContract:SetEmissionFactors", `{"Factors":{"car":0.2,"bike":0},"BaselineMode":"car","TripMode":"bike"}`)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "10", "2024-03-04T08:40:00Z")
+	createTrip(t, contract, "t2", "2024-03-04T09:00:00Z", "5", "2024-03-04T09:20:00Z")
+	createTrip(t, contract, "t3", "2024-03-05T08:00:00Z", "1", "2024-03-05T08:10:00Z")
+
+	var trip chaincode.TripCO2
+	evaluate(t, contract, &trip, "CalculateTripCO2", "t1")
+	if math.Abs(trip.CO2AvoidedKg-2) > 1e-9 {
+		t.Errorf("CO2 de t1 = %v, quer 2", trip.CO2AvoidedKg)
+	}
+
+	var aggregates []*chaincode.CO2Aggregate
+	evaluate(t, contract, &aggregates, "GetCO2Savings", "day")
+	if len(aggregates) != 2 || aggregates[0].Key != "2024-03-04" || aggregates[0].Trips != 2 || aggregates[1].Trips != 1 {
+		t.Fatalf("agregados = %+v", aggregates)
+	}
+	if math.Abs(aggregates[0].CO2AvoidedKg-3) > 1e-9 {
+		t.Errorf("CO2 de 2024-03-04 = %v, quer 3", aggregates[0].CO2AvoidedKg)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "GetCO2Savings", "semana")
+	wantError(t, contract, txerror.CodeNotFound, "CalculateTripCO2", "t9")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetEmissionFactors", `{"Factors":{"car":0.2,"bike":0.3},"BaselineMode":"car","TripMode":"bike"}`)
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:SetEmissionFactors", `{"Factors":{"car":0.2},"BaselineMode":"car","TripMode":"bike"}`)
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:SetEmissionFactors", `{"Factors":{"car":0.2,"bike":0},"BaselineMode":"car","TripMode":"bike"}`)
+}
+
+// As viagens corrigidas por contestações entram no CO2 evitado pela distância corrigida
+func TestCO2UsesAcceptedDisputes(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "10", "2024-03-04T08:40:00Z")
+	submit(t, contract, nil, "CreateOperatorTripData", "opa", "900", "2024-03-05 08:00:00", "5", "900", "2024-03-05 08:20:00")
+	for _, amendment := range []struct{ dispute, trip, distanceKm string }{{"d1", "t1", "4"}, {"d2", "opa:900", "1"}} {
+		submit(t, contract, nil, "OpenDispute", amendment.dispute, amendment.trip, "distância errada", evidenceHash)
+		submit(t, contract, nil, "StartDisputeReview", amendment.dispute)
+		submit(t, contract, nil, "AcceptDispute", amendment.dispute, amendment.distanceKm, "corrigida pelo GPS")
+	}
+
+	var trip chaincode.TripCO2
+	evaluate(t, contract, &trip, "CalculateTripCO2", "t1")
+	if trip.DistanceKm != 4 {
+		t.Errorf("distância de t1 = %v, quer 4", trip.DistanceKm)
+	}
+	var aggregates []*chaincode.CO2Aggregate
+	evaluate(t, contract, &aggregates, "GetCO2Savings", "day")
+	if len(aggregates) != 2 || aggregates[0].DistanceKm != 4 || aggregates[1].DistanceKm != 1 {
+		t.Errorf("agregados = %+v", aggregates)
+	}
+
+	var certificate chaincode.CarbonCertificate
+	submit(t, contract, &certificate, "IssueCarbonCertificate", "2024-03")
+	if certificate.Trips != 2 || certificate.DistanceKm != 5 {
+		t.Errorf("certificado = %+v", certificate)
+	}
+	if math.Abs(certificate.CO2AvoidedKg-5*0.192) > 1e-9 {
+		t.Errorf("CO2 evitado = %v, quer %v", certificate.CO2AvoidedKg, 5*0.192)
+	}
+}
@@ -0,0 +1,28 @@
+#!/bin/bash
+
+# Empacota o Chaincodemove como chaincode como serviço (ccaas).
+# O pacote contém apenas connection.json e metadata.json: o peer não compila
+# nada e conecta no servidor do chaincode que roda no nosso contêiner.
+#
+# Uso: ./ccaas/package.sh [endereço] [label]
+#   endereço  host:porta do servidor do chaincode (padrão: chaincodemove:9999)
+#   label     label do pacote (padrão: chaincodemove_1.0)
+
+set -e
+
+DIR=$(cd "$(dirname "$0")" && pwd)
+ADDRESS=${1:-chaincodemove:9999}
+LABEL=${2:-chaincodemove_1.0}
+WORK=$(mktemp -d)
+trap 'rm -rf "$WORK"' EXIT
+
+# Ajustar o endereço e o label nos arquivos do pacote
+sed "s|\"address\": \".*\"|\"address\": \"$ADDRESS\"|" "$DIR/connection.json" > "$WORK/connection.json"
+sed "s|\"label\": \".*\"|\"label\": \"$LABEL\"|" "$DIR/metadata.json" > "$WORK/metadata.json"
+
+# Montar o pacote no formato esperado por "peer lifecycle chaincode install"
+tar -C "$WORK" -czf "$WORK/code.tar.gz" connection.json
+tar -C "$WORK" -czf "$LABEL.tar.gz" code.tar.gz metadata.json
+
+echo "Pacote $LABEL.tar.gz criado para o servidor em $ADDRESS"
+echo "Após instalar, exporte CHAINCODE_ID com o ID devolvido pelo peer e MOVEUFF_CHAINCODE_MODE=server"
@@ -0,0 +1,70 @@
+// Command chaincode é o programa do chaincode: registra os contratos do pacote Chaincodemove
+// e atende o peer, lançado por ele ou, com MOVEUFF_CHAINCODE_MODE=server, como chaincode
+// como serviço.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/config"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Erro ao carregar a configuração: %v", err)
+	}
+
+	Chaincodemove, err := contractapi.NewChaincode(chaincode.NewContracts(cfg)...)
+	if err != nil {
+		log.Fatalf("Erro ao criar o chaincode: %v", err)
+	}
+
+	if cfg.ChaincodeMode == config.ChaincodeModeServer {
+		// Chaincode como serviço: o peer conecta neste processo, que roda no nosso próprio contêiner
+		server, err := newChaincodeServer(cfg, Chaincodemove)
+		if err != nil {
+			log.Fatalf("Erro ao configurar o servidor do chaincode: %v", err)
+		}
+		if err := server.Start(); err != nil {
+			log.Fatalf("Erro ao iniciar o servidor do chaincode: %v", err)
+		}
+		return
+	}
+
+	if err := Chaincodemove.Start(); err != nil {
+		log.Fatalf("Erro ao iniciar o chaincode: %v", err)
+	}
+}
+
+// newChaincodeServer monta o servidor externo do chaincode, lendo o material TLS configurado
+func newChaincodeServer(cfg *config.Config, cc shim.Chaincode) (*shim.ChaincodeServer, error) {
+	tlsProps := shim.TLSProperties{Disabled: cfg.TLSKeyFile == ""}
+	if !tlsProps.Disabled {
+		var err error
+		if tlsProps.Key, err = os.ReadFile(cfg.TLSKeyFile); err != nil {
+			return nil, fmt.Errorf("falha ao ler a chave TLS: %v", err)
+		}
+		if tlsProps.Cert, err = os.ReadFile(cfg.TLSCertFile); err != nil {
+			return nil, fmt.Errorf("falha ao ler o certificado TLS: %v", err)
+		}
+		if cfg.TLSClientCACertFile != "" {
+			if tlsProps.ClientCACerts, err = os.ReadFile(cfg.TLSClientCACertFile); err != nil {
+				return nil, fmt.Errorf("falha ao ler o certificado da CA dos clientes: %v", err)
+			}
+		}
+	}
+
+	return &shim.ChaincodeServer{
+		CCID:     cfg.ChaincodeID,
+		Address:  cfg.ChaincodeAddress,
+		CC:       cc,
+		TLSProps: tlsProps,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Chaincodemove/config"
+)
+
+func TestNewChaincodeServerWithoutTLS(t *testing.T) {
+	cfg := &config.Config{ChaincodeID: "moveuff:1", ChaincodeAddress: "0.0.0.0:9999"}
+
+	server, err := newChaincodeServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("newChaincodeServer: %v", err)
+	}
+	if !server.TLSProps.Disabled || server.CCID != cfg.ChaincodeID || server.Address != cfg.ChaincodeAddress {
+		t.Errorf("servidor = %+v", server)
+	}
+}
+
+func TestNewChaincodeServerWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"key.pem": "chave", "cert.pem": "certificado", "ca.pem": "ca"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{
+		ChaincodeID:         "moveuff:1",
+		ChaincodeAddress:    "0.0.0.0:9999",
+		TLSKeyFile:          filepath.Join(dir, "key.pem"),
+		TLSCertFile:         filepath.Join(dir, "cert.pem"),
+		TLSClientCACertFile: filepath.Join(dir, "ca.pem"),
+	}
+
+	server, err := newChaincodeServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("newChaincodeServer: %v", err)
+	}
+	tlsProps := server.TLSProps
+	if tlsProps.Disabled || string(tlsProps.Key) != "chave" || string(tlsProps.Cert) != "certificado" || string(tlsProps.ClientCACerts) != "ca" {
+		t.Errorf("TLS = %+v", tlsProps)
+	}
+
+	// Sem a CA dos clientes, o servidor não pede o certificado do peer
+	cfg.TLSClientCACertFile = ""
+	if server, err = newChaincodeServer(cfg, nil); err != nil {
+		t.Fatalf("newChaincodeServer: %v", err)
+	}
+	if server.TLSProps.ClientCACerts != nil {
+		t.Errorf("CA dos clientes = %q", server.TLSProps.ClientCACerts)
+	}
+}
+
+func TestNewChaincodeServerMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "key.pem"), []byte("chave"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("certificado"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "inexistente.pem")
+
+	tests := []struct {
+		key, cert, ca string
+		want          string
+	}{
+		{missing, "cert.pem", "", "falha ao ler a chave TLS"},
+		{"key.pem", missing, "", "falha ao ler o certificado TLS"},
+		{"key.pem", "cert.pem", missing, "falha ao ler o certificado da CA dos clientes"},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{
+			TLSKeyFile:  filepath.Join(dir, filepath.Base(tt.key)),
+			TLSCertFile: filepath.Join(dir, filepath.Base(tt.cert)),
+		}
+		if tt.ca != "" {
+			cfg.TLSClientCACertFile = tt.ca
+		}
+		if _, err := newChaincodeServer(cfg, nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("newChaincodeServer(%q, %q, %q): %v, quer %q", tt.key, tt.cert, tt.ca, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !mock
+
+package main
+
+import (
+	"errors"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/backend/gateway"
+	"Chaincodemove/config"
+)
+
+// newBackend conecta ao Fabric Gateway configurado
+func newBackend(cfg *config.Config, mockState string, mockAttrs string) (backend.Backend, func(), error) {
+	if mockState != "" {
+		return nil, nil, errors.New("-mock-state exige um binário compilado com -tags mock")
+	}
+	if mockAttrs != "" {
+		return nil, nil, errors.New("-mock-attrs exige um binário compilado com -tags mock")
+	}
+
+	contract, err := gateway.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return contract, func() {}, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"Chaincodemove/backend"
+)
+
+// blockSummary resume um bloco fechado. Hash é o SHA-256 da blockchain até este bloco,
+// o mesmo valor que FecharBloco imprime ao fechá-lo.
+type blockSummary struct {
+	Block        int    `json:"Block"`
+	Transactions int    `json:"Transactions"`
+	Hash         string `json:"Hash"`
+}
+
+// runBlock trata os subcomandos "block list" e "block verify [hash]"
+func runBlock(contract backend.Backend, args []string, output string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+	case args[0] == "verify" && len(args) <= 2:
+	default:
+		return errUsage
+	}
+
+	result, err := contract.Evaluate("BatchContract:GetBlocks")
+	if err != nil {
+		return fmt.Errorf("falha na transação GetBlocks: %v", err)
+	}
+	// GetBlocks devolve uma string JSON contendo a blockchain gravada
+	var blockchainJSON string
+	if err := json.Unmarshal(result, &blockchainJSON); err != nil {
+		blockchainJSON = string(result)
+	}
+	summaries, err := summarizeBlocks([]byte(blockchainJSON))
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "list" || len(args) == 1 {
+		summariesJSON, err := json.Marshal(summaries)
+		if err != nil {
+			return fmt.Errorf("falha ao serializar os blocos: %v", err)
+		}
+		return printResult(out, output, summariesJSON)
+	}
+
+	for _, summary := range summaries {
+		if summary.Hash == args[1] {
+			fmt.Fprintf(out, "O hash confere com a blockchain até o bloco %d\n", summary.Block)
+			return nil
+		}
+	}
+	return fmt.Errorf("o hash %s não corresponde a nenhum bloco gravado", args[1])
+}
+
+// summarizeBlocks recalcula o hash acumulado de cada bloco. FecharBloco calcula o hash
+// sobre json.Marshal da blockchain logo após acrescentar o bloco; os blocos são mantidos
+// como JSON bruto para reproduzir exatamente os mesmos bytes.
+func summarizeBlocks(blockchainJSON []byte) ([]blockSummary, error) {
+	var blockchain struct {
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	if err := json.Unmarshal(blockchainJSON, &blockchain); err != nil {
+		return nil, fmt.Errorf("falha ao deserializar os blocos: %v", err)
+	}
+
+	summaries := []blockSummary{}
+	for i, blockJSON := range blockchain.Blocks {
+		var block struct {
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+		if err := json.Unmarshal(blockJSON, &block); err != nil {
+			return nil, fmt.Errorf("falha ao deserializar o bloco %d: %v", i+1, err)
+		}
+
+		prefix := bytes.Join(rawMessages(blockchain.Blocks[:i+1]), []byte(","))
+		hash := sha256.Sum256([]byte(`{"blocks":[` + string(prefix) + `]}`))
+		summaries = append(summaries, blockSummary{
+			Block:        i + 1,
+			Transactions: len(block.Transactions),
+			Hash:         hex.EncodeToString(hash[:]),
+		})
+	}
+
+	return summaries, nil
+}
+
+func rawMessages(messages []json.RawMessage) [][]byte {
+	result := make([][]byte, len(messages))
+	for i, message := range messages {
+		result[i] = message
+	}
+	return result
+}
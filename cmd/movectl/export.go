@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/export"
+	"Chaincodemove/source"
+)
+
+// runExport trata "export trips", "export summaries" e "export settlement". As opções
+// do subcomando vêm depois do nome, por exemplo: export trips -format geojson -slots slots.csv.
+func runExport(contract backend.Backend, args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "settlement" {
+		return runExportSettlement(contract, args[1:], out)
+	}
+	if len(args) == 0 || (args[0] != "trips" && args[0] != "summaries") {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("export "+args[0], flag.ContinueOnError)
+	format := flags.String("format", export.FormatCSV, "formato: csv, jsonl ou geojson (geojson só para viagens)")
+	from := flags.String("from", "", "data inicial (AAAA-MM-DD) da partida das viagens")
+	to := flags.String("to", "", "data final (AAAA-MM-DD) da partida das viagens")
+	slotsFile := flags.String("slots", "", "CSV slot,latitude,longitude com as coordenadas dos slots (obrigatório em geojson)")
+	outFile := flags.String("out", "", "arquivo de saída (padrão: saída padrão)")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	window := source.Window{From: *from, To: *to}
+	if *from != "" || *to != "" {
+		if err := window.Validate(); err != nil {
+			return err
+		}
+	}
+
+	var slots export.SlotLocations
+	if *slotsFile != "" {
+		file, err := os.Open(*slotsFile)
+		if err != nil {
+			return fmt.Errorf("falha ao abrir as coordenadas dos slots: %v", err)
+		}
+		slots, err = export.LoadSlotLocations(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if *outFile != "" {
+		file, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("falha ao criar %s: %v", *outFile, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if args[0] == "summaries" {
+		summarizer := export.NewSummarizer()
+		err := forEachTripPage(contract, window, func(trip export.Trip) error {
+			summarizer.Add(trip)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return export.WriteSummaries(out, *format, summarizer.Summaries())
+	}
+
+	writer, err := export.NewTripWriter(out, *format, slots)
+	if err != nil {
+		return err
+	}
+	if err := forEachTripPage(contract, window, writer.WriteTrip); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// forEachTripPage lê as viagens do livro-razão página a página e chama fn para cada
+// viagem dentro da janela
+func forEachTripPage(contract backend.Backend, window source.Window, fn func(export.Trip) error) error {
+	return backend.ForEachTripPage(contract, func(trips []byte) error {
+		return export.DecodeTrips(bytes.NewReader(trips), window, fn)
+	})
+}
+
+// runExportSettlement trata "export settlement <período>": exporta o demonstrativo final,
+// isto é, o acerto do período depois de aprovado por todas as organizações.
+func runExportSettlement(contract backend.Backend, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	period := args[0]
+
+	flags := flag.NewFlagSet("export settlement", flag.ContinueOnError)
+	format := flags.String("format", export.FormatCSV, "formato: csv ou json")
+	outFile := flags.String("out", "", "arquivo de saída (padrão: saída padrão)")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	result, err := contract.Evaluate("ReadSettlement", period)
+	if err != nil {
+		return fmt.Errorf("falha na transação ReadSettlement: %v", err)
+	}
+	var settlement export.Settlement
+	if err := json.Unmarshal(result, &settlement); err != nil {
+		return fmt.Errorf("falha ao ler o acerto: %v", err)
+	}
+	if settlement.Status != export.SettlementApproved {
+		return fmt.Errorf("o acerto do período %s está %s; só o acerto aprovado por todas as organizações pode ser exportado", period, settlement.Status)
+	}
+
+	if *outFile != "" {
+		file, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("falha ao criar %s: %v", *outFile, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return export.WriteSettlement(out, *format, settlement)
+}
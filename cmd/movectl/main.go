@@ -0,0 +1,202 @@
+// Command movectl invoca as transações do contrato de viagens sem que o operador
+// precise montar à mão o JSON de "peer chaincode invoke".
+//
+// Uso:
+//
+//	movectl [opções] trip create <id> <partida> <distânciaKm> <tripID> <chegada>
+//	movectl [opções] trip read <id>
+//	movectl [opções] trip update <id> <partida> <distânciaKm> <tripID> <chegada>
+//	movectl [opções] trip delete <id> <motivo>
+//	movectl [opções] trip transfer <id> <novoTripID>
+//	movectl [opções] trip owner <id> <novoMSP>
+//	movectl [opções] trip deleted
+//	movectl [opções] trip restore <id>
+//	movectl [opções] trip list
+//	movectl [opções] block list
+//	movectl [opções] block verify [hash]
+//	movectl [opções] ingest
+//	movectl [opções] operator trips <operadora>
+//	movectl [opções] operator summary
+//	movectl [opções] operator ingest <operadora>
+//	movectl [opções] admin config
+//	movectl [opções] admin set-config <json>
+//	movectl [opções] admin pause <motivo>
+//	movectl [opções] admin resume
+//	movectl [opções] admin status
+//	movectl [opções] settlement generate <período>
+//	movectl [opções] settlement read <período>
+//	movectl [opções] settlement approve <período>
+//	movectl [opções] settlement dispute <período> <motivo>
+//	movectl [opções] export trips [-format csv|jsonl|geojson] [-from data] [-to data] [-slots arquivo] [-out arquivo]
+//	movectl [opções] export summaries [-format csv|jsonl] [-from data] [-to data] [-out arquivo]
+//	movectl [opções] export settlement <período> [-format csv|json] [-out arquivo]
+//
+// movectl conecta ao Fabric Gateway configurado no ambiente (veja .env.example).
+// Compilado com -tags mock, ele executa o contrato numa rede simulada dentro do
+// próprio processo, para testes locais; -mock-state guarda o estado simulado entre
+// execuções. Os dois modos não cabem no mesmo binário (veja o pacote backend/mock).
+//
+//	go build -tags mock ./cmd/movectl
+//	movectl -mock-state estado.json trip list
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"Chaincodemove/config"
+)
+
+// errUsage indica argumentos inválidos; o uso é impresso e o código de saída é 2
+var errUsage = errors.New("uso inválido")
+
+// command descreve um subcomando: a transação que ele chama e quantos argumentos recebe
+type command struct {
+	usage       string
+	transaction string
+	args        int
+	submit      bool
+}
+
+var commands = map[string]map[string]command{
+	"trip": {
+		"create":   {usage: "<id> <partida> <distânciaKm> <tripID> <chegada>", transaction: "CreateTripData", args: 5, submit: true},
+		"read":     {usage: "<id>", transaction: "ReadTripData", args: 1},
+		"update":   {usage: "<id> <partida> <distânciaKm> <tripID> <chegada>", transaction: "UpdateTripData", args: 5, submit: true},
+		"delete":   {usage: "<id> <motivo>", transaction: "DeleteTripData", args: 2, submit: true},
+		"transfer": {usage: "<id> <novoTripID>", transaction: "TransferTripData", args: 2, submit: true},
+		"list":     {transaction: "GetAllTripData"},
+		"owner":    {usage: "<id> <novoMSP>", transaction: "TransferTripOwnership", args: 2, submit: true},
+		"deleted":  {transaction: "ListDeletedTrips"},
+		"restore":  {usage: "<id>", transaction: "RestoreTripData", args: 1, submit: true},
+	},
+	"ingest": {
+		"": {transaction: "IngestContract:QueryBanco", submit: true},
+	},
+	"operator": {
+		"trips":   {usage: "<operadora>", transaction: "GetTripDataByOperator", args: 1},
+		"summary": {transaction: "GetOperatorSummaries"},
+		"ingest":  {usage: "<operadora>", transaction: "IngestContract:IngestOperatorTrips", args: 1, submit: true},
+	},
+	"admin": {
+		"config":     {transaction: "AdminContract:GetConfig"},
+		"set-config": {usage: "<json>", transaction: "AdminContract:SetConfig", args: 1, submit: true},
+		"pause":      {usage: "<motivo>", transaction: "AdminContract:Pause", args: 1, submit: true},
+		"resume":     {transaction: "AdminContract:Resume", submit: true},
+		"status":     {transaction: "AdminContract:GetPauseState"},
+	},
+	"settlement": {
+		"generate": {usage: "<período>", transaction: "GenerateSettlement", args: 1, submit: true},
+		"read":     {usage: "<período>", transaction: "ReadSettlement", args: 1},
+		"approve":  {usage: "<período>", transaction: "ApproveSettlement", args: 1, submit: true},
+		"dispute":  {usage: "<período> <motivo>", transaction: "DisputeSettlement", args: 2, submit: true},
+	},
+}
+
+func main() {
+	flags := flag.NewFlagSet("movectl", flag.ContinueOnError)
+	mockState := flags.String("mock-state", "", "arquivo onde o estado da rede simulada é lido e gravado (só com -tags mock)")
+	mockAttrs := flags.String("mock-attrs", "", "atributos da identidade da rede simulada, como riderID=r1 (só com -tags mock; padrão: role=operator)")
+	output := flags.String("output", "table", "formato da saída: table ou json")
+	envFile := flags.String("env", "", "arquivo .env com a configuração (padrão: ./.env, se existir)")
+	flags.Usage = func() { usage(flags) }
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	err := run(flags.Args(), *mockState, *mockAttrs, *output, *envFile, os.Stdout)
+	if errors.Is(err, errUsage) {
+		usage(flags)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, mockState string, mockAttrs string, output string, envFile string, out io.Writer) error {
+	if len(args) == 0 || (output != outputTable && output != outputJSON) {
+		return errUsage
+	}
+
+	var cfg *config.Config
+	var err error
+	if envFile != "" {
+		cfg, err = config.Load(envFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return err
+	}
+
+	contract, save, err := newBackend(cfg, mockState, mockAttrs)
+	if err != nil {
+		return err
+	}
+	defer save()
+	defer contract.Close()
+
+	if args[0] == "block" {
+		return runBlock(contract, args[1:], output, out)
+	}
+	if args[0] == "export" {
+		return runExport(contract, args[1:], out)
+	}
+
+	group, ok := commands[args[0]]
+	if !ok {
+		return errUsage
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	cmd, ok := group[name]
+	if !ok {
+		return errUsage
+	}
+	cmdArgs := args[1:]
+	if name != "" {
+		cmdArgs = args[2:]
+	}
+	if len(cmdArgs) != cmd.args {
+		return errUsage
+	}
+
+	var result []byte
+	if cmd.submit {
+		result, err = contract.Submit(cmd.transaction, cmdArgs...)
+	} else {
+		result, err = contract.Evaluate(cmd.transaction, cmdArgs...)
+	}
+	if err != nil {
+		return fmt.Errorf("falha na transação %s: %v", cmd.transaction, err)
+	}
+
+	return printResult(out, output, result)
+}
+
+func usage(flags *flag.FlagSet) {
+	w := flags.Output()
+	fmt.Fprintln(w, "Uso: movectl [opções] <comando> [argumentos]")
+	fmt.Fprintln(w, "\nComandos:")
+	for _, group := range []string{"trip", "ingest", "operator", "admin", "settlement"} {
+		for _, name := range []string{"create", "read", "update", "delete", "transfer", "owner", "list", "deleted", "restore", "trips", "summary", "ingest", "config", "set-config", "pause", "resume", "status", "generate", "approve", "dispute", ""} {
+			if cmd, ok := commands[group][name]; ok {
+				fmt.Fprintf(w, "  %s %s %s\n", group, name, cmd.usage)
+			}
+		}
+	}
+	fmt.Fprintln(w, "  block list")
+	fmt.Fprintln(w, "  block verify [hash]")
+	fmt.Fprintln(w, "  export trips [-format csv|jsonl|geojson] [-from data] [-to data] [-slots arquivo] [-out arquivo]")
+	fmt.Fprintln(w, "  export summaries [-format csv|jsonl] [-from data] [-to data] [-out arquivo]")
+	fmt.Fprintln(w, "  export settlement <período> [-format csv|json] [-out arquivo]")
+	fmt.Fprintln(w, "\nOpções:")
+	flags.PrintDefaults()
+}
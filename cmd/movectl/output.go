@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formatos de saída aceitos em -output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printResult escreve o resultado de uma transação. Resultados que não são JSON
+// (ou transações sem retorno) são escritos como vieram.
+func printResult(out io.Writer, format string, result []byte) error {
+	if len(bytes.TrimSpace(result)) == 0 {
+		fmt.Fprintln(out, "OK")
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(result, &value); err != nil {
+		fmt.Fprintln(out, string(result))
+		return nil
+	}
+
+	if format == outputJSON {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, result, "", "    "); err != nil {
+			return fmt.Errorf("falha ao formatar o JSON: %v", err)
+		}
+		fmt.Fprintln(out, indented.String())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	switch v := value.(type) {
+	case []interface{}:
+		writeRows(w, v)
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			fmt.Fprintf(w, "%s\t%s\n", key, formatCell(v[key]))
+		}
+	default:
+		fmt.Fprintln(w, formatCell(v))
+	}
+	return w.Flush()
+}
+
+// writeRows escreve uma lista de objetos como tabela, uma coluna por campo
+func writeRows(w io.Writer, rows []interface{}) {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "(nenhum resultado)")
+		return
+	}
+
+	columnSet := map[string]interface{}{}
+	for _, row := range rows {
+		if object, ok := row.(map[string]interface{}); ok {
+			for key := range object {
+				columnSet[key] = nil
+			}
+		}
+	}
+	if len(columnSet) == 0 {
+		for _, row := range rows {
+			fmt.Fprintln(w, formatCell(row))
+		}
+		return
+	}
+
+	columns := sortedKeys(columnSet)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		object, _ := row.(map[string]interface{})
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = formatCell(object[column])
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%g", v)
+	default:
+		cellJSON, _ := json.Marshal(v)
+		return string(cellJSON)
+	}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,20 @@
+//go:build !mock
+
+package main
+
+import (
+	"errors"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/backend/gateway"
+	"Chaincodemove/config"
+)
+
+// newBackend conecta ao Fabric Gateway configurado
+func newBackend(cfg *config.Config, mockAttrs string) (backend.Backend, error) {
+	if mockAttrs != "" {
+		return nil, errors.New("-mock-attrs exige um binário compilado com -tags mock")
+	}
+
+	return gateway.New(cfg)
+}
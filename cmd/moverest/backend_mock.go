@@ -0,0 +1,61 @@
+//go:build mock
+
+package main
+
+import (
+	"sync"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/config"
+)
+
+// newBackend cria a rede simulada em memória. As transações são assinadas por uma
+// identidade do MSP configurado com os atributos mockAttrs (nome=valor,...), ou com os da
+// operadora se mockAttrs for vazio.
+func newBackend(cfg *config.Config, mockAttrs string) (backend.Backend, error) {
+	contract, err := mock.New(cfg.MSPID)
+	if err != nil {
+		return nil, err
+	}
+	if err := contract.Bootstrap(); err != nil {
+		return nil, err
+	}
+	attributes := mock.OperatorAttributes
+	if mockAttrs != "" {
+		if attributes, err = mock.ParseAttributes(mockAttrs); err != nil {
+			return nil, err
+		}
+	}
+	if err := contract.SetIdentity(cfg.MSPID, attributes); err != nil {
+		return nil, err
+	}
+
+	return &lockedBackend{contract: contract}, nil
+}
+
+// lockedBackend executa uma transação de cada vez na rede simulada. O servidor HTTP
+// atende cada requisição numa goroutine, e o estado em memória do mock não aceita
+// transações concorrentes.
+type lockedBackend struct {
+	mu       sync.Mutex
+	contract *mock.Backend
+}
+
+func (b *lockedBackend) Submit(name string, args ...string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.contract.Submit(name, args...)
+}
+
+func (b *lockedBackend) Evaluate(name string, args ...string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.contract.Evaluate(name, args...)
+}
+
+func (b *lockedBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.contract.Close()
+}
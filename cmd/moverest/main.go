@@ -0,0 +1,44 @@
+// Command moverest serve o contrato de viagens como API REST (veja o pacote rest).
+//
+// Por padrão as transações vão para o Fabric Gateway configurado no ambiente.
+// Compilado com -tags mock, o contrato roda em memória dentro do próprio processo:
+//
+//	go build -tags mock ./cmd/moverest
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"Chaincodemove/config"
+	"Chaincodemove/rest"
+)
+
+func main() {
+	envFile := flag.String("env", "", "arquivo .env com a configuração (padrão: ./.env, se existir)")
+	mockAttrs := flag.String("mock-attrs", "", "atributos da identidade da rede simulada, como riderID=r1 (só com -tags mock; padrão: role=operator)")
+	flag.Parse()
+
+	var cfg *config.Config
+	var err error
+	if *envFile != "" {
+		cfg, err = config.Load(*envFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		log.Fatalf("Erro ao carregar a configuração: %v", err)
+	}
+
+	contract, err := newBackend(cfg, *mockAttrs)
+	if err != nil {
+		log.Fatalf("Erro ao conectar ao contrato: %v", err)
+	}
+	defer contract.Close()
+
+	log.Printf("API REST escutando em %s", cfg.RESTAddress)
+	if err := http.ListenAndServe(cfg.RESTAddress, rest.NewServer(contract)); err != nil {
+		log.Printf("Erro no servidor REST: %v", err)
+	}
+}
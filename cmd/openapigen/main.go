@@ -0,0 +1,81 @@
+// Command openapigen gera api/openapi.json a partir dos metadados do contrato de viagens.
+//
+// O contrato é instanciado em memória (veja o pacote backend/mock) e consultado pela
+// transação de sistema org.hyperledger.fabric:GetMetadata, a mesma que o peer expõe.
+//
+//	go run ./cmd/openapigen -out api/openapi.json           regenera o documento
+//	go run ./cmd/openapigen -out api/openapi.json -check    falha se o documento estiver desatualizado
+//
+// O teste do pacote faz a mesma verificação que -check, então go test ./... falha quando o
+// documento fica fora de sincronia com o contrato.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/openapi"
+)
+
+func main() {
+	out := flag.String("out", "api/openapi.json", "arquivo do documento OpenAPI")
+	check := flag.Bool("check", false, "só verifica se o arquivo está atualizado, sem gravá-lo")
+	title := flag.String("title", "Chaincodemove", "título da API")
+	version := flag.String("version", "1.0.0", "versão da API")
+	flag.Parse()
+
+	document, err := generate(*title, *version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao gerar o documento OpenAPI: %s\n", err)
+		os.Exit(2)
+	}
+
+	if *check {
+		current, err := os.ReadFile(*out)
+		if err != nil || !bytes.Equal(current, document) {
+			fmt.Fprintf(os.Stderr, "%s está desatualizado; rode go run ./cmd/openapigen -out %s\n", *out, *out)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*out, document, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao gravar o documento OpenAPI: %s\n", err)
+		os.Exit(2)
+	}
+}
+
+func generate(title string, version string) ([]byte, error) {
+	contract, err := mock.New("Org1MSP")
+	if err != nil {
+		return nil, err
+	}
+	defer contract.Close()
+
+	metadataJSON, err := contract.Evaluate(contractapi.SystemContractName + ":GetMetadata")
+	if err != nil {
+		return nil, fmt.Errorf("falha ao obter os metadados do contrato: %v", err)
+	}
+	var chaincodeMetadata metadata.ContractChaincodeMetadata
+	if err := json.Unmarshal(metadataJSON, &chaincodeMetadata); err != nil {
+		return nil, fmt.Errorf("falha ao deserializar os metadados do contrato: %v", err)
+	}
+
+	document, err := openapi.Generate(chaincodeMetadata, title, version)
+	if err != nil {
+		return nil, err
+	}
+	documentJSON, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("falha ao serializar o documento OpenAPI: %v", err)
+	}
+
+	return append(documentJSON, '\n'), nil
+}
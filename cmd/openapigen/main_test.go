@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDocumentInSync falha se api/openapi.json não for o documento gerado do contrato atual
+func TestDocumentInSync(t *testing.T) {
+	document, err := generate("Chaincodemove", "1.0.0")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	path := filepath.Join("..", "..", "api", "openapi.json")
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("falha ao ler %s: %v", path, err)
+	}
+	if !bytes.Equal(current, document) {
+		t.Errorf("%s está desatualizado; rode go generate ou go run ./cmd/openapigen -out api/openapi.json", path)
+	}
+}
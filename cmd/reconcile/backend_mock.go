@@ -0,0 +1,48 @@
+//go:build mock
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/config"
+)
+
+// newBackend cria a rede simulada, carregando e depois gravando o estado em mockState quando
+// informado. As transações são assinadas por uma identidade do MSP configurado com os
+// atributos mockAttrs (nome=valor,...), ou com os da operadora se mockAttrs for vazio.
+func newBackend(cfg *config.Config, mockState string, mockAttrs string) (backend.Backend, func(), error) {
+	contract, err := mock.New(cfg.MSPID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := contract.Bootstrap(); err != nil {
+		return nil, nil, err
+	}
+	attributes := mock.OperatorAttributes
+	if mockAttrs != "" {
+		if attributes, err = mock.ParseAttributes(mockAttrs); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := contract.SetIdentity(cfg.MSPID, attributes); err != nil {
+		return nil, nil, err
+	}
+	if mockState == "" {
+		return contract, func() {}, nil
+	}
+
+	if err := contract.LoadState(mockState); err != nil {
+		return nil, nil, err
+	}
+	save := func() {
+		if err := contract.SaveState(mockState); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro: %s\n", err)
+		}
+	}
+
+	return contract, save, nil
+}
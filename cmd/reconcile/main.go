@@ -0,0 +1,137 @@
+// Command reconcile compara as viagens do banco moveuff com as viagens do livro-razão
+// e relata as que faltam no livro-razão, as que sobram nele e as que divergem.
+//
+// As viagens do livro-razão são lidas pelo Fabric Gateway configurado no ambiente,
+// página a página com a transação GetTripDataPage. Sem -operator, são comparadas as
+// viagens gravadas em chave simples com o banco de MOVEUFF_DB_DSN; com -operator, as
+// viagens da operadora com o banco dela:
+//
+//	reconcile -from 2023-06-01 -to 2023-06-30 -format csv
+//	reconcile -operator bike-rio -from 2023-06-01 -to 2023-06-30
+//
+// O código de saída é 0 sem divergências, 1 com divergências e 2 em caso de erro.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/config"
+	"Chaincodemove/reconcile"
+	"Chaincodemove/source"
+)
+
+func main() {
+	today := time.Now().Format(source.DateLayout)
+	envFile := flag.String("env", "", "arquivo .env com a configuração (padrão: ./.env, se existir)")
+	operatorID := flag.String("operator", "", "operadora cujas viagens são comparadas (padrão: as gravadas em chave simples)")
+	dsn := flag.String("dsn", "", "DSN do banco MySQL (padrão: o da configuração)")
+	from := flag.String("from", today, "data inicial da janela (AAAA-MM-DD)")
+	to := flag.String("to", today, "data final da janela (AAAA-MM-DD)")
+	format := flag.String("format", "json", "formato do relatório: json ou csv")
+	mockState := flag.String("mock-state", "", "arquivo de estado da rede simulada (só com -tags mock)")
+	mockAttrs := flag.String("mock-attrs", "", "atributos da identidade da rede simulada, como riderID=r1 (só com -tags mock; padrão: role=operator)")
+	flag.Parse()
+
+	var cfg *config.Config
+	var err error
+	if *envFile != "" {
+		cfg, err = config.Load(*envFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao carregar a configuração: %s\n", err)
+		os.Exit(2)
+	}
+	if *dsn == "" {
+		*dsn = cfg.DatabaseDSN
+		if *operatorID != "" {
+			operator, err := cfg.Operator(*operatorID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro ao carregar a configuração: %s\n", err)
+				os.Exit(2)
+			}
+			*dsn = operator.DatabaseDSN
+		}
+	}
+
+	contract, closeBackend, err := newBackend(cfg, *mockState, *mockAttrs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao conectar ao contrato: %s\n", err)
+		os.Exit(2)
+	}
+	report, err := run(contract, *operatorID, *dsn, source.Window{From: *from, To: *to})
+	closeBackend()
+	contract.Close()
+	if err == nil {
+		err = write(report, *format, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro na reconciliação: %s\n", err)
+		os.Exit(2)
+	}
+	if report.HasDiscrepancies() {
+		os.Exit(1)
+	}
+}
+
+func run(contract backend.Backend, operatorID string, dsn string, window source.Window) (*reconcile.Report, error) {
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+	ledgerTrips, err := readLedger(contract, operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao conectar ao banco de dados: %v", err)
+	}
+	defer db.Close()
+
+	return reconcile.Reconcile(&source.MySQLSource{DB: db}, ledgerTrips, window)
+}
+
+// readLedger lê do livro-razão as viagens da operadora (vazia: as gravadas em chave simples)
+func readLedger(contract backend.Backend, operatorID string) ([]source.TripRow, error) {
+	var trips []source.TripRow
+	err := backend.ForEachTripPage(contract, func(page []byte) error {
+		var pageTrips []struct {
+			source.TripRow
+			OperatorID string `json:"OperatorID"`
+		}
+		if err := json.Unmarshal(page, &pageTrips); err != nil {
+			return fmt.Errorf("falha ao deserializar as viagens do livro-razão: %v", err)
+		}
+		for _, trip := range pageTrips {
+			if trip.OperatorID == operatorID {
+				trips = append(trips, trip.TripRow)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return trips, nil
+}
+
+func write(report *reconcile.Report, format string, out io.Writer) error {
+	switch format {
+	case "json":
+		return report.WriteJSON(out)
+	case "csv":
+		return report.WriteCSV(out)
+	default:
+		return fmt.Errorf("formato inválido %q: use json ou csv", format)
+	}
+}
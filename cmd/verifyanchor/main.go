@@ -0,0 +1,114 @@
+// Command verifyanchor recalcula, a partir do MySQL, o hash canônico de um lote
+// registrado por QueryBanco e informa se o banco ainda confere com a âncora do livro-razão.
+//
+// A âncora é o JSON devolvido pela transação GetSourceAnchor, por exemplo:
+//
+//	peer chaincode query -C canal -n Chaincodemove -c '{"Args":["IngestContract:GetSourceAnchor","<txID>"]}' > ancora.json
+//	verifyanchor -anchor ancora.json
+//
+// O código de saída é 0 quando o banco confere, 1 quando diverge e 2 em caso de erro.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"Chaincodemove/config"
+	"Chaincodemove/source"
+)
+
+func main() {
+	envFile := flag.String("env", "", "arquivo .env com a configuração (padrão: ./.env, se existir)")
+	dsn := flag.String("dsn", "", "DSN do banco MySQL (padrão: o da configuração)")
+	anchorPath := flag.String("anchor", "-", "arquivo com o JSON da âncora (- para a entrada padrão)")
+	flag.Parse()
+
+	var cfg *config.Config
+	var err error
+	if *envFile != "" {
+		cfg, err = config.Load(*envFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao carregar a configuração: %s\n", err)
+		os.Exit(2)
+	}
+	if *dsn == "" {
+		*dsn = cfg.DatabaseDSN
+	}
+
+	matches, err := run(*dsn, *anchorPath, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao verificar a âncora: %s\n", err)
+		os.Exit(2)
+	}
+	if !matches {
+		os.Exit(1)
+	}
+}
+
+func run(dsn string, anchorPath string, out io.Writer) (bool, error) {
+	anchor, err := readAnchor(anchorPath)
+	if err != nil {
+		return false, err
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return false, fmt.Errorf("falha ao conectar ao banco de dados: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := source.QueryTrips(db, anchor.Window)
+	if err != nil {
+		return false, err
+	}
+	hash, err := source.CanonicalHash(rows)
+	if err != nil {
+		return false, err
+	}
+
+	matches := hash == anchor.Hash && len(rows) == anchor.RowCount
+	fmt.Fprintf(out, "Transação:       %s\n", anchor.TxID)
+	fmt.Fprintf(out, "Janela:          %s a %s\n", anchor.Window.From, anchor.Window.To)
+	fmt.Fprintf(out, "Linhas (ledger): %d\n", anchor.RowCount)
+	fmt.Fprintf(out, "Linhas (banco):  %d\n", len(rows))
+	fmt.Fprintf(out, "Hash (ledger):   %s\n", anchor.Hash)
+	fmt.Fprintf(out, "Hash (banco):    %s\n", hash)
+	if matches {
+		fmt.Fprintln(out, "Resultado:       o banco confere com a âncora")
+	} else {
+		fmt.Fprintln(out, "Resultado:       o banco DIVERGE da âncora")
+	}
+
+	return matches, nil
+}
+
+func readAnchor(path string) (*source.Anchor, error) {
+	var anchorJSON []byte
+	var err error
+	if path == "-" {
+		anchorJSON, err = io.ReadAll(os.Stdin)
+	} else {
+		anchorJSON, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler a âncora: %v", err)
+	}
+
+	var anchor source.Anchor
+	err = json.Unmarshal(anchorJSON, &anchor)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao deserializar a âncora do JSON: %v", err)
+	}
+	if err := anchor.Window.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &anchor, nil
+}
@@ -0,0 +1,467 @@
+// Package config carrega a configuração do chaincode e das ferramentas de ingestão
+// a partir de variáveis de ambiente e de arquivos .env.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// Embute o banco de fusos horários, para TimeZone não depender do sistema do peer
+	_ "time/tzdata"
+
+	"github.com/joho/godotenv"
+)
+
+// Variáveis de ambiente lidas por Load
+const (
+	EnvDatabaseDSN             = "MOVEUFF_DB_DSN"
+	EnvQueryWindowDays         = "MOVEUFF_QUERY_WINDOW_DAYS"
+	EnvMaxTransactionsPerBlock = "MOVEUFF_MAX_TX_PER_BLOCK"
+	EnvBlockTimeLimit          = "MOVEUFF_BLOCK_TIME_LIMIT"
+	EnvChaincodeMode           = "MOVEUFF_CHAINCODE_MODE"
+	EnvChaincodeAddress        = "CHAINCODE_SERVER_ADDRESS"
+	EnvChaincodeID             = "CHAINCODE_ID"
+	EnvTLSKeyFile              = "CHAINCODE_TLS_KEY"
+	EnvTLSCertFile             = "CHAINCODE_TLS_CERT"
+	EnvTLSClientCACertFile     = "CHAINCODE_CLIENT_CA_CERT"
+	EnvLogLevel                = "MOVEUFF_LOG_LEVEL"
+	EnvTimeZone                = "MOVEUFF_TIMEZONE"
+	EnvGatewayEndpoint         = "MOVEUFF_GATEWAY_ENDPOINT"
+	EnvGatewayHostOverride     = "MOVEUFF_GATEWAY_HOST_OVERRIDE"
+	EnvGatewayTLSCertFile      = "MOVEUFF_GATEWAY_TLS_CERT"
+	EnvMSPID                   = "MOVEUFF_MSP_ID"
+	EnvCertFile                = "MOVEUFF_CERT"
+	EnvKeyFile                 = "MOVEUFF_KEY"
+	EnvChannel                 = "MOVEUFF_CHANNEL"
+	EnvChaincodeName           = "MOVEUFF_CHAINCODE_NAME"
+	EnvRESTAddress             = "MOVEUFF_REST_ADDRESS"
+	EnvOperators               = "MOVEUFF_OPERATORS"
+)
+
+// Cada operadora listada em MOVEUFF_OPERATORS pode ter seu próprio banco, janela de
+// consulta e fuso horário em MOVEUFF_OPERATOR_<ID>_DB_DSN,
+// MOVEUFF_OPERATOR_<ID>_QUERY_WINDOW_DAYS e MOVEUFF_OPERATOR_<ID>_TIMEZONE, com o ID em
+// maiúsculas e hífens trocados por sublinhados. Sem elas, valem MOVEUFF_DB_DSN,
+// MOVEUFF_QUERY_WINDOW_DAYS e MOVEUFF_TIMEZONE. MOVEUFF_OPERATOR_<ID>_MSP_ID restringe a
+// gravação das viagens da operadora às identidades desse MSP.
+const (
+	envOperatorPrefix          = "MOVEUFF_OPERATOR_"
+	envOperatorDatabaseDSN     = "_DB_DSN"
+	envOperatorQueryWindowDays = "_QUERY_WINDOW_DAYS"
+	envOperatorTimeZone        = "_TIMEZONE"
+	envOperatorMSPID           = "_MSP_ID"
+)
+
+// DefaultOperatorID é a operadora original do moveuff. As viagens gravadas antes do
+// suporte a várias operadoras pertencem a ela.
+const DefaultOperatorID = "moveuff"
+
+// Níveis de log aceitos
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Modos de execução do chaincode
+const (
+	// ChaincodeModePeer deixa o peer compilar e iniciar o chaincode
+	ChaincodeModePeer = "peer"
+	// ChaincodeModeServer roda o chaincode como serviço externo (shim.ChaincodeServer)
+	ChaincodeModeServer = "server"
+)
+
+// defaultEnvFile é carregado por Load quando nenhum arquivo é informado
+const defaultEnvFile = ".env"
+
+// Config reúne os parâmetros que antes estavam fixos no código
+type Config struct {
+	// DatabaseDSN é o DSN do banco MySQL moveuff
+	DatabaseDSN string
+	// QueryWindowDays é quantos dias, terminando no dia da transação, cada ingestão lê do banco
+	QueryWindowDays int
+	// MaxTransactionsPerBlock é o máximo de transações por bloco da aplicação
+	MaxTransactionsPerBlock int
+	// BlockTimeLimit é o tempo sem transações após o qual o bloco atual é descartado
+	BlockTimeLimit time.Duration
+	// ChaincodeMode é ChaincodeModePeer ou ChaincodeModeServer
+	ChaincodeMode string
+	// ChaincodeAddress é o endereço em que o servidor do chaincode escuta
+	ChaincodeAddress string
+	// ChaincodeID é o ID do pacote instalado no peer, obrigatório no modo servidor
+	ChaincodeID string
+	// TLSKeyFile e TLSCertFile habilitam TLS no modo servidor quando informados
+	TLSKeyFile  string
+	TLSCertFile string
+	// TLSClientCACertFile, quando informado, faz o servidor verificar o certificado do peer
+	TLSClientCACertFile string
+	// LogLevel é um de debug, info, warn ou error
+	LogLevel string
+	// TimeZone é o fuso horário (nome IANA) das datas de viagem sem fuso, como as que
+	// vêm do MySQL; deve ser o mesmo em todos os peers
+	TimeZone string
+
+	// CreditsPerKm, MinTripDistanceKm e MaxTripDistanceKm não têm variável de ambiente:
+	// vêm da configuração gravada no estado mundial (veja LedgerConfig).
+
+	// CreditsPerKm é quantos créditos cada km de viagem rende ao ciclista
+	CreditsPerKm float64
+	// MinTripDistanceKm e MaxTripDistanceKm limitam a distância aceita ao corrigir uma
+	// viagem com UpdateTripData; MaxTripDistanceKm zero não limita. As viagens novas não
+	// são recusadas pela distância, só sinalizadas pelas regras de anomalia
+	MinTripDistanceKm float64
+	MaxTripDistanceKm float64
+
+	// Os campos abaixo são usados pelas ferramentas que falam com o Fabric Gateway
+	// e só são validados quando uma conexão é aberta.
+
+	// GatewayEndpoint é o host:porta do peer que atende o Fabric Gateway
+	GatewayEndpoint string
+	// GatewayHostOverride substitui o nome do host na verificação TLS, quando o peer é acessado por outro nome
+	GatewayHostOverride string
+	// GatewayTLSCertFile é o certificado da CA TLS do peer
+	GatewayTLSCertFile string
+	// MSPID, CertFile e KeyFile identificam o usuário que assina as transações
+	MSPID    string
+	CertFile string
+	KeyFile  string
+	// Channel e ChaincodeName localizam o contrato na rede
+	Channel       string
+	ChaincodeName string
+
+	// RESTAddress é o endereço em que o gateway REST escuta
+	RESTAddress string
+
+	// Operators é a configuração de ingestão de cada operadora de bicicletas do canal
+	Operators []OperatorConfig
+	// OperatorMSPs são os MSPs cujas identidades com o papel de operadora são aceitas.
+	// Não vem do ambiente: é gravada no estado mundial por AdminContract.SetConfig (veja
+	// LedgerConfig), para que todos os peers decidam igual, e fica vazia até a primeira
+	// configuração
+	OperatorMSPs []string
+}
+
+// OperatorConfig é a configuração de ingestão de uma operadora
+type OperatorConfig struct {
+	// ID identifica a operadora nas chaves das viagens; só letras minúsculas, dígitos e hífens
+	ID string
+	// DatabaseDSN é o DSN do banco da operadora
+	DatabaseDSN string
+	// QueryWindowDays é quantos dias cada ingestão da operadora lê do banco
+	QueryWindowDays int
+	// TimeZone é o fuso horário (nome IANA) das datas do banco da operadora
+	TimeZone string
+	// MSPID é o MSP cujas identidades podem gravar viagens da operadora; vazio aceita
+	// qualquer MSP com o papel de operadora
+	MSPID string
+}
+
+// Operator retorna a configuração de ingestão da operadora com o ID fornecido
+func (c *Config) Operator(id string) (*OperatorConfig, error) {
+	for i := range c.Operators {
+		if c.Operators[i].ID == id {
+			return &c.Operators[i], nil
+		}
+	}
+	return nil, fmt.Errorf("a operadora %s não está configurada em %s", id, EnvOperators)
+}
+
+// IsOperatorMSP informa se as identidades do MSP mspID podem agir como operadora, isto
+// é, se o MSP está em OperatorMSPs
+func (c *Config) IsOperatorMSP(mspID string) bool {
+	for _, operatorMSP := range c.OperatorMSPs {
+		if operatorMSP == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// Location retorna o fuso horário TimeZone, ou UTC se ele não for válido
+func (c *Config) Location() *time.Location {
+	return loadLocation(c.TimeZone)
+}
+
+// Location retorna o fuso horário TimeZone da operadora, ou UTC se ele não for válido
+func (o *OperatorConfig) Location() *time.Location {
+	return loadLocation(o.TimeZone)
+}
+
+func loadLocation(timeZone string) *time.Location {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Default retorna a configuração usada quando nenhuma variável de ambiente é definida
+func Default() *Config {
+	return &Config{
+		DatabaseDSN:             "root:movepass@tcp(localhost:3306)/moveuff",
+		QueryWindowDays:         1,
+		MaxTransactionsPerBlock: 10,
+		BlockTimeLimit:          10 * time.Minute,
+		ChaincodeMode:           ChaincodeModePeer,
+		ChaincodeAddress:        "0.0.0.0:9999",
+		LogLevel:                LogLevelInfo,
+		TimeZone:                "America/Sao_Paulo",
+		GatewayEndpoint:         "localhost:7051",
+		MSPID:                   "Org1MSP",
+		Channel:                 "mychannel",
+		ChaincodeName:           "Chaincodemove",
+		RESTAddress:             ":8080",
+		Operators: []OperatorConfig{
+			{ID: DefaultOperatorID, DatabaseDSN: "root:movepass@tcp(localhost:3306)/moveuff", QueryWindowDays: 1, TimeZone: "America/Sao_Paulo"},
+		},
+	}
+}
+
+// Load carrega os arquivos .env fornecidos (ou ./.env, se existir) e então lê a
+// configuração do ambiente. Variáveis já definidas no ambiente têm precedência
+// sobre as dos arquivos.
+func Load(files ...string) (*Config, error) {
+	if len(files) == 0 {
+		if _, err := os.Stat(defaultEnvFile); err == nil {
+			files = []string{defaultEnvFile}
+		}
+	}
+	if len(files) > 0 {
+		if err := godotenv.Load(files...); err != nil {
+			return nil, fmt.Errorf("falha ao carregar o arquivo de configuração: %v", err)
+		}
+	}
+
+	return FromEnv()
+}
+
+// FromEnv lê a configuração das variáveis de ambiente, usando os valores de Default para as ausentes
+func FromEnv() (*Config, error) {
+	cfg := Default()
+
+	if value, ok := lookup(EnvDatabaseDSN); ok {
+		cfg.DatabaseDSN = value
+	}
+	if value, ok := lookup(EnvQueryWindowDays); ok {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("valor inválido em %s: %v", EnvQueryWindowDays, err)
+		}
+		cfg.QueryWindowDays = days
+	}
+	if value, ok := lookup(EnvMaxTransactionsPerBlock); ok {
+		maxTransactions, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("valor inválido em %s: %v", EnvMaxTransactionsPerBlock, err)
+		}
+		cfg.MaxTransactionsPerBlock = maxTransactions
+	}
+	if value, ok := lookup(EnvBlockTimeLimit); ok {
+		limit, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("valor inválido em %s: %v", EnvBlockTimeLimit, err)
+		}
+		cfg.BlockTimeLimit = limit
+	}
+	if value, ok := lookup(EnvChaincodeMode); ok {
+		cfg.ChaincodeMode = strings.ToLower(value)
+	}
+	if value, ok := lookup(EnvChaincodeAddress); ok {
+		cfg.ChaincodeAddress = value
+	}
+	if value, ok := lookup(EnvChaincodeID); ok {
+		cfg.ChaincodeID = value
+	}
+	if value, ok := lookup(EnvTLSKeyFile); ok {
+		cfg.TLSKeyFile = value
+	}
+	if value, ok := lookup(EnvTLSCertFile); ok {
+		cfg.TLSCertFile = value
+	}
+	if value, ok := lookup(EnvTLSClientCACertFile); ok {
+		cfg.TLSClientCACertFile = value
+	}
+	if value, ok := lookup(EnvLogLevel); ok {
+		cfg.LogLevel = strings.ToLower(value)
+	}
+	if value, ok := lookup(EnvTimeZone); ok {
+		cfg.TimeZone = value
+	}
+	// As operadoras herdam o banco, a janela e o fuso lidos acima
+	operatorIDs := []string{DefaultOperatorID}
+	if value, ok := lookup(EnvOperators); ok {
+		operatorIDs = strings.Split(value, ",")
+	}
+	cfg.Operators = nil
+	for _, id := range operatorIDs {
+		operator := OperatorConfig{
+			ID:              strings.TrimSpace(id),
+			DatabaseDSN:     cfg.DatabaseDSN,
+			QueryWindowDays: cfg.QueryWindowDays,
+			TimeZone:        cfg.TimeZone,
+		}
+		prefix := envOperatorPrefix + strings.ToUpper(strings.ReplaceAll(operator.ID, "-", "_"))
+		if value, ok := lookup(prefix + envOperatorDatabaseDSN); ok {
+			operator.DatabaseDSN = value
+		}
+		if value, ok := lookup(prefix + envOperatorTimeZone); ok {
+			operator.TimeZone = value
+		}
+		if value, ok := lookup(prefix + envOperatorMSPID); ok {
+			operator.MSPID = value
+		}
+		if value, ok := lookup(prefix + envOperatorQueryWindowDays); ok {
+			days, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("valor inválido em %s: %v", prefix+envOperatorQueryWindowDays, err)
+			}
+			operator.QueryWindowDays = days
+		}
+		cfg.Operators = append(cfg.Operators, operator)
+	}
+	for name, field := range map[string]*string{
+		EnvGatewayEndpoint:     &cfg.GatewayEndpoint,
+		EnvGatewayHostOverride: &cfg.GatewayHostOverride,
+		EnvGatewayTLSCertFile:  &cfg.GatewayTLSCertFile,
+		EnvMSPID:               &cfg.MSPID,
+		EnvCertFile:            &cfg.CertFile,
+		EnvKeyFile:             &cfg.KeyFile,
+		EnvChannel:             &cfg.Channel,
+		EnvChaincodeName:       &cfg.ChaincodeName,
+		EnvRESTAddress:         &cfg.RESTAddress,
+	} {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate verifica se todos os campos têm valores utilizáveis
+func (c *Config) Validate() error {
+	var problems []string
+	if c.DatabaseDSN == "" {
+		problems = append(problems, "o DSN do banco não pode ser vazio")
+	}
+	if c.QueryWindowDays < 1 {
+		problems = append(problems, "a janela de consulta deve ter pelo menos 1 dia")
+	}
+	if c.MaxTransactionsPerBlock < 1 {
+		problems = append(problems, "o máximo de transações por bloco deve ser positivo")
+	}
+	if c.BlockTimeLimit <= 0 {
+		problems = append(problems, "o limite de tempo do bloco deve ser positivo")
+	}
+	switch c.ChaincodeMode {
+	case ChaincodeModePeer:
+	case ChaincodeModeServer:
+		if c.ChaincodeAddress == "" {
+			problems = append(problems, "o endereço do servidor do chaincode não pode ser vazio")
+		}
+		if c.ChaincodeID == "" {
+			problems = append(problems, "o modo servidor exige o ID do chaincode")
+		}
+		if (c.TLSKeyFile == "") != (c.TLSCertFile == "") {
+			problems = append(problems, "a chave e o certificado TLS devem ser informados juntos")
+		}
+		if c.TLSClientCACertFile != "" && c.TLSKeyFile == "" {
+			problems = append(problems, "a CA dos clientes exige TLS habilitado")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("modo do chaincode inválido %q", c.ChaincodeMode))
+	}
+	switch c.LogLevel {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		problems = append(problems, fmt.Sprintf("nível de log inválido %q", c.LogLevel))
+	}
+	if !validTimeZone(c.TimeZone) {
+		problems = append(problems, fmt.Sprintf("fuso horário inválido %q", c.TimeZone))
+	}
+	seen := map[string]bool{}
+	for _, operator := range c.Operators {
+		switch {
+		case !validOperatorID(operator.ID):
+			problems = append(problems, fmt.Sprintf("ID de operadora inválido %q", operator.ID))
+		case seen[operator.ID]:
+			problems = append(problems, fmt.Sprintf("a operadora %s aparece mais de uma vez", operator.ID))
+		case operator.DatabaseDSN == "":
+			problems = append(problems, fmt.Sprintf("o DSN do banco da operadora %s não pode ser vazio", operator.ID))
+		case operator.QueryWindowDays < 1:
+			problems = append(problems, fmt.Sprintf("a janela de consulta da operadora %s deve ter pelo menos 1 dia", operator.ID))
+		case !validTimeZone(operator.TimeZone):
+			problems = append(problems, fmt.Sprintf("fuso horário inválido %q da operadora %s", operator.TimeZone, operator.ID))
+		}
+		seen[operator.ID] = true
+	}
+
+	if len(problems) > 0 {
+		return errors.New("configuração inválida: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ValidateGateway verifica se os campos necessários para conectar ao Fabric Gateway foram informados
+func (c *Config) ValidateGateway() error {
+	var missing []string
+	for name, value := range map[string]string{
+		EnvGatewayEndpoint:    c.GatewayEndpoint,
+		EnvGatewayTLSCertFile: c.GatewayTLSCertFile,
+		EnvMSPID:              c.MSPID,
+		EnvCertFile:           c.CertFile,
+		EnvKeyFile:            c.KeyFile,
+		EnvChannel:            c.Channel,
+		EnvChaincodeName:      c.ChaincodeName,
+	} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.New("configuração do gateway incompleta, defina: " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// validOperatorID aceita IDs com letras minúsculas, dígitos e hífens
+func validOperatorID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validTimeZone aceita nomes IANA de fuso horário; vazio não é aceito, embora
+// time.LoadLocation o trate como UTC
+func validTimeZone(timeZone string) bool {
+	if timeZone == "" {
+		return false
+	}
+	_, err := time.LoadLocation(timeZone)
+	return err == nil
+}
+
+// lookup retorna o valor da variável de ambiente, tratando valores em branco como ausentes
+func lookup(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	value = strings.TrimSpace(value)
+	return value, ok && value != ""
+}
@@ -0,0 +1,177 @@
+package config_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Chaincodemove/config"
+)
+
+// clearEnv esvazia as variáveis lidas por FromEnv; lookup trata valores em branco como ausentes
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		config.EnvDatabaseDSN, config.EnvQueryWindowDays, config.EnvMaxTransactionsPerBlock,
+		config.EnvBlockTimeLimit, config.EnvChaincodeMode, config.EnvChaincodeAddress, config.EnvChaincodeID,
+		config.EnvTLSKeyFile, config.EnvTLSCertFile, config.EnvTLSClientCACertFile, config.EnvLogLevel,
+		config.EnvTimeZone, config.EnvGatewayEndpoint, config.EnvGatewayHostOverride, config.EnvGatewayTLSCertFile,
+		config.EnvMSPID, config.EnvCertFile, config.EnvKeyFile, config.EnvChannel, config.EnvChaincodeName,
+		config.EnvRESTAddress, config.EnvOperators,
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestFromEnvDefaults(t *testing.T) {
+	clearEnv(t)
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, config.Default()) {
+		t.Errorf("configuração = %+v, quer %+v", cfg, config.Default())
+	}
+}
+
+func TestFromEnvOperators(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(config.EnvDatabaseDSN, "root:pass@tcp(db:3306)/moveuff")
+	t.Setenv(config.EnvQueryWindowDays, "3")
+	t.Setenv(config.EnvTimeZone, "America/Sao_Paulo")
+	t.Setenv(config.EnvOperators, "moveuff, bike-rio")
+	t.Setenv("MOVEUFF_OPERATOR_BIKE_RIO_DB_DSN", "rio:pass@tcp(rio:3306)/bikes")
+	t.Setenv("MOVEUFF_OPERATOR_BIKE_RIO_QUERY_WINDOW_DAYS", "7")
+	t.Setenv("MOVEUFF_OPERATOR_BIKE_RIO_TIMEZONE", "America/Manaus")
+	t.Setenv("MOVEUFF_OPERATOR_BIKE_RIO_MSP_ID", "BikeRioMSP")
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	want := []config.OperatorConfig{
+		{ID: "moveuff", DatabaseDSN: "root:pass@tcp(db:3306)/moveuff", QueryWindowDays: 3, TimeZone: "America/Sao_Paulo"},
+		{ID: "bike-rio", DatabaseDSN: "rio:pass@tcp(rio:3306)/bikes", QueryWindowDays: 7, TimeZone: "America/Manaus", MSPID: "BikeRioMSP"},
+	}
+	if !reflect.DeepEqual(cfg.Operators, want) {
+		t.Errorf("operadoras = %+v, quer %+v", cfg.Operators, want)
+	}
+	// Os MSPs de operadora só vêm do estado mundial, nem mesmo o MSP da operadora conta
+	if cfg.IsOperatorMSP("BikeRioMSP") || cfg.IsOperatorMSP("Org1MSP") {
+		t.Errorf("MSPs de operadora sem configuração do estado mundial = %v", cfg.OperatorMSPs)
+	}
+
+	operator, err := cfg.Operator("bike-rio")
+	if err != nil {
+		t.Fatalf("Operator: %v", err)
+	}
+	if operator.Location().String() != "America/Manaus" {
+		t.Errorf("fuso da operadora = %s, quer America/Manaus", operator.Location())
+	}
+	if _, err := cfg.Operator("bike-sp"); err == nil {
+		t.Error("Operator(bike-sp) não falhou")
+	}
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		error string
+	}{
+		{"janela", map[string]string{config.EnvQueryWindowDays: "um"}, config.EnvQueryWindowDays},
+		{"limite do bloco", map[string]string{config.EnvBlockTimeLimit: "10"}, config.EnvBlockTimeLimit},
+		{"janela da operadora", map[string]string{config.EnvOperators: "bike-rio", "MOVEUFF_OPERATOR_BIKE_RIO_QUERY_WINDOW_DAYS": "sete"}, "MOVEUFF_OPERATOR_BIKE_RIO_QUERY_WINDOW_DAYS"},
+		{"ID da operadora", map[string]string{config.EnvOperators: "Bike_Rio"}, "ID de operadora inválido"},
+		{"operadora repetida", map[string]string{config.EnvOperators: "bike-rio,bike-rio"}, "aparece mais de uma vez"},
+		{"fuso da operadora", map[string]string{config.EnvOperators: "bike-rio", "MOVEUFF_OPERATOR_BIKE_RIO_TIMEZONE": "Rio"}, "fuso horário inválido \"Rio\" da operadora bike-rio"},
+		{"janela zero da operadora", map[string]string{config.EnvOperators: "bike-rio", "MOVEUFF_OPERATOR_BIKE_RIO_QUERY_WINDOW_DAYS": "0"}, "janela de consulta da operadora bike-rio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := config.FromEnv()
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("FromEnv: %v, quer um erro com %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*config.Config)
+		error  string
+	}{
+		{"padrão", func(*config.Config) {}, ""},
+		{"DSN vazio", func(c *config.Config) { c.DatabaseDSN = "" }, "o DSN do banco não pode ser vazio"},
+		{"janela", func(c *config.Config) { c.QueryWindowDays = 0 }, "janela de consulta deve ter pelo menos 1 dia"},
+		{"bloco", func(c *config.Config) { c.MaxTransactionsPerBlock = 0 }, "máximo de transações por bloco"},
+		{"limite do bloco", func(c *config.Config) { c.BlockTimeLimit = 0 }, "limite de tempo do bloco"},
+		{"modo", func(c *config.Config) { c.ChaincodeMode = "docker" }, "modo do chaincode inválido"},
+		{"servidor sem ID", func(c *config.Config) { c.ChaincodeMode = config.ChaincodeModeServer }, "exige o ID do chaincode"},
+		{"TLS pela metade", func(c *config.Config) {
+			c.ChaincodeMode, c.ChaincodeID, c.TLSKeyFile = config.ChaincodeModeServer, "cc:1", "key.pem"
+		}, "devem ser informados juntos"},
+		{"log", func(c *config.Config) { c.LogLevel = "trace" }, "nível de log inválido"},
+		{"fuso vazio", func(c *config.Config) { c.TimeZone = "" }, "fuso horário inválido \"\""},
+		{"DSN da operadora", func(c *config.Config) { c.Operators[0].DatabaseDSN = "" }, "o DSN do banco da operadora moveuff"},
+		{"fuso vazio da operadora", func(c *config.Config) { c.Operators[0].TimeZone = "" }, "fuso horário inválido \"\" da operadora moveuff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.change(cfg)
+			err := cfg.Validate()
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Validate: %v, quer um erro com %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestLedgerConfig(t *testing.T) {
+	ledger := config.Default().Ledger()
+	ledger.OperatorMSPs = []string{"Org1MSP", "Org2MSP"}
+	if err := ledger.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	cfg := config.Default().WithLedger(ledger)
+	for mspID, want := range map[string]bool{"Org1MSP": true, "Org2MSP": true, "Org3MSP": false, "": false} {
+		if got := cfg.IsOperatorMSP(mspID); got != want {
+			t.Errorf("IsOperatorMSP(%q) = %v, quer %v", mspID, got, want)
+		}
+	}
+	if got := cfg.Ledger(); !reflect.DeepEqual(got, ledger) {
+		t.Errorf("Ledger = %+v, quer %+v", got, ledger)
+	}
+
+	for _, operatorMSPs := range [][]string{nil, {"Org1MSP", " "}} {
+		ledger.OperatorMSPs = operatorMSPs
+		if err := ledger.Validate(); err == nil || !strings.Contains(err.Error(), "MSPs de operadora") {
+			t.Errorf("Validate com MSPs %q: %v", operatorMSPs, err)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	cfg := config.Default()
+	if cfg.Location().String() != "America/Sao_Paulo" {
+		t.Errorf("fuso = %s, quer America/Sao_Paulo", cfg.Location())
+	}
+	cfg.TimeZone = "Marte/Olympus"
+	if cfg.Location() != time.UTC {
+		t.Errorf("fuso inválido = %s, quer UTC", cfg.Location())
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// LedgerConfig é a parte da configuração gravada no estado mundial por
+// AdminContract.SetConfig. Ela muda com a rede no ar, sem reinstalar o chaincode,
+// e prevalece sobre os valores lidos do ambiente.
+type LedgerConfig struct {
+	// MaxTransactionsPerBlock e BlockTimeLimitSeconds são os limites dos blocos da aplicação
+	MaxTransactionsPerBlock int `json:"MaxTransactionsPerBlock"`
+	BlockTimeLimitSeconds   int `json:"BlockTimeLimitSeconds"`
+	// CreditsPerKm é quantos créditos cada km de viagem rende ao ciclista
+	CreditsPerKm float64 `json:"CreditsPerKm"`
+	// MinTripDistanceKm e MaxTripDistanceKm limitam a distância aceita ao corrigir uma
+	// viagem com UpdateTripData; MaxTripDistanceKm zero não limita. As viagens novas não
+	// são recusadas pela distância, só sinalizadas pelas regras de anomalia
+	MinTripDistanceKm float64 `json:"MinTripDistanceKm"`
+	MaxTripDistanceKm float64 `json:"MaxTripDistanceKm"`
+	// OperatorMSPs são os MSPs cujas identidades com o papel de operadora são aceitas
+	OperatorMSPs []string `json:"OperatorMSPs"`
+}
+
+// Ledger retorna os valores de c que a configuração do estado mundial substitui
+func (c *Config) Ledger() LedgerConfig {
+	return LedgerConfig{
+		MaxTransactionsPerBlock: c.MaxTransactionsPerBlock,
+		BlockTimeLimitSeconds:   int(c.BlockTimeLimit / time.Second),
+		CreditsPerKm:            c.CreditsPerKm,
+		MinTripDistanceKm:       c.MinTripDistanceKm,
+		MaxTripDistanceKm:       c.MaxTripDistanceKm,
+		OperatorMSPs:            c.OperatorMSPs,
+	}
+}
+
+// WithLedger retorna uma cópia de c com os valores de ledger
+func (c *Config) WithLedger(ledger LedgerConfig) *Config {
+	merged := *c
+	merged.MaxTransactionsPerBlock = ledger.MaxTransactionsPerBlock
+	merged.BlockTimeLimit = time.Duration(ledger.BlockTimeLimitSeconds) * time.Second
+	merged.CreditsPerKm = ledger.CreditsPerKm
+	merged.MinTripDistanceKm = ledger.MinTripDistanceKm
+	merged.MaxTripDistanceKm = ledger.MaxTripDistanceKm
+	merged.OperatorMSPs = ledger.OperatorMSPs
+	return &merged
+}
+
+// Validate verifica se todos os campos têm valores utilizáveis
+func (l LedgerConfig) Validate() error {
+	var problems []string
+	if l.MaxTransactionsPerBlock < 1 {
+		problems = append(problems, "o máximo de transações por bloco deve ser positivo")
+	}
+	if l.BlockTimeLimitSeconds < 1 {
+		problems = append(problems, "o limite de tempo do bloco deve ser de pelo menos 1 segundo")
+	}
+	if l.CreditsPerKm < 0 {
+		problems = append(problems, "os créditos por km não podem ser negativos")
+	}
+	if l.MinTripDistanceKm < 0 {
+		problems = append(problems, "a distância mínima da viagem não pode ser negativa")
+	}
+	if l.MaxTripDistanceKm < 0 || (l.MaxTripDistanceKm > 0 && l.MaxTripDistanceKm < l.MinTripDistanceKm) {
+		problems = append(problems, "a distância máxima da viagem deve ser zero ou maior que a mínima")
+	}
+	if len(l.OperatorMSPs) == 0 {
+		problems = append(problems, "a lista de MSPs de operadora não pode ser vazia")
+	}
+	for _, mspID := range l.OperatorMSPs {
+		if strings.TrimSpace(mspID) == "" {
+			problems = append(problems, "os MSPs de operadora não podem ser vazios")
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("configuração inválida: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
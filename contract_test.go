@@ -0,0 +1,92 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/txerror"
+)
+
+// operator são os atributos da identidade da operadora
+var operator = map[string]string{"role": "operator"}
+
+// newContract cria o contrato em memória, configurado com o Org1MSP como o único MSP de
+// operadora, e usa uma identidade de operadora do Org1MSP
+func newContract(t *testing.T) *mock.Backend {
+	t.Helper()
+	contract, err := mock.New("Org1MSP")
+	if err != nil {
+		t.Fatalf("mock.New: %v", err)
+	}
+	if err := contract.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	setIdentity(t, contract, "Org1MSP", operator)
+	return contract
+}
+
+func setIdentity(t *testing.T, contract *mock.Backend, mspID string, attributes map[string]string) {
+	t.Helper()
+	if err := contract.SetIdentity(mspID, attributes); err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+}
+
+// submit executa a transação e falha o teste se ela falhar. Se out não for nil, o
+// resultado é deserializado nele; o contractapi devolve as listas nil sem resultado, e
+// nesse caso out fica como está.
+func submit(t *testing.T, contract *mock.Backend, out interface{}, name string, args ...string) {
+	t.Helper()
+	payload, err := contract.Submit(name, args...)
+	if err != nil {
+		t.Fatalf("%s %q: %v", name, args, err)
+	}
+	if out != nil && len(payload) > 0 {
+		if err := json.Unmarshal(payload, out); err != nil {
+			t.Fatalf("%s: resultado %s: %v", name, payload, err)
+		}
+	}
+}
+
+// evaluate é submit para consultas: as alterações da transação são descartadas
+func evaluate(t *testing.T, contract *mock.Backend, out interface{}, name string, args ...string) {
+	t.Helper()
+	payload, err := contract.Evaluate(name, args...)
+	if err != nil {
+		t.Fatalf("%s %q: %v", name, args, err)
+	}
+	if out != nil && len(payload) > 0 {
+		if err := json.Unmarshal(payload, out); err != nil {
+			t.Fatalf("%s: resultado %s: %v", name, payload, err)
+		}
+	}
+}
+
+// wantError executa a transação e falha o teste se ela não falhar com o código informado
+func wantError(t *testing.T, contract *mock.Backend, code txerror.Code, name string, args ...string) {
+	t.Helper()
+	_, err := contract.Submit(name, args...)
+	if err == nil {
+		t.Fatalf("%s %q não falhou, quer %s", name, args, code)
+	}
+	if txerror.CodeOf(err) != code {
+		t.Fatalf("%s %q: %v, quer %s", name, args, err, code)
+	}
+}
+
+// createTrip grava uma viagem de chave simples
+func createTrip(t *testing.T, contract *mock.Backend, id string, departure string, distanceKm string, arrival string) {
+	t.Helper()
+	submit(t, contract, nil, "CreateTripData", id, departure, distanceKm, "1", arrival)
+}
+
+// toJSON serializa value para os argumentos das transações que recebem JSON
+func toJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(valueJSON)
+}
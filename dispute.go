@@ -0,0 +1,406 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipos de objeto das contestações, do índice de contestações por viagem
+// (dispute-trip/<viagem>/<contestação>) e das versões corrigidas dos dados de viagem
+const (
+	disputeObjectType     = "dispute"
+	disputeTripObjectType = "dispute-trip"
+	tripVersionObjectType = "trip-version"
+)
+
+// Estados de uma contestação: open → under_review → accepted ou rejected
+const (
+	DisputeOpen        = "open"
+	DisputeUnderReview = "under_review"
+	DisputeAccepted    = "accepted"
+	DisputeRejected    = "rejected"
+)
+
+// Dispute é a contestação de um ciclista sobre os dados de uma viagem
+type Dispute struct {
+	ID           string `json:"ID"`
+	TripDataID   string `json:"TripDataID"`
+	Reason       string `json:"Reason"`
+	EvidenceHash string `json:"EvidenceHash"`
+	Status       string `json:"Status"`
+	OpenedBy     string `json:"OpenedBy"`
+	OpenedAt     string `json:"OpenedAt"`
+	ReviewedBy   string `json:"ReviewedBy,omitempty" metadata:",optional"`
+	ResolvedAt   string `json:"ResolvedAt,omitempty" metadata:",optional"`
+	Resolution   string `json:"Resolution,omitempty" metadata:",optional"`
+	// AmendedVersion é a versão dos dados de viagem criada quando a contestação é aceita
+	AmendedVersion int `json:"AmendedVersion,omitempty" metadata:",optional"`
+}
+
+// TripDataVersion é uma versão dos dados de viagem. A versão 0 é o registro original,
+// que nunca é alterado por uma contestação; as seguintes são as correções aceitas.
+type TripDataVersion struct {
+	Version   int      `json:"Version"`
+	DisputeID string   `json:"DisputeID,omitempty" metadata:",optional"`
+	CreatedAt string   `json:"CreatedAt,omitempty" metadata:",optional"`
+	TripData  TripData `json:"TripData"`
+}
+
+// OpenDispute abre uma contestação sobre os dados de viagem. evidenceHash é o SHA-256,
+// em hexadecimal, da evidência guardada fora do livro-razão. Só o ciclista da viagem (o
+// RiderID dela ou, sem ele, o ciclista que recebeu os créditos da viagem, como em
+// CalculateFare) ou a operadora podem contestá-la.
+func (tc *TripContract) OpenDispute(ctx txcontext.TransactionContextInterface, id string, tripDataID string, reason string, evidenceHash string) (*Dispute, error) {
+	if reason == "" {
+		return nil, txerror.Validation("o motivo da contestação é obrigatório")
+	}
+	if decoded, err := hex.DecodeString(evidenceHash); err != nil || len(decoded) != 32 {
+		return nil, txerror.Validation("o hash da evidência deve ser um SHA-256 em hexadecimal")
+	}
+	tripData, err := tc.ReadTripData(ctx, tripDataID)
+	if err != nil {
+		return nil, err
+	}
+	riderID := tripData.RiderID
+	if riderID == "" {
+		riderID, err = tripCreditRider(ctx, tripDataID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := requireRiderOrOperator(ctx, riderID); err != nil {
+		return nil, err
+	}
+	if _, err := readDispute(ctx, id); err == nil {
+		return nil, txerror.AlreadyExists("a contestação %s já existe", id)
+	} else if txerror.CodeOf(err) != txerror.CodeNotFound {
+		return nil, err
+	}
+
+	disputes, err := tc.GetDisputesByTrip(ctx, tripDataID)
+	if err != nil {
+		return nil, err
+	}
+	for _, dispute := range disputes {
+		if dispute.Status == DisputeOpen || dispute.Status == DisputeUnderReview {
+			return nil, txerror.AlreadyExists("os dados de viagem %s já têm a contestação %s em andamento", tripDataID, dispute.ID)
+		}
+	}
+
+	openedBy := ctx.CallerID()
+	now := ctx.TxTime()
+
+	dispute := &Dispute{
+		ID:           id,
+		TripDataID:   tripDataID,
+		Reason:       reason,
+		EvidenceHash: evidenceHash,
+		Status:       DisputeOpen,
+		OpenedBy:     openedBy,
+		OpenedAt:     now.Format(time.RFC3339),
+	}
+	if err := putDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(disputeTripObjectType, []string{tripDataID, id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar o índice da contestação")
+	}
+	// O valor só marca a existência da chave; o Fabric não grava valores vazios
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar o índice da contestação no estado mundial")
+	}
+
+	return dispute, nil
+}
+
+// ReadDispute retorna a contestação com o ID fornecido.
+func (tc *TripContract) ReadDispute(ctx txcontext.TransactionContextInterface, id string) (*Dispute, error) {
+	return readDispute(ctx, id)
+}
+
+// GetDisputesByTrip retorna todas as contestações sobre os dados de viagem, em qualquer
+// estado, na ordem dos IDs.
+func (tc *TripContract) GetDisputesByTrip(ctx txcontext.TransactionContextInterface, tripDataID string) ([]*Dispute, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(disputeTripObjectType, []string{tripDataID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter contestações")
+	}
+	defer resultsIterator.Close()
+
+	var disputes []*Dispute
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return nil, txerror.Internal("chave de índice de contestação inválida %q", queryResponse.Key)
+		}
+
+		dispute, err := readDispute(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, nil
+}
+
+// StartDisputeReview coloca a contestação em revisão. Só a operadora pode revisar contestações.
+func (tc *TripContract) StartDisputeReview(ctx txcontext.TransactionContextInterface, id string) (*Dispute, error) {
+	dispute, err := transitionDispute(ctx, id, DisputeOpen, DisputeUnderReview)
+	if err != nil {
+		return nil, err
+	}
+	if err := putDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+// AcceptDispute aceita a contestação e grava uma nova versão dos dados de viagem com a
+// distância corrigida, ligada à contestação, e com as regras de anomalia reavaliadas.
+// O registro original não é alterado.
+func (tc *TripContract) AcceptDispute(ctx txcontext.TransactionContextInterface, id string, amendedDistanceKm float64, resolution string) (*Dispute, error) {
+	if amendedDistanceKm < 0 {
+		return nil, txerror.Validation("a distância corrigida não pode ser negativa")
+	}
+	dispute, err := transitionDispute(ctx, id, DisputeUnderReview, DisputeAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := tc.ReadLatestTripData(ctx, dispute.TripDataID)
+	if err != nil {
+		return nil, err
+	}
+	amended := *latest
+	amended.TotalDistanceKm = amendedDistanceKm
+	// As regras de anomalia são reavaliadas com a distância corrigida
+	if err := unflagTripData(ctx, latest); err != nil {
+		return nil, err
+	}
+	if err := flagTripData(ctx, &amended); err != nil {
+		return nil, err
+	}
+
+	versions, err := tc.GetTripDataVersions(ctx, dispute.TripDataID)
+	if err != nil {
+		return nil, err
+	}
+	version := &TripDataVersion{
+		Version:   len(versions),
+		DisputeID: dispute.ID,
+		CreatedAt: dispute.ResolvedAt,
+		TripData:  amended,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(tripVersionObjectType, []string{dispute.TripDataID, versionKey(version.Version)})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da versão dos dados de viagem")
+	}
+	versionJSON, err := json.Marshal(version)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter a versão dos dados de viagem para JSON")
+	}
+	err = ctx.GetStub().PutState(key, versionJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar a versão dos dados de viagem no estado mundial")
+	}
+	if err := updateAggregates(ctx, latest, &amended); err != nil {
+		return nil, err
+	}
+
+	dispute.Resolution = resolution
+	dispute.AmendedVersion = version.Version
+	if err := putDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+// RejectDispute rejeita a contestação sem alterar os dados de viagem.
+func (tc *TripContract) RejectDispute(ctx txcontext.TransactionContextInterface, id string, resolution string) (*Dispute, error) {
+	if resolution == "" {
+		return nil, txerror.Validation("o motivo da rejeição é obrigatório")
+	}
+	dispute, err := transitionDispute(ctx, id, DisputeUnderReview, DisputeRejected)
+	if err != nil {
+		return nil, err
+	}
+	dispute.Resolution = resolution
+	if err := putDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+// GetTripDataVersions retorna o registro original dos dados de viagem (versão 0) seguido
+// das versões corrigidas por contestações aceitas, em ordem.
+func (tc *TripContract) GetTripDataVersions(ctx txcontext.TransactionContextInterface, id string) ([]*TripDataVersion, error) {
+	original, err := tc.ReadTripData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	versions := []*TripDataVersion{{Version: 0, TripData: *original}}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripVersionObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as versões dos dados de viagem")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var version TripDataVersion
+		err = json.Unmarshal(queryResponse.Value, &version)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da versão dos dados de viagem")
+		}
+		versions = append(versions, &version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+	return versions, nil
+}
+
+// ReadLatestTripData retorna a versão mais recente dos dados de viagem: a última correção
+// aceita ou, se não houver nenhuma, o registro original.
+func (tc *TripContract) ReadLatestTripData(ctx txcontext.TransactionContextInterface, id string) (*TripData, error) {
+	versions, err := tc.GetTripDataVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &versions[len(versions)-1].TripData, nil
+}
+
+// latestTripVersion é ReadLatestTripData para uma viagem já lida: retorna a última correção
+// aceita ou, se não houver nenhuma, a própria viagem.
+func latestTripVersion(ctx txcontext.TransactionContextInterface, tripData *TripData) (*TripData, error) {
+	amended, _, err := amendedTripVersion(ctx, tripRef(tripData))
+	if err != nil {
+		return nil, err
+	}
+	if amended == nil {
+		return tripData, nil
+	}
+
+	return amended, nil
+}
+
+// amendedTripVersion retorna a última correção aceita da viagem com a referência ref e o
+// registro dela gravado no estado mundial, ou nil se a viagem nunca foi corrigida
+func amendedTripVersion(ctx txcontext.TransactionContextInterface, ref string) (*TripData, []byte, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripVersionObjectType, []string{ref})
+	if err != nil {
+		return nil, nil, txerror.Wrap(err, "falha ao obter as versões dos dados de viagem")
+	}
+	defer resultsIterator.Close()
+
+	// As chaves das versões estão em ordem de versão (veja versionKey)
+	var latestJSON []byte
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		latestJSON = queryResponse.Value
+	}
+	if latestJSON == nil {
+		return nil, nil, nil
+	}
+
+	var version TripDataVersion
+	err = json.Unmarshal(latestJSON, &version)
+	if err != nil {
+		return nil, nil, txerror.Wrap(err, "falha ao fazer unmarshal da versão dos dados de viagem")
+	}
+
+	return &version.TripData, latestJSON, nil
+}
+
+// transitionDispute confere o papel da operadora e o estado atual da contestação e a
+// move para o próximo estado, registrando quem a revisou. Não grava a contestação.
+func transitionDispute(ctx txcontext.TransactionContextInterface, id string, from string, to string) (*Dispute, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	dispute, err := readDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != from {
+		return nil, txerror.Validation("a contestação %s está em %s e não pode passar para %s", id, dispute.Status, to)
+	}
+
+	reviewedBy := ctx.CallerID()
+	dispute.Status = to
+	dispute.ReviewedBy = reviewedBy
+	if to == DisputeAccepted || to == DisputeRejected {
+		now := ctx.TxTime()
+		dispute.ResolvedAt = now.Format(time.RFC3339)
+	}
+
+	return dispute, nil
+}
+
+func readDispute(ctx txcontext.TransactionContextInterface, id string) (*Dispute, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(disputeObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da contestação")
+	}
+	disputeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if disputeJSON == nil {
+		return nil, txerror.NotFound("a contestação %s não existe", id)
+	}
+
+	var dispute Dispute
+	err = json.Unmarshal(disputeJSON, &dispute)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da contestação")
+	}
+
+	return &dispute, nil
+}
+
+func putDispute(ctx txcontext.TransactionContextInterface, dispute *Dispute) error {
+	key, err := ctx.GetStub().CreateCompositeKey(disputeObjectType, []string{dispute.ID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da contestação")
+	}
+	disputeJSON, err := json.Marshal(dispute)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter contestação para JSON")
+	}
+	err = ctx.GetStub().PutState(key, disputeJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a contestação no estado mundial")
+	}
+
+	return nil
+}
+
+// versionKey completa o número da versão com zeros para que as chaves fiquem em ordem
+func versionKey(version int) string {
+	return fmt.Sprintf("%06d", version)
+}
@@ -0,0 +1,101 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+// evidenceHash é o SHA-256 de "x"
+const evidenceHash = "2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881"
+
+func TestAcceptDispute(t *testing.T) {
+	contract := newContract(t)
+	var ledger map[string]interface{}
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger["CreditsPerKm"] = 1
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	submit(t, contract, nil, "AwardTripCredits", "t1", "rider1")
+
+	// Só o ciclista que recebeu os créditos da viagem a contesta
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider2"})
+	wantError(t, contract, txerror.CodeUnauthorized, "OpenDispute", "d1", "t1", "distância errada", evidenceHash)
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	var dispute chaincode.Dispute
+	submit(t, contract, &dispute, "OpenDispute", "d1", "t1", "distância errada", evidenceHash)
+	if dispute.Status != chaincode.DisputeOpen || dispute.TripDataID != "t1" {
+		t.Errorf("contestação = %+v", dispute)
+	}
+	wantError(t, contract, txerror.CodeAlreadyExists, "OpenDispute", "d2", "t1", "de novo", evidenceHash)
+	wantError(t, contract, txerror.CodeUnauthorized, "StartDisputeReview", "d1")
+
+	setIdentity(t, contract, "Org1MSP", operator)
+	submit(t, contract, nil, "StartDisputeReview", "d1")
+	submit(t, contract, &dispute, "AcceptDispute", "d1", "2.5", "corrigida pelo GPS")
+	if dispute.Status != chaincode.DisputeAccepted || dispute.AmendedVersion != 1 || dispute.ResolvedAt == "" {
+		t.Errorf("contestação aceita = %+v", dispute)
+	}
+
+	var versions []*chaincode.TripDataVersion
+	evaluate(t, contract, &versions, "GetTripDataVersions", "t1")
+	if len(versions) != 2 || versions[0].TripData.TotalDistanceKm != 4 || versions[1].TripData.TotalDistanceKm != 2.5 || versions[1].DisputeID != "d1" {
+		t.Errorf("versões = %+v", versions)
+	}
+	var latest chaincode.TripData
+	evaluate(t, contract, &latest, "ReadLatestTripData", "t1")
+	if latest.TotalDistanceKm != 2.5 {
+		t.Errorf("distância da versão mais recente = %v, quer 2.5", latest.TotalDistanceKm)
+	}
+	var original chaincode.TripData
+	evaluate(t, contract, &original, "ReadTripData", "t1")
+	if original.TotalDistanceKm != 4 {
+		t.Errorf("distância do registro original = %v, quer 4", original.TotalDistanceKm)
+	}
+
+	// Corrigida por uma contestação, a viagem só é corrigida de novo por outra
+	wantError(t, contract, txerror.CodeValidation, "UpdateTripData", "t1", "2024-03-04T08:00:00Z", "6", "1", "2024-03-04T08:20:00Z")
+
+	// Resolvida a primeira, a viagem pode ser contestada de novo
+	submit(t, contract, nil, "OpenDispute", "d2", "t1", "ainda errada", evidenceHash)
+	submit(t, contract, nil, "StartDisputeReview", "d2")
+	submit(t, contract, &dispute, "RejectDispute", "d2", "sem evidência")
+	if dispute.Status != chaincode.DisputeRejected {
+		t.Errorf("contestação rejeitada = %+v", dispute)
+	}
+	var disputes []*chaincode.Dispute
+	evaluate(t, contract, &disputes, "GetDisputesByTrip", "t1")
+	if len(disputes) != 2 || disputes[0].ID != "d1" || disputes[1].ID != "d2" {
+		t.Errorf("contestações de t1 = %+v", disputes)
+	}
+}
+
+func TestDisputeErrors(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	submit(t, contract, nil, "OpenDispute", "d1", "t1", "distância errada", evidenceHash)
+
+	wantError(t, contract, txerror.CodeValidation, "OpenDispute", "d2", "t1", "", evidenceHash)
+	wantError(t, contract, txerror.CodeValidation, "OpenDispute", "d2", "t1", "errada", "abc")
+	wantError(t, contract, txerror.CodeNotFound, "OpenDispute", "d2", "t9", "errada", evidenceHash)
+	wantError(t, contract, txerror.CodeAlreadyExists, "OpenDispute", "d1", "t1", "errada", evidenceHash)
+	wantError(t, contract, txerror.CodeValidation, "AcceptDispute", "d1", "2", "ok")
+	wantError(t, contract, txerror.CodeValidation, "AcceptDispute", "d1", "-1", "ok")
+	wantError(t, contract, txerror.CodeNotFound, "StartDisputeReview", "d9")
+	wantError(t, contract, txerror.CodeNotFound, "ReadDispute", "d9")
+
+	submit(t, contract, nil, "StartDisputeReview", "d1")
+	wantError(t, contract, txerror.CodeValidation, "RejectDispute", "d1", "")
+	wantError(t, contract, txerror.CodeValidation, "StartDisputeReview", "d1")
+
+	// A viagem com RiderID só é contestada pelo ciclista dela, e a sem ciclista só pela operadora
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"t2","TripID":2,"Departure_Datetime":"2024-03-04 09:00:00","Arrival_Datetime":"2024-03-04 09:10:00","totalDistance_km":1,"RiderID":"rider1"}`)
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider2"})
+	wantError(t, contract, txerror.CodeUnauthorized, "OpenDispute", "d2", "opa:t2", "errada", evidenceHash)
+	createTrip(t, contract, "t3", "2024-03-04T10:00:00Z", "4", "2024-03-04T10:20:00Z")
+	wantError(t, contract, txerror.CodeUnauthorized, "OpenDispute", "d2", "t3", "errada", evidenceHash)
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	submit(t, contract, nil, "OpenDispute", "d2", "opa:t2", "errada", evidenceHash)
+}
@@ -0,0 +1,352 @@
+// Package export grava as viagens do livro-razão e os resumos diários em CSV,
+// JSON por linha (JSON Lines) e GeoJSON, para análise em planilhas e SIG, e os
+// acertos entre organizações em CSV e JSON.
+//
+// As viagens são lidas do JSON devolvido pelo contrato uma a uma e escritas assim que
+// são lidas, sem montar a lista completa em memória; movectl busca o livro-razão página
+// a página com GetTripDataPage. As colunas têm ordem fixa
+// (TripColumns e SummaryColumns) para que as planilhas dos analistas não quebrem
+// quando o contrato ganhar campos novos.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Chaincodemove/source"
+)
+
+// Formatos de exportação
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+	FormatGeoJSON   = "geojson"
+)
+
+// TripColumns é a ordem das colunas das viagens exportadas
+var TripColumns = []string{"ID", "TripID", "RiderID", "Departure_Datetime", "Arrival_Datetime", "totalDistance_km", "DepartureSlot", "OperatorID"}
+
+// SummaryColumns é a ordem das colunas dos resumos diários exportados
+var SummaryColumns = []string{"Date", "Trips", "totalDistance_km"}
+
+// ErrUnsupportedFormat indica um formato que não se aplica ao que está sendo exportado
+var ErrUnsupportedFormat = errors.New("formato de exportação não suportado")
+
+// Trip é uma viagem como gravada no livro-razão
+type Trip struct {
+	ID                string  `json:"ID"`
+	TripID            int     `json:"TripID"`
+	RiderID           string  `json:"RiderID"`
+	DepartureDatetime string  `json:"Departure_Datetime"`
+	ArrivalDatetime   string  `json:"Arrival_Datetime"`
+	TotalDistanceKm   float64 `json:"totalDistance_km"`
+	DepartureSlot     string  `json:"DepartureSlot"`
+	// OperatorID é vazio nas viagens gravadas em chave simples
+	OperatorID string `json:"OperatorID"`
+}
+
+func (t Trip) record() []string {
+	return []string{
+		t.ID,
+		strconv.Itoa(t.TripID),
+		t.RiderID,
+		t.DepartureDatetime,
+		t.ArrivalDatetime,
+		strconv.FormatFloat(t.TotalDistanceKm, 'f', -1, 64),
+		t.DepartureSlot,
+		t.OperatorID,
+	}
+}
+
+// DailySummary reúne as viagens que partiram num mesmo dia
+type DailySummary struct {
+	Date            string  `json:"Date"`
+	Trips           int     `json:"Trips"`
+	TotalDistanceKm float64 `json:"totalDistance_km"`
+}
+
+func (s DailySummary) record() []string {
+	return []string{s.Date, strconv.Itoa(s.Trips), strconv.FormatFloat(s.TotalDistanceKm, 'f', -1, 64)}
+}
+
+// DecodeTrips lê uma lista JSON de viagens e chama fn para cada viagem dentro da janela.
+// Uma janela vazia não filtra nada. Uma resposta vazia ou null é tratada como lista vazia.
+func DecodeTrips(r io.Reader, window source.Window, fn func(Trip) error) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao ler as viagens: %v", err)
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("falha ao ler as viagens: esperava uma lista JSON")
+	}
+
+	filter := window.From != "" || window.To != ""
+	for decoder.More() {
+		var trip Trip
+		if err := decoder.Decode(&trip); err != nil {
+			return fmt.Errorf("falha ao ler as viagens: %v", err)
+		}
+		if filter && !window.Contains(trip.DepartureDatetime) {
+			continue
+		}
+		if err := fn(trip); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("falha ao ler as viagens: %v", err)
+	}
+
+	return nil
+}
+
+// TripWriter escreve viagens num formato de exportação. Close termina o documento,
+// mas não fecha o io.Writer de destino.
+type TripWriter interface {
+	WriteTrip(trip Trip) error
+	Close() error
+}
+
+// NewTripWriter cria o TripWriter do formato pedido. slots só é usado em GeoJSON.
+func NewTripWriter(w io.Writer, format string, slots SlotLocations) (TripWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, TripColumns)
+	case FormatJSONLines:
+		return &jsonLinesWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatGeoJSON:
+		if slots == nil {
+			return nil, fmt.Errorf("%w: GeoJSON exige as coordenadas dos slots", ErrUnsupportedFormat)
+		}
+		return newGeoJSONWriter(w, slots)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// WriteSummaries escreve os resumos diários em CSV ou JSON Lines
+func WriteSummaries(w io.Writer, format string, summaries []DailySummary) error {
+	switch format {
+	case FormatCSV:
+		cw, err := newCSVWriter(w, SummaryColumns)
+		if err != nil {
+			return err
+		}
+		for _, summary := range summaries {
+			if err := cw.write(summary.record()); err != nil {
+				return err
+			}
+		}
+		return cw.Close()
+	case FormatJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, summary := range summaries {
+			if err := encoder.Encode(summary); err != nil {
+				return fmt.Errorf("falha ao escrever o resumo: %v", err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w para resumos: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// Summarizer acumula os resumos diários das viagens recebidas. Só guarda um total
+// por dia, então pode ser usado junto com DecodeTrips em exportações grandes.
+type Summarizer struct {
+	days map[string]*DailySummary
+}
+
+// NewSummarizer cria um Summarizer vazio
+func NewSummarizer() *Summarizer {
+	return &Summarizer{days: map[string]*DailySummary{}}
+}
+
+// Add soma a viagem ao resumo do dia de partida
+func (s *Summarizer) Add(trip Trip) {
+	date := trip.DepartureDatetime
+	if len(date) >= len(source.DateLayout) {
+		date = date[:len(source.DateLayout)]
+	}
+	summary, ok := s.days[date]
+	if !ok {
+		summary = &DailySummary{Date: date}
+		s.days[date] = summary
+	}
+	summary.Trips++
+	summary.TotalDistanceKm += trip.TotalDistanceKm
+}
+
+// Summaries retorna os resumos ordenados por data
+func (s *Summarizer) Summaries() []DailySummary {
+	summaries := make([]DailySummary, 0, len(s.days))
+	for _, summary := range s.days {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Date < summaries[j].Date })
+	return summaries
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, header []string) (*csvWriter, error) {
+	cw := &csvWriter{writer: csv.NewWriter(w)}
+	if err := cw.write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteTrip(trip Trip) error {
+	return cw.write(trip.record())
+}
+
+func (cw *csvWriter) write(record []string) error {
+	if err := cw.writer.Write(record); err != nil {
+		return fmt.Errorf("falha ao escrever o CSV: %v", err)
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.writer.Flush()
+	if err := cw.writer.Error(); err != nil {
+		return fmt.Errorf("falha ao escrever o CSV: %v", err)
+	}
+	return nil
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func (jw *jsonLinesWriter) WriteTrip(trip Trip) error {
+	if err := jw.encoder.Encode(trip); err != nil {
+		return fmt.Errorf("falha ao escrever a viagem: %v", err)
+	}
+	return nil
+}
+
+func (jw *jsonLinesWriter) Close() error {
+	return nil
+}
+
+// geoJSONWriter escreve uma FeatureCollection feature a feature. Viagens cujo slot de
+// partida não tem coordenadas conhecidas são escritas com geometria nula.
+type geoJSONWriter struct {
+	w        io.Writer
+	slots    SlotLocations
+	features int
+}
+
+type geoJSONFeature struct {
+	Type       string           `json:"type"`
+	Geometry   *geoJSONGeometry `json:"geometry"`
+	Properties Trip             `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func newGeoJSONWriter(w io.Writer, slots SlotLocations) (*geoJSONWriter, error) {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`); err != nil {
+		return nil, fmt.Errorf("falha ao escrever o GeoJSON: %v", err)
+	}
+	return &geoJSONWriter{w: w, slots: slots}, nil
+}
+
+func (gw *geoJSONWriter) WriteTrip(trip Trip) error {
+	feature := geoJSONFeature{Type: "Feature", Properties: trip}
+	if location, ok := gw.slots[trip.DepartureSlot]; ok && trip.DepartureSlot != "" {
+		// GeoJSON usa a ordem longitude, latitude
+		feature.Geometry = &geoJSONGeometry{Type: "Point", Coordinates: [2]float64{location.Longitude, location.Latitude}}
+	}
+	featureJSON, err := json.Marshal(feature)
+	if err != nil {
+		return fmt.Errorf("falha ao converter a viagem para GeoJSON: %v", err)
+	}
+
+	separator := "\n"
+	if gw.features > 0 {
+		separator = ",\n"
+	}
+	if _, err := io.WriteString(gw.w, separator); err != nil {
+		return fmt.Errorf("falha ao escrever o GeoJSON: %v", err)
+	}
+	if _, err := gw.w.Write(featureJSON); err != nil {
+		return fmt.Errorf("falha ao escrever o GeoJSON: %v", err)
+	}
+	gw.features++
+	return nil
+}
+
+func (gw *geoJSONWriter) Close() error {
+	if _, err := io.WriteString(gw.w, "\n]}\n"); err != nil {
+		return fmt.Errorf("falha ao escrever o GeoJSON: %v", err)
+	}
+	return nil
+}
+
+// Location é a posição geográfica de um slot de partida
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// SlotLocations associa cada slot de partida às suas coordenadas
+type SlotLocations map[string]Location
+
+// LoadSlotLocations lê as coordenadas dos slots de um CSV com cabeçalho
+// slot,latitude,longitude.
+func LoadSlotLocations(r io.Reader) (SlotLocations, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler as coordenadas dos slots: %v", err)
+	}
+	if !strings.EqualFold(header[0], "slot") || !strings.EqualFold(header[1], "latitude") || !strings.EqualFold(header[2], "longitude") {
+		return nil, fmt.Errorf("cabeçalho inválido nas coordenadas dos slots: esperava slot,latitude,longitude")
+	}
+
+	slots := SlotLocations{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler as coordenadas dos slots: %v", err)
+		}
+		latitude, err := strconv.ParseFloat(record[1], 64)
+		if err != nil || latitude < -90 || latitude > 90 {
+			return nil, fmt.Errorf("latitude inválida para o slot %s: %q", record[0], record[1])
+		}
+		longitude, err := strconv.ParseFloat(record[2], 64)
+		if err != nil || longitude < -180 || longitude > 180 {
+			return nil, fmt.Errorf("longitude inválida para o slot %s: %q", record[0], record[2])
+		}
+		slots[record[0]] = Location{Latitude: latitude, Longitude: longitude}
+	}
+
+	return slots, nil
+}
@@ -0,0 +1,187 @@
+package export_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"Chaincodemove/export"
+	"Chaincodemove/source"
+)
+
+const tripsJSON = `[
+	{"ID":"t1","TripID":1,"RiderID":"r1","Departure_Datetime":"2024-03-04 08:00:00","Arrival_Datetime":"2024-03-04 08:20:00","totalDistance_km":4.5,"DepartureSlot":"s1"},
+	{"ID":"t2","TripID":2,"Departure_Datetime":"2024-03-05 09:00:00","Arrival_Datetime":"2024-03-05 09:10:00","totalDistance_km":2,"DepartureSlot":"s9","OperatorID":"opa"}
+]`
+
+func TestDecodeTrips(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		window source.Window
+		want   []string
+		ok     bool
+	}{
+		{"lista", tripsJSON, source.Window{}, []string{"t1", "t2"}, true},
+		{"janela", tripsJSON, source.Window{From: "2024-03-05", To: "2024-03-05"}, []string{"t2"}, true},
+		{"vazia", "", source.Window{}, nil, true},
+		{"null", "null", source.Window{}, nil, true},
+		{"lista vazia", "[]", source.Window{}, nil, true},
+		{"JSON inválido", "{", source.Window{}, nil, false},
+		{"não é lista", `{"ID":"t1"}`, source.Window{}, nil, false},
+		{"viagem inválida", `[{"TripID":"um"}]`, source.Window{}, nil, false},
+		{"lista incompleta", `[{"ID":"t1"}`, source.Window{}, nil, false},
+		{"lixo", "nul", source.Window{}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := export.DecodeTrips(strings.NewReader(tt.input), tt.window, func(trip export.Trip) error {
+				got = append(got, trip.ID)
+				return nil
+			})
+			if (err == nil) != tt.ok {
+				t.Fatalf("DecodeTrips: %v", err)
+			}
+			if tt.ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("viagens = %v, quer %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTripsStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("parar")
+	calls := 0
+	err := export.DecodeTrips(strings.NewReader(tripsJSON), source.Window{}, func(export.Trip) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("DecodeTrips = %v depois de %d chamadas, quer %v depois de 1", err, calls, stop)
+	}
+}
+
+func TestTripWriter(t *testing.T) {
+	slots := export.SlotLocations{"s1": {Latitude: -22.9, Longitude: -43.1}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{export.FormatCSV, "ID,TripID,RiderID,Departure_Datetime,Arrival_Datetime,totalDistance_km,DepartureSlot,OperatorID\n" +
+			"t1,1,r1,2024-03-04 08:00:00,2024-03-04 08:20:00,4.5,s1,\n" +
+			"t2,2,,2024-03-05 09:00:00,2024-03-05 09:10:00,2,s9,opa\n"},
+		{export.FormatJSONLines, `{"ID":"t1","TripID":1,"RiderID":"r1","Departure_Datetime":"2024-03-04 08:00:00","Arrival_Datetime":"2024-03-04 08:20:00","totalDistance_km":4.5,"DepartureSlot":"s1","OperatorID":""}` + "\n" +
+			`{"ID":"t2","TripID":2,"RiderID":"","Departure_Datetime":"2024-03-05 09:00:00","Arrival_Datetime":"2024-03-05 09:10:00","totalDistance_km":2,"DepartureSlot":"s9","OperatorID":"opa"}` + "\n"},
+		{export.FormatGeoJSON, `{"type":"FeatureCollection","features":[` + "\n" +
+			`{"type":"Feature","geometry":{"type":"Point","coordinates":[-43.1,-22.9]},"properties":{"ID":"t1","TripID":1,"RiderID":"r1","Departure_Datetime":"2024-03-04 08:00:00","Arrival_Datetime":"2024-03-04 08:20:00","totalDistance_km":4.5,"DepartureSlot":"s1","OperatorID":""}},` + "\n" +
+			`{"type":"Feature","geometry":null,"properties":{"ID":"t2","TripID":2,"RiderID":"","Departure_Datetime":"2024-03-05 09:00:00","Arrival_Datetime":"2024-03-05 09:10:00","totalDistance_km":2,"DepartureSlot":"s9","OperatorID":"opa"}}` + "\n" +
+			"]}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			writer, err := export.NewTripWriter(&out, tt.format, slots)
+			if err != nil {
+				t.Fatalf("NewTripWriter: %v", err)
+			}
+			err = export.DecodeTrips(strings.NewReader(tripsJSON), source.Window{}, writer.WriteTrip)
+			if err != nil {
+				t.Fatalf("DecodeTrips: %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("saída:\n%s\nquer:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTripWriterUnsupportedFormat(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		slots  export.SlotLocations
+	}{
+		{"xlsx", nil},
+		{export.FormatGeoJSON, nil},
+		{export.FormatJSON, export.SlotLocations{}},
+	} {
+		if _, err := export.NewTripWriter(&strings.Builder{}, tt.format, tt.slots); !errors.Is(err, export.ErrUnsupportedFormat) {
+			t.Errorf("NewTripWriter(%q): %v, quer ErrUnsupportedFormat", tt.format, err)
+		}
+	}
+}
+
+func TestSummarizer(t *testing.T) {
+	summarizer := export.NewSummarizer()
+	for _, trip := range []export.Trip{
+		{DepartureDatetime: "2024-03-05 09:00:00", TotalDistanceKm: 2},
+		{DepartureDatetime: "2024-03-04 08:00:00", TotalDistanceKm: 4.5},
+		{DepartureDatetime: "2024-03-05T18:00:00Z", TotalDistanceKm: 1},
+	} {
+		summarizer.Add(trip)
+	}
+
+	want := []export.DailySummary{
+		{Date: "2024-03-04", Trips: 1, TotalDistanceKm: 4.5},
+		{Date: "2024-03-05", Trips: 2, TotalDistanceKm: 3},
+	}
+	if got := summarizer.Summaries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("resumos = %+v, quer %+v", got, want)
+	}
+}
+
+func TestWriteSummaries(t *testing.T) {
+	summaries := []export.DailySummary{{Date: "2024-03-04", Trips: 1, TotalDistanceKm: 4.5}}
+	tests := []struct {
+		format string
+		want   string
+		ok     bool
+	}{
+		{export.FormatCSV, "Date,Trips,totalDistance_km\n2024-03-04,1,4.5\n", true},
+		{export.FormatJSONLines, `{"Date":"2024-03-04","Trips":1,"totalDistance_km":4.5}` + "\n", true},
+		{export.FormatGeoJSON, "", false},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		err := export.WriteSummaries(&out, tt.format, summaries)
+		if (err == nil) != tt.ok {
+			t.Errorf("WriteSummaries(%q): %v", tt.format, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("WriteSummaries(%q) = %q, quer %q", tt.format, out.String(), tt.want)
+		}
+	}
+}
+
+func TestLoadSlotLocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  export.SlotLocations
+		ok    bool
+	}{
+		{"válido", "slot,latitude,longitude\ns1,-22.9,-43.1\ns2, 10, 20\n", export.SlotLocations{"s1": {Latitude: -22.9, Longitude: -43.1}, "s2": {Latitude: 10, Longitude: 20}}, true},
+		{"cabeçalho maiúsculo", "Slot,Latitude,Longitude\n", export.SlotLocations{}, true},
+		{"vazio", "", nil, false},
+		{"cabeçalho inválido", "slot,lat,lon\n", nil, false},
+		{"colunas a menos", "slot,latitude,longitude\ns1,-22.9\n", nil, false},
+		{"latitude inválida", "slot,latitude,longitude\ns1,-91,-43.1\n", nil, false},
+		{"longitude inválida", "slot,latitude,longitude\ns1,-22.9,oeste\n", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := export.LoadSlotLocations(strings.NewReader(tt.input))
+			if (err == nil) != tt.ok {
+				t.Fatalf("LoadSlotLocations: %v", err)
+			}
+			if tt.ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slots = %+v, quer %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FormatJSON grava o documento inteiro como um único JSON indentado
+const FormatJSON = "json"
+
+// SettlementApproved é o estado do acerto aprovado por todas as organizações, o
+// demonstrativo final do período
+const SettlementApproved = "approved"
+
+// SettlementColumns é a ordem das colunas das linhas do acerto exportado
+var SettlementColumns = []string{"Period", "Revision", "Organisation", "Charges", "DistanceKm", "AmountCents", "Hash"}
+
+// SettlementLine são as cobranças de uma organização num acerto
+type SettlementLine struct {
+	Organisation string  `json:"Organisation"`
+	Charges      int     `json:"Charges"`
+	DistanceKm   float64 `json:"DistanceKm"`
+	AmountCents  int64   `json:"AmountCents"`
+}
+
+// SettlementSignoff é a aprovação ou contestação de um acerto por uma organização
+type SettlementSignoff struct {
+	MSP      string `json:"MSP"`
+	Decision string `json:"Decision"`
+	Reason   string `json:"Reason,omitempty"`
+	SignedBy string `json:"SignedBy"`
+	SignedAt string `json:"SignedAt"`
+}
+
+// Settlement é o demonstrativo do acerto entre organizações como gravado no livro-razão
+type Settlement struct {
+	Period      string              `json:"Period"`
+	Revision    int                 `json:"Revision"`
+	Status      string              `json:"Status"`
+	Lines       []SettlementLine    `json:"Lines"`
+	Charges     int                 `json:"Charges"`
+	DistanceKm  float64             `json:"DistanceKm"`
+	AmountCents int64               `json:"AmountCents"`
+	Hash        string              `json:"Hash"`
+	Parties     []string            `json:"Parties"`
+	Signoffs    []SettlementSignoff `json:"Signoffs"`
+	GeneratedBy string              `json:"GeneratedBy"`
+	GeneratedAt string              `json:"GeneratedAt"`
+	FinalizedAt string              `json:"FinalizedAt,omitempty"`
+}
+
+func (s Settlement) record(line SettlementLine) []string {
+	return []string{
+		s.Period,
+		strconv.Itoa(s.Revision),
+		line.Organisation,
+		strconv.Itoa(line.Charges),
+		strconv.FormatFloat(line.DistanceKm, 'f', -1, 64),
+		strconv.FormatInt(line.AmountCents, 10),
+		s.Hash,
+	}
+}
+
+// WriteSettlement escreve o acerto em JSON ou, uma linha por organização, em CSV. O CSV
+// leva o período, a revisão e o hash em todas as linhas para que cada uma possa ser
+// conferida com o livro-razão.
+func WriteSettlement(w io.Writer, format string, settlement Settlement) error {
+	switch format {
+	case FormatCSV:
+		cw, err := newCSVWriter(w, SettlementColumns)
+		if err != nil {
+			return err
+		}
+		for _, line := range settlement.Lines {
+			if err := cw.write(settlement.record(line)); err != nil {
+				return err
+			}
+		}
+		return cw.Close()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(settlement); err != nil {
+			return fmt.Errorf("falha ao escrever o acerto: %v", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w para acertos: %q", ErrUnsupportedFormat, format)
+	}
+}
@@ -0,0 +1,450 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipos de objeto das tarifas, das cobranças e do índice de cobranças por ciclista
+// (charge-rider/<ciclista>/<data de partida>/<viagem>)
+const (
+	tariffObjectType      = "tariff"
+	chargeObjectType      = "charge"
+	chargeRiderObjectType = "charge-rider"
+)
+
+// tariffBandLayout é o formato dos horários das faixas de tarifa
+const tariffBandLayout = "15:04"
+
+// tariffDateLayout é o formato de ValidFrom e dos períodos das consultas de cobranças
+const tariffDateLayout = "2006-01-02"
+
+// TariffBand multiplica a tarifa das viagens que partem entre Start (inclusive) e End
+// (exclusive), no formato hh:mm. Uma faixa com End antes de Start passa da meia-noite.
+type TariffBand struct {
+	Start      string  `json:"Start"`
+	End        string  `json:"End"`
+	Multiplier float64 `json:"Multiplier"`
+}
+
+// Tariff é uma tabela de preços das viagens, com valores em centavos. Tarifas não são
+// alteradas: uma nova tarifa com ValidFrom posterior substitui a anterior a partir dessa data.
+type Tariff struct {
+	ID             string `json:"ID"`
+	Name           string `json:"Name"`
+	ValidFrom      string `json:"ValidFrom"`
+	BaseFeeCents   int64  `json:"BaseFeeCents"`
+	PerKmCents     int64  `json:"PerKmCents"`
+	PerMinuteCents int64  `json:"PerMinuteCents"`
+	// MaxFareCents é o teto de uma viagem; zero não limita
+	MaxFareCents int64        `json:"MaxFareCents"`
+	Bands        []TariffBand `json:"Bands"`
+	CreatedBy    string       `json:"CreatedBy,omitempty" metadata:",optional"`
+}
+
+// Charge é a cobrança de uma viagem, com o detalhamento do cálculo pela tarifa em vigor
+// na partida
+type Charge struct {
+	TripDataID        string  `json:"TripDataID"`
+	RiderID           string  `json:"RiderID,omitempty" metadata:",optional"`
+	OwnerMSP          string  `json:"OwnerMSP,omitempty" metadata:",optional"`
+	TariffID          string  `json:"TariffID"`
+	DepartureDatetime string  `json:"DepartureDatetime"`
+	DistanceKm        float64 `json:"DistanceKm"`
+	DurationMinutes   int64   `json:"DurationMinutes"`
+	BaseFeeCents      int64   `json:"BaseFeeCents"`
+	DistanceCents     int64   `json:"DistanceCents"`
+	TimeCents         int64   `json:"TimeCents"`
+	Multiplier        float64 `json:"Multiplier"`
+	Capped            bool    `json:"Capped"`
+	// PassID é o passe que cobriu a viagem e PassCoveredCents, quanto dela ele cobriu;
+	// AmountCents é só o que sobra para o ciclista pagar
+	PassID           string `json:"PassID,omitempty" metadata:",optional"`
+	PassCoveredCents int64  `json:"PassCoveredCents,omitempty" metadata:",optional"`
+	AmountCents      int64  `json:"AmountCents"`
+	CalculatedAt     string `json:"CalculatedAt"`
+}
+
+// CreateTariff grava a tarifa fornecida em JSON. Só a operadora pode criar tarifas.
+func (tc *TripContract) CreateTariff(ctx txcontext.TransactionContextInterface, tariffJSON string) (*Tariff, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+
+	var tariff Tariff
+	err := json.Unmarshal([]byte(tariffJSON), &tariff)
+	if err != nil {
+		return nil, txerror.Validation("falha ao fazer unmarshal da tarifa: %v", err)
+	}
+	if err := tariff.validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(tariffObjectType, []string{tariff.ID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da tarifa")
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("a tarifa %s já existe", tariff.ID)
+	}
+
+	tariff.CreatedBy = ctx.CallerID()
+	storedJSON, err := json.Marshal(tariff)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter tarifa para JSON")
+	}
+	err = ctx.GetStub().PutState(key, storedJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar a tarifa no estado mundial")
+	}
+
+	return &tariff, nil
+}
+
+// ReadTariff retorna a tarifa com o ID fornecido.
+func (tc *TripContract) ReadTariff(ctx txcontext.TransactionContextInterface, id string) (*Tariff, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(tariffObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da tarifa")
+	}
+	tariffJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if tariffJSON == nil {
+		return nil, txerror.NotFound("a tarifa %s não existe", id)
+	}
+
+	var tariff Tariff
+	err = json.Unmarshal(tariffJSON, &tariff)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da tarifa")
+	}
+
+	return &tariff, nil
+}
+
+// GetAllTariffs retorna todas as tarifas, da mais antiga para a mais nova.
+func (tc *TripContract) GetAllTariffs(ctx txcontext.TransactionContextInterface) ([]*Tariff, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tariffObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter tarifas")
+	}
+	defer resultsIterator.Close()
+
+	var tariffs []*Tariff
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var tariff Tariff
+		err = json.Unmarshal(queryResponse.Value, &tariff)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da tarifa")
+		}
+		tariffs = append(tariffs, &tariff)
+	}
+	sort.SliceStable(tariffs, func(i, j int) bool { return tariffs[i].ValidFrom < tariffs[j].ValidFrom })
+
+	return tariffs, nil
+}
+
+// CalculateFare calcula a tarifa da viagem com a referência fornecida (o ID ou, nas
+// viagens das operadoras, <operadora>:<ID>) e grava a cobrança. Só a operadora cobra
+// viagens. O cálculo usa a versão mais recente da viagem, com as correções das
+// contestações aceitas (veja ReadLatestTripData). A tarifa usada é a de ValidFrom mais
+// recente até a data de partida, e o cálculo só depende dos dados da viagem e da tarifa,
+// então dá o mesmo resultado em todos os peers. Cada viagem é cobrada uma vez só. O ciclista da cobrança é o RiderID da viagem ou,
+// na falta dele, o ciclista que recebeu os créditos da viagem em AwardTripCredits.
+// Se o ciclista tiver um passe válido na partida, o passe cobre a viagem (veja IssuePass).
+//
+// Valor = (tarifa base + km × PerKmCents + minutos × PerMinuteCents) × multiplicador da
+// faixa de horário da partida, arredondado para o centavo e limitado a MaxFareCents.
+func (tc *TripContract) CalculateFare(ctx txcontext.TransactionContextInterface, tripID string) (*Charge, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	tripData, err := tc.ReadLatestTripData(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := readCharge(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("a viagem %s já foi cobrada", tripID)
+	}
+
+	departure, err := parseTripDatetime(tripData.DepartureDatetime)
+	if err != nil {
+		return nil, err
+	}
+	arrival, err := parseTripDatetime(tripData.ArrivalDatetime)
+	if err != nil {
+		return nil, err
+	}
+	if arrival.Before(departure) {
+		return nil, txerror.Validation("a chegada da viagem %s é anterior à partida", tripID)
+	}
+
+	tariffs, err := tc.GetAllTariffs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tariff *Tariff
+	for _, candidate := range tariffs {
+		if candidate.ValidFrom <= departure.Format(tariffDateLayout) {
+			tariff = candidate
+		}
+	}
+	if tariff == nil {
+		return nil, txerror.NotFound("nenhuma tarifa em vigor em %s", departure.Format(tariffDateLayout))
+	}
+
+	charge := tariff.charge(tripData, departure, arrival)
+	if charge.RiderID == "" {
+		charge.RiderID, err = tripCreditRider(ctx, tripID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := applyPass(ctx, charge, tariff, departure); err != nil {
+		return nil, err
+	}
+	charge.CalculatedAt = ctx.TxTime().Format(time.RFC3339)
+	if err := putCharge(ctx, charge); err != nil {
+		return nil, err
+	}
+
+	return charge, nil
+}
+
+// ReadCharge retorna a cobrança da viagem com o ID fornecido.
+func (tc *TripContract) ReadCharge(ctx txcontext.TransactionContextInterface, tripID string) (*Charge, error) {
+	charge, err := readCharge(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if charge == nil {
+		return nil, txerror.NotFound("a viagem %s não foi cobrada", tripID)
+	}
+
+	return charge, nil
+}
+
+// GetChargesByRider retorna as cobranças das viagens do ciclista que partiram entre from
+// e to (AAAA-MM-DD, inclusivos; vazios não limitam), em ordem de partida.
+func (tc *TripContract) GetChargesByRider(ctx txcontext.TransactionContextInterface, riderID string, from string, to string) ([]*Charge, error) {
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(tariffDateLayout, value); err != nil {
+			return nil, txerror.Validation("data inválida %q: use AAAA-MM-DD", value)
+		}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(chargeRiderObjectType, []string{riderID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as cobranças do ciclista")
+	}
+	defer resultsIterator.Close()
+
+	var charges []*Charge
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 3 {
+			return nil, txerror.Internal("chave de cobrança inválida %q", queryResponse.Key)
+		}
+		date := keyParts[1]
+		if (from != "" && date < from) || (to != "" && date > to) {
+			continue
+		}
+
+		charge, err := readCharge(ctx, keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		if charge != nil {
+			charges = append(charges, charge)
+		}
+	}
+
+	return charges, nil
+}
+
+// charge calcula a cobrança da viagem pela tarifa
+func (t *Tariff) charge(tripData *TripData, departure time.Time, arrival time.Time) *Charge {
+	minutes := int64(math.Ceil(arrival.Sub(departure).Minutes()))
+	charge := &Charge{
+		TripDataID:        tripRef(tripData),
+		RiderID:           tripData.RiderID,
+		OwnerMSP:          tripData.OwnerMSP,
+		TariffID:          t.ID,
+		DepartureDatetime: tripData.DepartureDatetime,
+		DistanceKm:        tripData.TotalDistanceKm,
+		DurationMinutes:   minutes,
+		BaseFeeCents:      t.BaseFeeCents,
+		DistanceCents:     int64(math.Round(tripData.TotalDistanceKm * float64(t.PerKmCents))),
+		TimeCents:         minutes * t.PerMinuteCents,
+		Multiplier:        t.multiplier(departure),
+	}
+
+	amount := int64(math.Round(float64(charge.BaseFeeCents+charge.DistanceCents+charge.TimeCents) * charge.Multiplier))
+	if t.MaxFareCents > 0 && amount > t.MaxFareCents {
+		amount = t.MaxFareCents
+		charge.Capped = true
+	}
+	charge.AmountCents = amount
+
+	return charge
+}
+
+// multiplier retorna o multiplicador da primeira faixa que contém o horário da partida,
+// ou 1 se nenhuma contiver
+func (t *Tariff) multiplier(departure time.Time) float64 {
+	clock := departure.Format(tariffBandLayout)
+	for _, band := range t.Bands {
+		inside := clock >= band.Start && clock < band.End
+		if band.End <= band.Start {
+			inside = clock >= band.Start || clock < band.End
+		}
+		if inside {
+			return band.Multiplier
+		}
+	}
+
+	return 1
+}
+
+// validate verifica os campos da tarifa
+func (t *Tariff) validate() error {
+	if t.ID == "" {
+		return txerror.Validation("o ID da tarifa é obrigatório")
+	}
+	if _, err := time.Parse(tariffDateLayout, t.ValidFrom); err != nil {
+		return txerror.Validation("início de vigência inválido %q: use AAAA-MM-DD", t.ValidFrom)
+	}
+	if t.BaseFeeCents < 0 || t.PerKmCents < 0 || t.PerMinuteCents < 0 || t.MaxFareCents < 0 {
+		return txerror.Validation("os valores da tarifa %s não podem ser negativos", t.ID)
+	}
+	if t.MaxFareCents > 0 && t.MaxFareCents < t.BaseFeeCents {
+		return txerror.Validation("o teto da tarifa %s é menor que a tarifa base", t.ID)
+	}
+	for _, band := range t.Bands {
+		for _, value := range []string{band.Start, band.End} {
+			if _, err := time.Parse(tariffBandLayout, value); err != nil || len(value) != len(tariffBandLayout) {
+				return txerror.Validation("horário de faixa inválido %q: use hh:mm", value)
+			}
+		}
+		if band.Start == band.End {
+			return txerror.Validation("a faixa %s-%s da tarifa %s é vazia", band.Start, band.End, t.ID)
+		}
+		if band.Multiplier <= 0 {
+			return txerror.Validation("o multiplicador da faixa %s-%s deve ser positivo", band.Start, band.End)
+		}
+	}
+	if t.Bands == nil {
+		t.Bands = []TariffBand{}
+	}
+
+	return nil
+}
+
+// tripCreditRider retorna o ciclista que recebeu os créditos da viagem, vazio se ela
+// não rendeu créditos
+func tripCreditRider(ctx txcontext.TransactionContextInterface, tripID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(tripCreditObjectType, []string{tripID})
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao criar a chave dos créditos da viagem")
+	}
+	awardJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if awardJSON == nil {
+		return "", nil
+	}
+
+	var award TripCreditAward
+	err = json.Unmarshal(awardJSON, &award)
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao fazer unmarshal dos créditos da viagem")
+	}
+
+	return award.RiderID, nil
+}
+
+// readCharge retorna a cobrança da viagem, ou nil se ela não foi cobrada
+func readCharge(ctx txcontext.TransactionContextInterface, tripID string) (*Charge, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(chargeObjectType, []string{tripID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da cobrança")
+	}
+	chargeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if chargeJSON == nil {
+		return nil, nil
+	}
+
+	var charge Charge
+	err = json.Unmarshal(chargeJSON, &charge)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da cobrança")
+	}
+
+	return &charge, nil
+}
+
+// putCharge grava a cobrança e, quando a viagem tem ciclista, o índice por ciclista e data
+func putCharge(ctx txcontext.TransactionContextInterface, charge *Charge) error {
+	key, err := ctx.GetStub().CreateCompositeKey(chargeObjectType, []string{charge.TripDataID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da cobrança")
+	}
+	chargeJSON, err := json.Marshal(charge)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter cobrança para JSON")
+	}
+	err = ctx.GetStub().PutState(key, chargeJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a cobrança no estado mundial")
+	}
+	if charge.RiderID == "" {
+		return nil
+	}
+
+	departure, err := parseTripDatetime(charge.DepartureDatetime)
+	if err != nil {
+		return err
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(chargeRiderObjectType, []string{charge.RiderID, departure.Format(tariffDateLayout), charge.TripDataID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar o índice da cobrança")
+	}
+	// O valor só marca a existência da chave; o Fabric não grava valores vazios
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o índice da cobrança no estado mundial")
+	}
+
+	return nil
+}
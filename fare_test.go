@@ -0,0 +1,74 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+const tariffJSON = `{"ID":"padrao","Name":"Padrão","ValidFrom":"2024-01-01","BaseFeeCents":200,"PerKmCents":50,"PerMinuteCents":10,"MaxFareCents":1000,"Bands":[{"Start":"22:00","End":"06:00","Multiplier":1.5}]}`
+
+func TestCalculateFare(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateTariff", tariffJSON)
+	createTrip(t, contract, "dia", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	createTrip(t, contract, "longa", "2024-03-04T10:00:00Z", "40", "2024-03-04T12:00:00Z")
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"noite","TripID":1,"Departure_Datetime":"2024-03-04 23:00:00","Arrival_Datetime":"2024-03-04 23:10:00","totalDistance_km":1,"RiderID":"rider1"}`)
+
+	tests := []struct {
+		ref    string
+		amount int64
+		capped bool
+	}{
+		{"dia", 600, false},
+		{"longa", 1000, true},
+		{"opa:noite", 525, false},
+	}
+	for _, tt := range tests {
+		var charge chaincode.Charge
+		submit(t, contract, &charge, "CalculateFare", tt.ref)
+		if charge.AmountCents != tt.amount || charge.Capped != tt.capped || charge.TripDataID != tt.ref || charge.TariffID != "padrao" {
+			t.Errorf("cobrança de %s = %+v, quer %d centavos", tt.ref, charge, tt.amount)
+		}
+	}
+
+	var charge chaincode.Charge
+	evaluate(t, contract, &charge, "ReadCharge", "opa:noite")
+	if charge.AmountCents != 525 || charge.RiderID != "rider1" {
+		t.Errorf("cobrança lida = %+v", charge)
+	}
+	var charges []*chaincode.Charge
+	evaluate(t, contract, &charges, "GetChargesByRider", "rider1", "2024-03-04", "2024-03-04")
+	if len(charges) != 1 || charges[0].TripDataID != "opa:noite" {
+		t.Errorf("cobranças de rider1 = %+v", charges)
+	}
+	var later []*chaincode.Charge
+	evaluate(t, contract, &later, "GetChargesByRider", "rider1", "2024-03-05", "")
+	if len(later) != 0 {
+		t.Errorf("cobranças de rider1 depois de 2024-03-05 = %+v", later)
+	}
+}
+
+func TestCalculateFareErrors(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "antiga", "2023-03-04T08:00:00Z", "4", "2023-03-04T08:20:00Z")
+	createTrip(t, contract, "invertida", "2024-03-04T08:20:00Z", "4", "2024-03-04T08:00:00Z")
+	createTrip(t, contract, "dia", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	submit(t, contract, nil, "CreateTariff", tariffJSON)
+	submit(t, contract, nil, "CalculateFare", "dia")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "CreateTariff", tariffJSON)
+	wantError(t, contract, txerror.CodeAlreadyExists, "CalculateFare", "dia")
+	wantError(t, contract, txerror.CodeNotFound, "CalculateFare", "antiga")
+	wantError(t, contract, txerror.CodeValidation, "CalculateFare", "invertida")
+	wantError(t, contract, txerror.CodeNotFound, "CalculateFare", "t9")
+	wantError(t, contract, txerror.CodeNotFound, "ReadCharge", "antiga")
+	wantError(t, contract, txerror.CodeNotFound, "ReadTariff", "outra")
+	wantError(t, contract, txerror.CodeValidation, "GetChargesByRider", "rider1", "04/03/2024", "")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateTariff", `{"ID":"outra","ValidFrom":"2024-01-01"}`)
+	wantError(t, contract, txerror.CodeUnauthorized, "CalculateFare", "antiga")
+}
@@ -0,0 +1,173 @@
+// Package openapi converte os metadados que o contractapi gera para o chaincode
+// num documento OpenAPI 3, usado pelos times clientes para gerar SDKs.
+//
+// Cada transação vira uma operação POST /{contrato}/{transação}, atendida pelo pacote
+// rest. Os parâmetros formam o corpo JSON da requisição e o retorno da transação é o
+// corpo da resposta 200.
+// Os esquemas dos tipos (como TripData) vão para components.schemas, que é o
+// mesmo caminho usado pelas referências ($ref) dos metadados.
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// Version é a versão da especificação OpenAPI gerada
+const Version = "3.0.3"
+
+// Document é a raiz de um documento OpenAPI 3
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info descreve a API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem reúne as operações de um caminho; as transações só usam POST
+type PathItem struct {
+	Post *Operation `json:"post,omitempty"`
+}
+
+// Operation descreve a chamada de uma transação
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// TransactionType informa se a transação deve ser submetida ou apenas avaliada
+	TransactionType string `json:"x-fabric-transaction-type,omitempty"`
+}
+
+// RequestBody descreve o corpo da requisição
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response descreve uma resposta da operação
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType associa um esquema a um tipo de conteúdo
+type MediaType struct {
+	Schema *spec.Schema `json:"schema"`
+}
+
+// Components guarda os esquemas referenciados pelas operações
+type Components struct {
+	Schemas map[string]*spec.Schema `json:"schemas"`
+}
+
+const jsonContentType = "application/json"
+
+// Generate monta o documento OpenAPI a partir dos metadados do chaincode.
+// O contrato de sistema do contractapi (org.hyperledger.fabric) é omitido.
+func Generate(chaincodeMetadata metadata.ContractChaincodeMetadata, title string, version string) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       title,
+			Description: "Gerado a partir dos metadados do contractapi. Cada transação é um POST /{contrato}/{transação}.",
+			Version:     version,
+		},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*spec.Schema{}},
+	}
+
+	contractNames := make([]string, 0, len(chaincodeMetadata.Contracts))
+	for name := range chaincodeMetadata.Contracts {
+		if name != contractapi.SystemContractName {
+			contractNames = append(contractNames, name)
+		}
+	}
+	sort.Strings(contractNames)
+
+	for _, contractName := range contractNames {
+		contract := chaincodeMetadata.Contracts[contractName]
+		for _, transaction := range contract.Transactions {
+			path := fmt.Sprintf("/%s/%s", contractName, transaction.Name)
+			if _, ok := doc.Paths[path]; ok {
+				return nil, fmt.Errorf("transação %s repetida no contrato %s", transaction.Name, contractName)
+			}
+			doc.Paths[path] = PathItem{Post: operation(contractName, transaction)}
+		}
+	}
+
+	for name, object := range chaincodeMetadata.Components.Schemas {
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"object"}
+		schema.Properties = object.Properties
+		schema.Required = object.Required
+		schema.AdditionalProperties = &spec.SchemaOrBool{Allows: object.AdditionalProperties}
+		doc.Components.Schemas[name] = schema
+	}
+
+	return doc, nil
+}
+
+func operation(contractName string, transaction metadata.TransactionMetadata) *Operation {
+	op := &Operation{
+		OperationID:     contractName + "_" + transaction.Name,
+		Summary:         transaction.Name,
+		Tags:            []string{contractName},
+		TransactionType: transactionType(transaction.Tag),
+		Responses: map[string]Response{
+			"200": {Description: "Transação executada"},
+			"500": {Description: "Erro devolvido pelo chaincode"},
+		},
+	}
+
+	if len(transaction.Parameters) > 0 {
+		body := &spec.Schema{}
+		body.Type = spec.StringOrArray{"object"}
+		body.Properties = map[string]spec.Schema{}
+		for _, parameter := range transaction.Parameters {
+			if parameter.Schema != nil {
+				body.Properties[parameter.Name] = *parameter.Schema
+			}
+			body.Required = append(body.Required, parameter.Name)
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{jsonContentType: {Schema: body}},
+		}
+	}
+
+	if transaction.Returns.Schema != nil {
+		op.Responses["200"] = Response{
+			Description: "Resultado da transação",
+			Content:     map[string]MediaType{jsonContentType: {Schema: transaction.Returns.Schema}},
+		}
+	}
+
+	return op
+}
+
+// transactionType lê das tags do contractapi se a transação é submetida ou avaliada
+func transactionType(tags []string) string {
+	for _, tag := range tags {
+		switch strings.ToLower(tag) {
+		case "submit":
+			return "submit"
+		case "evaluate":
+			return "evaluate"
+		}
+	}
+	return ""
+}
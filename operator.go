@@ -0,0 +1,536 @@
+package chaincode
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Chaincodemove/config"
+	"Chaincodemove/source"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// tripObjectType é o tipo da chave composta das viagens de cada operadora: trip/<operadora>/<ID>.
+// Com a operadora na chave, viagens de operadoras diferentes com o mesmo ID não colidem,
+// como aconteceria com os TripIDs dos bancos de cada uma em chaves simples.
+//
+// As viagens gravadas antes do suporte a várias operadoras continuam em chaves simples
+// e pertencem a config.DefaultOperatorID.
+const tripObjectType = "trip"
+
+// tripRefSeparator separa a operadora do ID na referência de uma viagem gravada em chave
+// de operadora: <operadora>:<ID>. Os IDs de operadora não têm dois-pontos, então a
+// referência é separada no primeiro deles.
+const tripRefSeparator = ":"
+
+// maxTripDataPageSize limita quantas viagens cada página de GetTripDataPage traz
+const maxTripDataPageSize = 500
+
+// OperatorSummary resume as viagens de uma operadora
+type OperatorSummary struct {
+	OperatorID      string  `json:"OperatorID"`
+	Trips           int     `json:"Trips"`
+	TotalDistanceKm float64 `json:"totalDistance_km"`
+}
+
+// CreateOperatorTripData grava dados de viagem no espaço da operadora. Como em
+// CreateTripData, a organização de quem cria é a dona e a política de endosso da chave
+// passa a exigir o seu peer. Só a operadora grava no seu espaço (veja
+// requireOperatorNamespace).
+func (tc *TripContract) CreateOperatorTripData(ctx txcontext.TransactionContextInterface, operatorID string, id string, departureDatetime string, totalDistanceKm float64, tripID int, arrivalDatetime string) error {
+	if err := requireOperatorNamespace(ctx, operatorID); err != nil {
+		return err
+	}
+	ownerMSP := ctx.MSPID()
+
+	tripData := &TripData{
+		ID:                id,
+		DepartureDatetime: departureDatetime,
+		TotalDistanceKm:   totalDistanceKm,
+		TripID:            tripID,
+		ArrivalDatetime:   arrivalDatetime,
+		SchemaVersion:     tripSchemaVersion,
+		OwnerMSP:          ownerMSP,
+		OperatorID:        operatorID,
+	}
+	created, err := putOperatorTripData(ctx, tripData)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return txerror.AlreadyExists("os dados de viagem %s da operadora %s já existem", id, operatorID)
+	}
+
+	return nil
+}
+
+// ReadOperatorTripData retorna os dados de viagem da operadora com o ID fornecido. É o
+// mesmo que ReadTripData com a referência <operadora>:<ID>.
+func (tc *TripContract) ReadOperatorTripData(ctx txcontext.TransactionContextInterface, operatorID string, id string) (*TripData, error) {
+	tripData, _, err := readTripData(ctx, operatorID+tripRefSeparator+id)
+	if err != nil {
+		return nil, err
+	}
+	if tripData == nil {
+		return nil, txerror.NotFound("os dados de viagem %s da operadora %s não existem", id, operatorID)
+	}
+
+	return tripData, nil
+}
+
+// GetTripDataByOperator retorna todos os dados de viagem da operadora. As da operadora
+// padrão são as gravadas em chaves simples, que continuam com OperatorID vazio para que
+// a referência delas seja o próprio ID.
+func (tc *TripContract) GetTripDataByOperator(ctx txcontext.TransactionContextInterface, operatorID string) ([]*TripData, error) {
+	if operatorID == config.DefaultOperatorID {
+		return tc.GetAllTripData(ctx)
+	}
+
+	var tripDataList []*TripData
+	err := forEachOperatorTrip(ctx, []string{operatorID}, func(tripData *TripData) {
+		tripDataList = append(tripDataList, tripData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tripDataList, nil
+}
+
+// GetOperatorSummaries retorna o total de viagens e de quilômetros de cada operadora,
+// ordenado pelo ID da operadora.
+func (tc *TripContract) GetOperatorSummaries(ctx txcontext.TransactionContextInterface) ([]*OperatorSummary, error) {
+	summaries := map[string]*OperatorSummary{}
+	add := func(operatorID string, tripData *TripData) {
+		summary, ok := summaries[operatorID]
+		if !ok {
+			summary = &OperatorSummary{OperatorID: operatorID}
+			summaries[operatorID] = summary
+		}
+		summary.Trips++
+		summary.TotalDistanceKm += tripData.TotalDistanceKm
+	}
+
+	legacy, err := tc.GetAllTripData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, tripData := range legacy {
+		add(config.DefaultOperatorID, tripData)
+	}
+	err = forEachOperatorTrip(ctx, []string{}, func(tripData *TripData) {
+		add(tripData.OperatorID, tripData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*OperatorSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].OperatorID < result[j].OperatorID })
+
+	return result, nil
+}
+
+// IngestOperatorTrips lê do banco da operadora as viagens da janela configurada para
+// ela e grava as que ainda não estão no livro-razão, usando o TripID do banco como ID.
+// Retorna quantas viagens foram gravadas. Só a operadora ingere as suas viagens.
+func (ic *IngestContract) IngestOperatorTrips(ctx txcontext.TransactionContextInterface, operatorID string) (int, error) {
+	if err := requireOperatorNamespace(ctx, operatorID); err != nil {
+		return 0, err
+	}
+	cfg := ctx.Config()
+	operator, err := cfg.Operator(operatorID)
+	if err != nil {
+		return 0, txerror.NotFound("%v", err)
+	}
+	ownerMSP := ctx.MSPID()
+	// A janela termina no dia da transação no fuso das datas do banco da operadora
+	now := ctx.TxTime().In(operator.Location())
+
+	db, err := sql.Open("mysql", operator.DatabaseDSN)
+	if err != nil {
+		return 0, txerror.Wrap(err, "falha ao conectar ao banco de dados da operadora %s", operatorID)
+	}
+	defer db.Close()
+
+	rows, err := source.QueryTrips(db, source.LastDaysWindow(now, operator.QueryWindowDays))
+	if err != nil {
+		return 0, txerror.Wrap(err, "falha ao consultar as viagens da operadora %s", operatorID)
+	}
+
+	ingested := 0
+	for _, row := range rows {
+		tripData := &TripData{
+			ID:                strconv.Itoa(row.TripID),
+			DepartureDatetime: row.DepartureDatetime,
+			TotalDistanceKm:   row.TotalDistanceKm,
+			TripID:            row.TripID,
+			ArrivalDatetime:   row.ArrivalDatetime,
+			SchemaVersion:     tripSchemaVersion,
+			OwnerMSP:          ownerMSP,
+			OperatorID:        operatorID,
+		}
+		created, err := putOperatorTripData(ctx, tripData)
+		if err != nil {
+			return 0, err
+		}
+		if created {
+			ingested++
+		}
+	}
+
+	return ingested, nil
+}
+
+// IngestTrip grava uma viagem enviada pela operadora em JSON, no formato de TripData.
+// Ao contrário de IngestOperatorTrips, que só lê as colunas de data e distância do banco,
+// a viagem enviada pode trazer o ciclista, a vaga de partida e o veículo, e com eles
+// cumpre a reserva ativa do veículo (veja CreateReservation). Só a operadora envia viagens.
+func (ic *IngestContract) IngestTrip(ctx txcontext.TransactionContextInterface, operatorID string, tripJSON string) error {
+	if err := requireOperatorNamespace(ctx, operatorID); err != nil {
+		return err
+	}
+
+	var tripData TripData
+	err := json.Unmarshal([]byte(tripJSON), &tripData)
+	if err != nil {
+		return txerror.Validation("falha ao fazer unmarshal dos dados de viagem: %v", err)
+	}
+	tripData.SchemaVersion = tripSchemaVersion
+	tripData.OwnerMSP = ctx.MSPID()
+	tripData.OperatorID = operatorID
+
+	created, err := putOperatorTripData(ctx, &tripData)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return txerror.AlreadyExists("os dados de viagem %s da operadora %s já existem", tripData.ID, operatorID)
+	}
+
+	return nil
+}
+
+// putOperatorTripData grava os dados de viagem na chave da operadora, se ainda não
+// existirem, com as regras de anomalia que eles violam, define a política de endosso
+// da chave e cumpre a reserva do veículo da viagem, se houver uma que combine.
+// Retorna false se já existiam.
+func putOperatorTripData(ctx txcontext.TransactionContextInterface, tripData *TripData) (bool, error) {
+	if tripData.OperatorID == "" || tripData.ID == "" {
+		return false, txerror.Validation("a operadora e o ID dos dados de viagem são obrigatórios")
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(tripObjectType, []string{tripData.OperatorID, tripData.ID})
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao criar a chave dos dados de viagem")
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if existing != nil {
+		return false, nil
+	}
+
+	if err := flagTripData(ctx, tripData); err != nil {
+		return false, err
+	}
+	tripDataJSON, err := json.Marshal(tripData)
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
+	}
+	err = ctx.GetStub().PutState(key, tripDataJSON)
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao colocar no estado mundial")
+	}
+	if err := setTripEndorsementPolicy(ctx, key, tripData.OwnerMSP); err != nil {
+		return false, err
+	}
+	if err := fulfilVehicleReservation(ctx, tripData); err != nil {
+		return false, err
+	}
+	if err := aggregateTripData(ctx, tripData); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// forEachOperatorTrip chama fn para cada viagem não excluída gravada em chave de
+// operadora que comece pelos atributos fornecidos (vazio percorre todas as operadoras).
+func forEachOperatorTrip(ctx txcontext.TransactionContextInterface, attributes []string, fn func(*TripData)) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripObjectType, attributes)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao obter dados de viagem")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return txerror.Internal("chave de dados de viagem inválida %q", queryResponse.Key)
+		}
+
+		tripData, err := unmarshalTripData(keyParts[1], queryResponse.Value)
+		if err != nil {
+			return err
+		}
+		tripData.OperatorID = keyParts[0]
+		deleted, err := tripDataDeleted(ctx, tripRef(tripData))
+		if err != nil {
+			return err
+		}
+		if deleted {
+			continue
+		}
+		fn(tripData)
+	}
+
+	return nil
+}
+
+// TripDataPage é uma página de GetTripDataPage. Bookmark é o marcador que abre a próxima
+// página; vazio na última.
+type TripDataPage struct {
+	Trips    []*TripData `json:"Trips"`
+	Bookmark string      `json:"Bookmark,omitempty" metadata:",optional"`
+}
+
+// GetTripDataPage retorna até pageSize viagens não excluídas, começando pelo marcador
+// bookmark de uma página anterior (vazio começa do início). Percorre primeiro as viagens
+// gravadas em chave simples e depois as das operadoras, na ordem das chaves, para que quem
+// exporta o livro-razão não precise carregá-lo inteiro de uma vez. Nas chaves simples, o
+// marcador é a chave da próxima viagem; nas das operadoras, é o marcador da consulta
+// paginada do Fabric depois de tripRefSeparator (veja operatorPageBookmark). As viagens
+// excluídas são puladas, então uma página pode vir com menos de pageSize viagens.
+func (tc *TripContract) GetTripDataPage(ctx txcontext.TransactionContextInterface, bookmark string, pageSize int) (*TripDataPage, error) {
+	if pageSize < 1 || pageSize > maxTripDataPageSize {
+		return nil, txerror.Validation("o tamanho da página deve estar entre 1 e %d", maxTripDataPageSize)
+	}
+	page := &TripDataPage{Trips: []*TripData{}}
+
+	if !strings.Contains(bookmark, tripRefSeparator) {
+		resultsIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+		}
+		defer resultsIterator.Close()
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+			}
+			if len(page.Trips) == pageSize {
+				page.Bookmark = queryResponse.Key
+				return page, nil
+			}
+
+			tripData, ok, err := plainTripData(queryResponse.Key, queryResponse.Value)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			deleted, err := tripDataDeleted(ctx, queryResponse.Key)
+			if err != nil {
+				return nil, err
+			}
+			if !deleted {
+				page.Trips = append(page.Trips, tripData)
+			}
+		}
+		bookmark = tripRefSeparator
+	}
+
+	fabricBookmark, err := parseOperatorPageBookmark(bookmark)
+	if err != nil {
+		return nil, err
+	}
+	// Com a página cheia, só falta saber se há viagens das operadoras para a próxima
+	remaining := pageSize - len(page.Trips)
+	if remaining == 0 {
+		remaining = 1
+	}
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(tripObjectType, []string{}, int32(remaining), fabricBookmark)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		if len(page.Trips) == pageSize {
+			page.Bookmark = operatorPageBookmark(fabricBookmark)
+			return page, nil
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return nil, txerror.Internal("chave de dados de viagem inválida %q", queryResponse.Key)
+		}
+		tripData, err := unmarshalTripData(keyParts[1], queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		tripData.OperatorID = keyParts[0]
+		deleted, err := tripDataDeleted(ctx, keyParts[0]+tripRefSeparator+keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			page.Trips = append(page.Trips, tripData)
+		}
+	}
+	// O CouchDB devolve um marcador mesmo depois da última página; só uma página completa
+	// pode ter outra depois dela
+	if metadata.Bookmark != "" && int(metadata.FetchedRecordsCount) == remaining {
+		page.Bookmark = operatorPageBookmark(metadata.Bookmark)
+	}
+
+	return page, nil
+}
+
+// operatorPageBookmark monta o marcador de GetTripDataPage para a seção das chaves das
+// operadoras: tripRefSeparator seguido do marcador da consulta paginada do Fabric em
+// base64, já que no LevelDB ele é uma chave composta, com bytes nulos. As chaves
+// simples nunca têm tripRefSeparator, então os dois tipos de marcador não se confundem.
+func operatorPageBookmark(fabricBookmark string) string {
+	return tripRefSeparator + base64.RawURLEncoding.EncodeToString([]byte(fabricBookmark))
+}
+
+// parseOperatorPageBookmark devolve o marcador do Fabric de um marcador montado por
+// operatorPageBookmark
+func parseOperatorPageBookmark(bookmark string) (string, error) {
+	if !strings.HasPrefix(bookmark, tripRefSeparator) {
+		return "", txerror.Validation("marcador de página inválido %q", bookmark)
+	}
+	fabricBookmark, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(bookmark, tripRefSeparator))
+	if err != nil {
+		return "", txerror.Validation("marcador de página inválido %q", bookmark)
+	}
+
+	return string(fabricBookmark), nil
+}
+
+// requireOperatorNamespace falha se quem chama não puder gravar viagens no espaço da
+// operadora: é preciso ter o papel de operadora, a operadora precisa estar configurada e,
+// se ela tiver um MSP configurado, a identidade precisa ser desse MSP. A operadora padrão
+// não tem espaço: as viagens dela ficam em chaves simples, e trip/moveuff/<ID> colidiria
+// com a chave simples <ID>.
+func requireOperatorNamespace(ctx txcontext.TransactionContextInterface, operatorID string) error {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return err
+	}
+	if operatorID == config.DefaultOperatorID {
+		return txerror.Validation("as viagens da operadora %s ficam em chaves simples; use CreateTripData", operatorID)
+	}
+	operator, err := ctx.Config().Operator(operatorID)
+	if err != nil {
+		return txerror.NotFound("%v", err)
+	}
+	if mspID := ctx.MSPID(); operator.MSPID != "" && mspID != operator.MSPID {
+		return txerror.Unauthorized("as viagens da operadora %s só podem ser gravadas por %s, e não por %s", operatorID, operator.MSPID, mspID)
+	}
+
+	return nil
+}
+
+// tripRef retorna a referência da viagem aceita pelas transações que recebem o ID de uma
+// viagem: o próprio ID, para as viagens em chave simples, ou <operadora>:<ID>, para as
+// gravadas em chave de operadora. Os registros ligados a uma viagem (lápides, cobranças,
+// contestações, créditos) usam a referência, para que viagens de operadoras diferentes
+// com o mesmo ID não colidam.
+func tripRef(tripData *TripData) string {
+	if tripData.OperatorID == "" {
+		return tripData.ID
+	}
+
+	return tripData.OperatorID + tripRefSeparator + tripData.ID
+}
+
+// tripKey retorna a chave no estado mundial da viagem com a referência fornecida
+func tripKey(ctx txcontext.TransactionContextInterface, ref string) (string, error) {
+	operatorID, id, ok := strings.Cut(ref, tripRefSeparator)
+	if !ok {
+		return ref, nil
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(tripObjectType, []string{operatorID, id})
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao criar a chave dos dados de viagem")
+	}
+
+	return key, nil
+}
+
+// readTripData retorna a viagem com a referência fornecida e a sua chave no estado
+// mundial. A viagem é nil se não existe ou foi excluída.
+func readTripData(ctx txcontext.TransactionContextInterface, ref string) (*TripData, string, error) {
+	tripData, key, err := readStoredTripData(ctx, ref)
+	if err != nil || tripData == nil {
+		return nil, key, err
+	}
+	deleted, err := tripDataDeleted(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	if deleted {
+		return nil, key, nil
+	}
+
+	return tripData, key, nil
+}
+
+// readStoredTripData é readTripData sem olhar a lápide: a viagem só é nil se não existe.
+func readStoredTripData(ctx txcontext.TransactionContextInterface, ref string) (*TripData, string, error) {
+	key, err := tripKey(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	tripDataJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, "", txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if tripDataJSON == nil {
+		return nil, key, nil
+	}
+
+	operatorID, id, ok := strings.Cut(ref, tripRefSeparator)
+	if !ok {
+		operatorID, id = "", ref
+	}
+	tripData, err := unmarshalTripData(id, tripDataJSON)
+	if err != nil {
+		return nil, "", err
+	}
+	tripData.OperatorID = operatorID
+
+	return tripData, key, nil
+}
+
+// forEachTrip chama fn para cada viagem não excluída, das chaves simples e das operadoras
+func forEachTrip(ctx txcontext.TransactionContextInterface, fn func(*TripData)) error {
+	legacy, err := readPlainTripData(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tripData := range legacy {
+		fn(tripData)
+	}
+
+	return forEachOperatorTrip(ctx, []string{}, fn)
+}
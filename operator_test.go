@@ -0,0 +1,116 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestOperatorTripData(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	createTrip(t, contract, "900", "2024-03-04T08:00:00Z", "2", "2024-03-04T08:20:00Z")
+	submit(t, contract, nil, "CreateOperatorTripData", "opa", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"901","TripID":901,"Departure_Datetime":"2024-03-05T08:00:00Z","Arrival_Datetime":"2024-03-05T08:20:00Z","totalDistance_km":4,"RiderID":"rider1"}`)
+
+	var tripData chaincode.TripData
+	evaluate(t, contract, &tripData, "ReadOperatorTripData", "opa", "900")
+	if tripData.OperatorID != "opa" || tripData.TotalDistanceKm != 3 || tripData.OwnerMSP != "Org1MSP" {
+		t.Errorf("viagem = %+v", tripData)
+	}
+	var ingested chaincode.TripData
+	evaluate(t, contract, &ingested, "ReadTripData", "opa:901")
+	if ingested.OperatorID != "opa" || ingested.RiderID != "rider1" {
+		t.Errorf("viagem = %+v", ingested)
+	}
+	var plain chaincode.TripData
+	evaluate(t, contract, &plain, "ReadTripData", "900")
+	if plain.OperatorID != "" || plain.TotalDistanceKm != 2 {
+		t.Errorf("viagem de chave simples = %+v", plain)
+	}
+
+	var trips []*chaincode.TripData
+	evaluate(t, contract, &trips, "GetTripDataByOperator", "opa")
+	if len(trips) != 2 {
+		t.Errorf("%d viagens da operadora opa, quer 2", len(trips))
+	}
+	// As viagens da operadora padrão são as de chave simples, e a referência delas é o ID
+	var legacy []*chaincode.TripData
+	evaluate(t, contract, &legacy, "GetTripDataByOperator", "moveuff")
+	if len(legacy) != 1 || legacy[0].ID != "900" || legacy[0].OperatorID != "" {
+		t.Errorf("viagens da operadora moveuff = %+v", legacy)
+	}
+	var summaries []*chaincode.OperatorSummary
+	evaluate(t, contract, &summaries, "GetOperatorSummaries")
+	if len(summaries) != 2 || summaries[0].OperatorID != "moveuff" || summaries[0].Trips != 1 || summaries[1].OperatorID != "opa" || summaries[1].TotalDistanceKm != 7 {
+		t.Errorf("resumos = %+v", summaries)
+	}
+}
+
+func TestOperatorTripDataErrors(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa,opb")
+	t.Setenv("MOVEUFF_OPERATOR_OPB_MSP_ID", "Org2MSP")
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateOperatorTripData", "opa", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "CreateOperatorTripData", "opa", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+	wantError(t, contract, txerror.CodeNotFound, "CreateOperatorTripData", "opz", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+	wantError(t, contract, txerror.CodeValidation, "CreateOperatorTripData", "moveuff", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+	wantError(t, contract, txerror.CodeValidation, "IngestContract:IngestTrip", "moveuff", `{"ID":"901","TripID":901}`)
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateOperatorTripData", "opb", "900", "2024-03-04T09:00:00Z", "3", "900", "2024-03-04T09:20:00Z")
+	wantError(t, contract, txerror.CodeValidation, "IngestContract:IngestTrip", "opa", "{")
+	wantError(t, contract, txerror.CodeValidation, "IngestContract:IngestTrip", "opa", `{"TripID":1}`)
+	wantError(t, contract, txerror.CodeAlreadyExists, "IngestContract:IngestTrip", "opa", `{"ID":"900","TripID":900}`)
+	wantError(t, contract, txerror.CodeNotFound, "ReadOperatorTripData", "opa", "901")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateOperatorTripData", "opa", "901", "2024-03-04T09:00:00Z", "3", "901", "2024-03-04T09:20:00Z")
+	wantError(t, contract, txerror.CodeUnauthorized, "IngestContract:IngestTrip", "opa", `{"ID":"901","TripID":901}`)
+}
+
+func TestGetTripDataPage(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	for _, id := range []string{"t1", "t2", "t3"} {
+		createTrip(t, contract, id, "2024-03-04T08:00:00Z", "2", "2024-03-04T08:20:00Z")
+	}
+	submit(t, contract, nil, "DeleteTripData", "t2", "duplicada")
+	for _, id := range []string{"900", "901", "902", "903"} {
+		submit(t, contract, nil, "CreateOperatorTripData", "opa", id, "2024-03-04T09:00:00Z", "3", "1", "2024-03-04T09:20:00Z")
+	}
+	submit(t, contract, nil, "DeleteTripData", "opa:902", "duplicada")
+
+	var refs []string
+	bookmark := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("a paginação não terminou")
+		}
+		var page chaincode.TripDataPage
+		evaluate(t, contract, &page, "GetTripDataPage", bookmark, "2")
+		if len(page.Trips) > 2 {
+			t.Fatalf("%d viagens na página, quer no máximo 2", len(page.Trips))
+		}
+		for _, tripData := range page.Trips {
+			ref := tripData.ID
+			if tripData.OperatorID != "" {
+				ref = tripData.OperatorID + ":" + tripData.ID
+			}
+			refs = append(refs, ref)
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	if got := strings.Join(refs, ","); got != "t1,t3,opa:900,opa:901,opa:903" {
+		t.Errorf("viagens = %s, quer t1,t3,opa:900,opa:901,opa:903", got)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "GetTripDataPage", "", "0")
+	wantError(t, contract, txerror.CodeValidation, "GetTripDataPage", "", "501")
+	// Nas chaves das operadoras, o marcador é o da consulta paginada, e não a referência
+	wantError(t, contract, txerror.CodeValidation, "GetTripDataPage", "opa:900", "2")
+}
@@ -0,0 +1,88 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// TransferTripOwnership passa os dados de viagem para outra organização e retorna o MSP do
+// dono anterior. A política de endosso da chave passa a exigir o peer do novo dono, então
+// só a transação endossada pela organização dona atual consegue fazer a transferência.
+// Viagens gravadas antes de terem dono só podem ser atribuídas pela operadora.
+func (tc *TripContract) TransferTripOwnership(ctx txcontext.TransactionContextInterface, id string, newOwnerMSP string) (string, error) {
+	if newOwnerMSP == "" {
+		return "", txerror.Validation("o MSP do novo dono é obrigatório")
+	}
+	tripData, key, err := readTripData(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if tripData == nil {
+		return "", txerror.NotFound("os dados de viagem %s não existem", id)
+	}
+	if tripData.OwnerMSP == "" {
+		if err := requireRole(ctx, operatorRole); err != nil {
+			return "", err
+		}
+	} else if err := requireTripOwner(ctx, tripData); err != nil {
+		return "", err
+	}
+
+	oldOwnerMSP := tripData.OwnerMSP
+	tripData.OwnerMSP = newOwnerMSP
+	tripDataJSON, err := json.Marshal(tripData)
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
+	}
+	err = ctx.GetStub().PutState(key, tripDataJSON)
+	if err != nil {
+		return "", txerror.Wrap(err, "falha ao transferir dados de viagem para o estado mundial")
+	}
+	if err := setTripEndorsementPolicy(ctx, key, newOwnerMSP); err != nil {
+		return "", err
+	}
+
+	return oldOwnerMSP, nil
+}
+
+// setTripEndorsementPolicy define a política de endosso da chave key dos dados de viagem:
+// alterações passam a exigir o endosso de um peer da organização dona.
+func setTripEndorsementPolicy(ctx txcontext.TransactionContextInterface, key string, ownerMSP string) error {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a política de endosso")
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, ownerMSP)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao adicionar %s à política de endosso", ownerMSP)
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return txerror.Wrap(err, "falha ao serializar a política de endosso")
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao definir a política de endosso dos dados de viagem %s", key)
+	}
+
+	return nil
+}
+
+// requireTripOwner falha se a identidade que assinou a transação não for da organização
+// dona dos dados de viagem. Protege as alterações da viagem já no endosso e as escritas em
+// chaves que não são a da viagem (como a lápide), que a política de endosso da chave não cobre.
+func requireTripOwner(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	if tripData.OwnerMSP == "" {
+		return nil
+	}
+	mspID := ctx.MSPID()
+	if mspID != tripData.OwnerMSP {
+		return txerror.Unauthorized("os dados de viagem %s pertencem a %s e não podem ser alterados por %s", tripData.ID, tripData.OwnerMSP, mspID)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestTransferTripOwnership(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	// Strings voltam sem JSON, então o resultado não passa por submit
+	previous, err := contract.Submit("TransferTripOwnership", "t1", "Org2MSP")
+	if err != nil {
+		t.Fatalf("TransferTripOwnership: %v", err)
+	}
+	if string(previous) != "Org1MSP" {
+		t.Errorf("dono anterior = %q, quer Org1MSP", previous)
+	}
+	var tripData chaincode.TripData
+	evaluate(t, contract, &tripData, "ReadTripData", "t1")
+	if tripData.OwnerMSP != "Org2MSP" {
+		t.Errorf("dono = %q, quer Org2MSP", tripData.OwnerMSP)
+	}
+
+	wantError(t, contract, txerror.CodeUnauthorized, "TransferTripOwnership", "t1", "Org1MSP")
+	wantError(t, contract, txerror.CodeUnauthorized, "UpdateTripData", "t1", "2024-03-04T08:00:00Z", "5", "1", "2024-03-04T08:20:00Z")
+	wantError(t, contract, txerror.CodeValidation, "TransferTripOwnership", "t1", "")
+	wantError(t, contract, txerror.CodeNotFound, "TransferTripOwnership", "t9", "Org2MSP")
+
+	setIdentity(t, contract, "Org2MSP", nil)
+	submit(t, contract, nil, "UpdateTripData", "t1", "2024-03-04T08:00:00Z", "5", "1", "2024-03-04T08:20:00Z")
+	var updated chaincode.TripData
+	evaluate(t, contract, &updated, "ReadTripData", "t1")
+	if updated.TotalDistanceKm != 5 || updated.OwnerMSP != "Org2MSP" {
+		t.Errorf("viagem alterada = %+v", updated)
+	}
+}
+
+func TestTransferTripDataRequiresOwner(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	setIdentity(t, contract, "Org2MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "TransferTripData", "t1", "7")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	var previous int
+	submit(t, contract, &previous, "TransferTripData", "t1", "7")
+	if previous != 1 {
+		t.Errorf("trip ID anterior = %d, quer 1", previous)
+	}
+	var tripData chaincode.TripData
+	evaluate(t, contract, &tripData, "ReadTripData", "t1")
+	if tripData.TripID != 7 {
+		t.Errorf("trip ID = %d, quer 7", tripData.TripID)
+	}
+}
+
+// InitLedger grava as viagens do banco com a identidade de quem chama como dona, então
+// só a operadora pode chamá-la
+func TestInitLedgerRequiresOperator(t *testing.T) {
+	contract := newContract(t)
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "InitLedger")
+}
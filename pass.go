@@ -0,0 +1,331 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipos de objeto dos passes e do índice das viagens cobertas por cada passe
+// (pass-trip/<passe>/<viagem>)
+const (
+	passObjectType     = "pass"
+	passTripObjectType = "pass-trip"
+)
+
+// Tipos de passe: o diário vale do início da data de início até o dia seguinte; o
+// mensal, até o mesmo dia do mês seguinte
+const (
+	PassDaily   = "daily"
+	PassMonthly = "monthly"
+)
+
+// Estados de um passe: active → revoked. Um passe vencido ou esgotado continua active,
+// mas não cobre mais viagens.
+const (
+	PassActive  = "active"
+	PassRevoked = "revoked"
+)
+
+// Pass é um passe de um ciclista. Enquanto estiver ativo, dentro da validade e com
+// viagens disponíveis, CalculateFare usa o passe em vez de cobrar a viagem.
+type Pass struct {
+	ID         string `json:"ID"`
+	RiderID    string `json:"RiderID"`
+	Type       string `json:"Type"`
+	ValidFrom  string `json:"ValidFrom"`
+	ValidUntil string `json:"ValidUntil"`
+	// MaxTrips é quantas viagens o passe cobre; zero não limita
+	MaxTrips int `json:"MaxTrips"`
+	// IncludedMinutes é quanto de cada viagem o passe cobre; os minutos além dele são
+	// cobrados pela tarifa. Zero cobre a viagem inteira.
+	IncludedMinutes int64  `json:"IncludedMinutes"`
+	TripsUsed       int    `json:"TripsUsed"`
+	Status          string `json:"Status"`
+	IssuedBy        string `json:"IssuedBy"`
+	IssuedAt        string `json:"IssuedAt"`
+	RevokedBy       string `json:"RevokedBy,omitempty" metadata:",optional"`
+	RevokedAt       string `json:"RevokedAt,omitempty" metadata:",optional"`
+	RevokeReason    string `json:"RevokeReason,omitempty" metadata:",optional"`
+}
+
+// IssuePass emite um passe do tipo daily ou monthly para o ciclista, válido a partir de
+// validFrom (AAAA-MM-DD, em UTC). Só a operadora emite passes.
+func (tc *TripContract) IssuePass(ctx txcontext.TransactionContextInterface, id string, riderID string, passType string, validFrom string, maxTrips int, includedMinutes int) (*Pass, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if id == "" || riderID == "" {
+		return nil, txerror.Validation("o ID do passe e o ciclista são obrigatórios")
+	}
+	start, err := time.Parse(tariffDateLayout, validFrom)
+	if err != nil {
+		return nil, txerror.Validation("início de validade inválido %q: use AAAA-MM-DD", validFrom)
+	}
+	var end time.Time
+	switch passType {
+	case PassDaily:
+		end = start.AddDate(0, 0, 1)
+	case PassMonthly:
+		end = start.AddDate(0, 1, 0)
+	default:
+		return nil, txerror.Validation("tipo de passe inválido %q: use %s ou %s", passType, PassDaily, PassMonthly)
+	}
+	if maxTrips < 0 || includedMinutes < 0 {
+		return nil, txerror.Validation("os limites de uso do passe não podem ser negativos")
+	}
+
+	existing, err := readPass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("o passe %s já existe", id)
+	}
+
+	pass := &Pass{
+		ID:              id,
+		RiderID:         riderID,
+		Type:            passType,
+		ValidFrom:       start.Format(time.RFC3339),
+		ValidUntil:      end.Format(time.RFC3339),
+		MaxTrips:        maxTrips,
+		IncludedMinutes: int64(includedMinutes),
+		Status:          PassActive,
+		IssuedBy:        ctx.CallerID(),
+		IssuedAt:        ctx.TxTime().Format(time.RFC3339),
+	}
+	if err := putPass(ctx, pass); err != nil {
+		return nil, err
+	}
+
+	return pass, nil
+}
+
+// RevokePass revoga o passe: ele deixa de cobrir viagens, mas as já cobertas continuam
+// ligadas a ele. Só a operadora revoga passes.
+func (tc *TripContract) RevokePass(ctx txcontext.TransactionContextInterface, id string, reason string) (*Pass, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, txerror.Validation("o motivo da revogação é obrigatório")
+	}
+	pass, err := tc.ReadPass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pass.Status == PassRevoked {
+		return nil, txerror.Validation("o passe %s já foi revogado", id)
+	}
+
+	pass.Status = PassRevoked
+	pass.RevokedBy = ctx.CallerID()
+	pass.RevokedAt = ctx.TxTime().Format(time.RFC3339)
+	pass.RevokeReason = reason
+	if err := putPass(ctx, pass); err != nil {
+		return nil, err
+	}
+
+	return pass, nil
+}
+
+// ReadPass retorna o passe com o ID fornecido.
+func (tc *TripContract) ReadPass(ctx txcontext.TransactionContextInterface, id string) (*Pass, error) {
+	pass, err := readPass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pass == nil {
+		return nil, txerror.NotFound("o passe %s não existe", id)
+	}
+
+	return pass, nil
+}
+
+// GetPassesByRider retorna os passes do ciclista, em ordem de início de validade.
+func (tc *TripContract) GetPassesByRider(ctx txcontext.TransactionContextInterface, riderID string) ([]*Pass, error) {
+	return readPassesByRider(ctx, riderID)
+}
+
+// readPassesByRider retorna os passes do ciclista, em ordem de início de validade
+func readPassesByRider(ctx txcontext.TransactionContextInterface, riderID string) ([]*Pass, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(passObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter passes")
+	}
+	defer resultsIterator.Close()
+
+	var passes []*Pass
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var pass Pass
+		err = json.Unmarshal(queryResponse.Value, &pass)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal do passe")
+		}
+		if pass.RiderID == riderID {
+			passes = append(passes, &pass)
+		}
+	}
+	sort.SliceStable(passes, func(i, j int) bool { return passes[i].ValidFrom < passes[j].ValidFrom })
+
+	return passes, nil
+}
+
+// GetPassCharges retorna as cobranças das viagens cobertas pelo passe.
+func (tc *TripContract) GetPassCharges(ctx txcontext.TransactionContextInterface, id string) ([]*Charge, error) {
+	if _, err := tc.ReadPass(ctx, id); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(passTripObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as viagens do passe")
+	}
+	defer resultsIterator.Close()
+
+	var charges []*Charge
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return nil, txerror.Internal("chave de viagem do passe inválida %q", queryResponse.Key)
+		}
+
+		charge, err := readCharge(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if charge != nil {
+			charges = append(charges, charge)
+		}
+	}
+
+	return charges, nil
+}
+
+// applyPass cobre a cobrança com um passe do ciclista, se houver um que valha na partida,
+// e grava o uso do passe. Entre vários passes válidos, usa o que vence primeiro.
+//
+// O passe cobre a tarifa da viagem; só os minutos além de IncludedMinutes continuam
+// cobrados, ao preço por minuto da tarifa com o multiplicador da faixa.
+func applyPass(ctx txcontext.TransactionContextInterface, charge *Charge, tariff *Tariff, departure time.Time) error {
+	if charge.RiderID == "" {
+		return nil
+	}
+	passes, err := readPassesByRider(ctx, charge.RiderID)
+	if err != nil {
+		return err
+	}
+
+	var pass *Pass
+	for _, candidate := range passes {
+		if !candidate.covers(departure) {
+			continue
+		}
+		if pass == nil || candidate.ValidUntil < pass.ValidUntil {
+			pass = candidate
+		}
+	}
+	if pass == nil {
+		return nil
+	}
+
+	amount := int64(0)
+	if pass.IncludedMinutes > 0 && charge.DurationMinutes > pass.IncludedMinutes {
+		extra := float64((charge.DurationMinutes - pass.IncludedMinutes) * tariff.PerMinuteCents)
+		amount = int64(math.Round(extra * charge.Multiplier))
+	}
+	if amount > charge.AmountCents {
+		amount = charge.AmountCents
+	}
+	charge.PassID = pass.ID
+	charge.PassCoveredCents = charge.AmountCents - amount
+	charge.AmountCents = amount
+
+	pass.TripsUsed++
+	if err := putPass(ctx, pass); err != nil {
+		return err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(passTripObjectType, []string{pass.ID, charge.TripDataID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar o índice das viagens do passe")
+	}
+	// O valor só marca a existência da chave; o Fabric não grava valores vazios
+	err = ctx.GetStub().PutState(key, []byte{0x00})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o índice das viagens do passe no estado mundial")
+	}
+
+	return nil
+}
+
+// covers informa se o passe está ativo, vale na partida e ainda tem viagens disponíveis
+func (p *Pass) covers(departure time.Time) bool {
+	if p.Status != PassActive || (p.MaxTrips > 0 && p.TripsUsed >= p.MaxTrips) {
+		return false
+	}
+	validFrom, err := time.Parse(time.RFC3339, p.ValidFrom)
+	if err != nil {
+		return false
+	}
+	validUntil, err := time.Parse(time.RFC3339, p.ValidUntil)
+	if err != nil {
+		return false
+	}
+
+	return !departure.Before(validFrom) && departure.Before(validUntil)
+}
+
+// readPass retorna o passe, ou nil se ele não existe
+func readPass(ctx txcontext.TransactionContextInterface, id string) (*Pass, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(passObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do passe")
+	}
+	passJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if passJSON == nil {
+		return nil, nil
+	}
+
+	var pass Pass
+	err = json.Unmarshal(passJSON, &pass)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal do passe")
+	}
+
+	return &pass, nil
+}
+
+// putPass grava o passe no estado mundial
+func putPass(ctx txcontext.TransactionContextInterface, pass *Pass) error {
+	key, err := ctx.GetStub().CreateCompositeKey(passObjectType, []string{pass.ID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave do passe")
+	}
+	passJSON, err := json.Marshal(pass)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter passe para JSON")
+	}
+	err = ctx.GetStub().PutState(key, passJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o passe no estado mundial")
+	}
+
+	return nil
+}
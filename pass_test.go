@@ -0,0 +1,71 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestIssuePassCoversFare(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateTariff", tariffJSON)
+	var pass chaincode.Pass
+	submit(t, contract, &pass, "IssuePass", "p1", "rider1", "daily", "2024-03-04", "1", "15")
+	if pass.Status != chaincode.PassActive || pass.ValidFrom != "2024-03-04T00:00:00Z" || pass.ValidUntil != "2024-03-05T00:00:00Z" {
+		t.Errorf("passe = %+v", pass)
+	}
+	for _, id := range []string{"a", "b"} {
+		submit(t, contract, nil, "IngestContract:IngestTrip", "opa", `{"ID":"`+id+`","TripID":1,"Departure_Datetime":"2024-03-04T08:00:00Z","Arrival_Datetime":"2024-03-04T08:20:00Z","totalDistance_km":4,"RiderID":"rider1"}`)
+	}
+
+	// O passe cobre a primeira viagem, menos os 5 minutos além dos 15 incluídos
+	var covered chaincode.Charge
+	submit(t, contract, &covered, "CalculateFare", "opa:a")
+	if covered.PassID != "p1" || covered.AmountCents != 50 || covered.PassCoveredCents != 550 {
+		t.Errorf("cobrança coberta = %+v", covered)
+	}
+	// A segunda passa do limite de uma viagem e é cobrada inteira
+	var charged chaincode.Charge
+	submit(t, contract, &charged, "CalculateFare", "opa:b")
+	if charged.PassID != "" || charged.AmountCents != 600 {
+		t.Errorf("cobrança sem passe = %+v", charged)
+	}
+
+	var charges []*chaincode.Charge
+	evaluate(t, contract, &charges, "GetPassCharges", "p1")
+	if len(charges) != 1 || charges[0].TripDataID != "opa:a" {
+		t.Errorf("cobranças do passe = %+v", charges)
+	}
+	var passes []*chaincode.Pass
+	evaluate(t, contract, &passes, "GetPassesByRider", "rider1")
+	if len(passes) != 1 || passes[0].TripsUsed != 1 {
+		t.Errorf("passes de rider1 = %+v", passes)
+	}
+
+	submit(t, contract, &pass, "RevokePass", "p1", "fraude")
+	if pass.Status != chaincode.PassRevoked || pass.RevokeReason != "fraude" {
+		t.Errorf("passe revogado = %+v", pass)
+	}
+}
+
+func TestPassErrors(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "IssuePass", "p1", "rider1", "monthly", "2024-03-04", "0", "0")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "IssuePass", "p1", "rider1", "monthly", "2024-03-04", "0", "0")
+	wantError(t, contract, txerror.CodeValidation, "IssuePass", "p2", "", "monthly", "2024-03-04", "0", "0")
+	wantError(t, contract, txerror.CodeValidation, "IssuePass", "p2", "rider1", "weekly", "2024-03-04", "0", "0")
+	wantError(t, contract, txerror.CodeValidation, "IssuePass", "p2", "rider1", "daily", "04/03/2024", "0", "0")
+	wantError(t, contract, txerror.CodeValidation, "IssuePass", "p2", "rider1", "daily", "2024-03-04", "-1", "0")
+	wantError(t, contract, txerror.CodeValidation, "RevokePass", "p1", "")
+	wantError(t, contract, txerror.CodeNotFound, "RevokePass", "p9", "fraude")
+	wantError(t, contract, txerror.CodeNotFound, "GetPassCharges", "p9")
+	submit(t, contract, nil, "RevokePass", "p1", "fraude")
+	wantError(t, contract, txerror.CodeValidation, "RevokePass", "p1", "fraude")
+
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	wantError(t, contract, txerror.CodeUnauthorized, "IssuePass", "p2", "rider1", "daily", "2024-03-04", "0", "0")
+	wantError(t, contract, txerror.CodeUnauthorized, "RevokePass", "p1", "fraude")
+}
@@ -0,0 +1,175 @@
+// Package reconcile compara as viagens do banco moveuff com as viagens gravadas no livro-razão.
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"Chaincodemove/source"
+)
+
+// distanceTolerance é a diferença máxima de distância (km) tratada como igual
+const distanceTolerance = 1e-6
+
+// Difference descreve um campo que difere entre o banco e o livro-razão
+type Difference struct {
+	Field  string `json:"Field"`
+	Source string `json:"Source"`
+	Ledger string `json:"Ledger"`
+}
+
+// Mismatch reúne as diferenças encontradas numa viagem presente nos dois lados
+type Mismatch struct {
+	TripID      int          `json:"TripID"`
+	Differences []Difference `json:"Differences"`
+}
+
+// Report é o resultado da reconciliação
+type Report struct {
+	Window     source.Window    `json:"Window"`
+	SourceRows int              `json:"SourceRows"`
+	LedgerRows int              `json:"LedgerRows"`
+	Missing    []source.TripRow `json:"Missing"`
+	Extra      []source.TripRow `json:"Extra"`
+	Mismatched []Mismatch       `json:"Mismatched"`
+}
+
+// HasDiscrepancies informa se o relatório encontrou alguma divergência
+func (r *Report) HasDiscrepancies() bool {
+	return len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Mismatched) > 0
+}
+
+// Reconcile lê as viagens da janela na origem e compara com as viagens do livro-razão.
+// Viagens do livro-razão que partiram ou chegaram fora da janela são ignoradas, como
+// as da origem (veja source.Window.ContainsTrip).
+func Reconcile(tripSource source.TripSource, ledgerTrips []source.TripRow, window source.Window) (*Report, error) {
+	sourceTrips, err := tripSource.Trips(window)
+	if err != nil {
+		return nil, err
+	}
+
+	var inWindow []source.TripRow
+	for _, trip := range ledgerTrips {
+		if window.ContainsTrip(trip) {
+			inWindow = append(inWindow, trip)
+		}
+	}
+
+	report := Compare(sourceTrips, inWindow)
+	report.Window = window
+
+	return report, nil
+}
+
+// Compare casa as viagens dos dois lados pelo TripID.
+// Viagens repetidas no livro-razão são relatadas como extras.
+func Compare(sourceTrips []source.TripRow, ledgerTrips []source.TripRow) *Report {
+	report := &Report{
+		SourceRows: len(sourceTrips),
+		LedgerRows: len(ledgerTrips),
+		Missing:    []source.TripRow{},
+		Extra:      []source.TripRow{},
+		Mismatched: []Mismatch{},
+	}
+
+	ledgerByID := map[int]source.TripRow{}
+	for _, trip := range ledgerTrips {
+		if _, ok := ledgerByID[trip.TripID]; ok {
+			report.Extra = append(report.Extra, trip)
+			continue
+		}
+		ledgerByID[trip.TripID] = trip
+	}
+
+	for _, sourceTrip := range sourceTrips {
+		ledgerTrip, ok := ledgerByID[sourceTrip.TripID]
+		if !ok {
+			report.Missing = append(report.Missing, sourceTrip)
+			continue
+		}
+		delete(ledgerByID, sourceTrip.TripID)
+
+		if differences := diff(sourceTrip, ledgerTrip); len(differences) > 0 {
+			report.Mismatched = append(report.Mismatched, Mismatch{TripID: sourceTrip.TripID, Differences: differences})
+		}
+	}
+	for _, trip := range ledgerByID {
+		report.Extra = append(report.Extra, trip)
+	}
+
+	sort.Slice(report.Missing, func(i, j int) bool { return report.Missing[i].TripID < report.Missing[j].TripID })
+	sort.Slice(report.Extra, func(i, j int) bool { return report.Extra[i].TripID < report.Extra[j].TripID })
+	sort.Slice(report.Mismatched, func(i, j int) bool { return report.Mismatched[i].TripID < report.Mismatched[j].TripID })
+
+	return report
+}
+
+func diff(sourceTrip source.TripRow, ledgerTrip source.TripRow) []Difference {
+	var differences []Difference
+	if math.Abs(sourceTrip.TotalDistanceKm-ledgerTrip.TotalDistanceKm) > distanceTolerance {
+		differences = append(differences, Difference{
+			Field:  "totalDistance_km",
+			Source: formatDistance(sourceTrip.TotalDistanceKm),
+			Ledger: formatDistance(ledgerTrip.TotalDistanceKm),
+		})
+	}
+	if sourceTrip.DepartureDatetime != ledgerTrip.DepartureDatetime {
+		differences = append(differences, Difference{
+			Field:  "Departure_Datetime",
+			Source: sourceTrip.DepartureDatetime,
+			Ledger: ledgerTrip.DepartureDatetime,
+		})
+	}
+	if sourceTrip.ArrivalDatetime != ledgerTrip.ArrivalDatetime {
+		differences = append(differences, Difference{
+			Field:  "Arrival_Datetime",
+			Source: sourceTrip.ArrivalDatetime,
+			Ledger: ledgerTrip.ArrivalDatetime,
+		})
+	}
+
+	return differences
+}
+
+func formatDistance(km float64) string {
+	return strconv.FormatFloat(km, 'f', -1, 64)
+}
+
+// WriteJSON escreve o relatório como JSON indentado
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("falha ao serializar o relatório para JSON: %v", err)
+	}
+
+	return nil
+}
+
+// WriteCSV escreve uma linha por divergência: status, TripID, campo, valor no banco e valor no livro-razão
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{{"status", "TripID", "field", "source", "ledger"}}
+	for _, trip := range r.Missing {
+		records = append(records, []string{"missing", strconv.Itoa(trip.TripID), "", "", ""})
+	}
+	for _, trip := range r.Extra {
+		records = append(records, []string{"extra", strconv.Itoa(trip.TripID), "", "", ""})
+	}
+	for _, mismatch := range r.Mismatched {
+		for _, difference := range mismatch.Differences {
+			records = append(records, []string{"mismatch", strconv.Itoa(mismatch.TripID), difference.Field, difference.Source, difference.Ledger})
+		}
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("falha ao escrever o relatório em CSV: %v", err)
+	}
+
+	return nil
+}
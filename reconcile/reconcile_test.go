@@ -0,0 +1,205 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"Chaincodemove/reconcile"
+	"Chaincodemove/source"
+)
+
+// trip monta uma viagem de 2024-03-04 com o TripID e a distância fornecidos
+func trip(tripID int, distanceKm float64) source.TripRow {
+	return source.TripRow{
+		DepartureDatetime: "2024-03-04 08:00:00",
+		TotalDistanceKm:   distanceKm,
+		TripID:            tripID,
+		ArrivalDatetime:   "2024-03-04 08:20:00",
+	}
+}
+
+// tripIDs retorna os TripIDs das viagens, na ordem
+func tripIDs(trips []source.TripRow) []int {
+	ids := []int{}
+	for _, trip := range trips {
+		ids = append(ids, trip.TripID)
+	}
+	return ids
+}
+
+func equalIDs(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompare(t *testing.T) {
+	moved := trip(3, 2)
+	moved.DepartureDatetime = "2024-03-04 09:00:00"
+
+	tests := []struct {
+		name       string
+		source     []source.TripRow
+		ledger     []source.TripRow
+		missing    []int
+		extra      []int
+		mismatched []int
+	}{
+		{
+			name:   "iguais",
+			source: []source.TripRow{trip(1, 2), trip(2, 3)},
+			ledger: []source.TripRow{trip(2, 3), trip(1, 2)},
+		},
+		{
+			name:    "faltando no livro-razão",
+			source:  []source.TripRow{trip(3, 2), trip(1, 2), trip(2, 3)},
+			ledger:  []source.TripRow{trip(2, 3)},
+			missing: []int{1, 3},
+		},
+		{
+			name:   "extra no livro-razão",
+			source: []source.TripRow{trip(1, 2)},
+			ledger: []source.TripRow{trip(5, 2), trip(1, 2), trip(4, 1)},
+			extra:  []int{4, 5},
+		},
+		{
+			name:   "repetida no livro-razão",
+			source: []source.TripRow{trip(1, 2)},
+			ledger: []source.TripRow{trip(1, 2), trip(1, 2)},
+			extra:  []int{1},
+		},
+		{
+			name:   "distância dentro da tolerância",
+			source: []source.TripRow{trip(1, 2)},
+			ledger: []source.TripRow{trip(1, 2+1e-9)},
+		},
+		{
+			name:       "distância fora da tolerância",
+			source:     []source.TripRow{trip(1, 2)},
+			ledger:     []source.TripRow{trip(1, 2.001)},
+			mismatched: []int{1},
+		},
+		{
+			name:       "partida diferente",
+			source:     []source.TripRow{trip(3, 2)},
+			ledger:     []source.TripRow{moved},
+			mismatched: []int{3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := reconcile.Compare(test.source, test.ledger)
+			if report.SourceRows != len(test.source) || report.LedgerRows != len(test.ledger) {
+				t.Errorf("linhas = %d/%d, quer %d/%d", report.SourceRows, report.LedgerRows, len(test.source), len(test.ledger))
+			}
+			if got := tripIDs(report.Missing); !equalIDs(got, test.missing) {
+				t.Errorf("faltando = %v, quer %v", got, test.missing)
+			}
+			if got := tripIDs(report.Extra); !equalIDs(got, test.extra) {
+				t.Errorf("extras = %v, quer %v", got, test.extra)
+			}
+			mismatched := []int{}
+			for _, mismatch := range report.Mismatched {
+				mismatched = append(mismatched, mismatch.TripID)
+			}
+			if !equalIDs(mismatched, test.mismatched) {
+				t.Errorf("divergentes = %v, quer %v", mismatched, test.mismatched)
+			}
+			discrepancies := len(test.missing)+len(test.extra)+len(test.mismatched) > 0
+			if report.HasDiscrepancies() != discrepancies {
+				t.Errorf("HasDiscrepancies = %v, quer %v", report.HasDiscrepancies(), discrepancies)
+			}
+		})
+	}
+}
+
+func TestCompareDifferences(t *testing.T) {
+	ledger := trip(1, 2.5)
+	ledger.ArrivalDatetime = "2024-03-04 08:30:00"
+
+	report := reconcile.Compare([]source.TripRow{trip(1, 2)}, []source.TripRow{ledger})
+	if len(report.Mismatched) != 1 {
+		t.Fatalf("divergentes = %+v", report.Mismatched)
+	}
+	want := []reconcile.Difference{
+		{Field: "totalDistance_km", Source: "2", Ledger: "2.5"},
+		{Field: "Arrival_Datetime", Source: "2024-03-04 08:20:00", Ledger: "2024-03-04 08:30:00"},
+	}
+	differences := report.Mismatched[0].Differences
+	if len(differences) != len(want) {
+		t.Fatalf("diferenças = %+v, quer %+v", differences, want)
+	}
+	for i := range want {
+		if differences[i] != want[i] {
+			t.Errorf("diferença %d = %+v, quer %+v", i, differences[i], want[i])
+		}
+	}
+}
+
+// tripSource devolve sempre as mesmas viagens e guarda a janela pedida
+type tripSource struct {
+	trips  []source.TripRow
+	err    error
+	window source.Window
+}
+
+func (s *tripSource) Trips(w source.Window) ([]source.TripRow, error) {
+	s.window = w
+	return s.trips, s.err
+}
+
+func TestReconcileIgnoresLedgerTripsOutsideWindow(t *testing.T) {
+	window := source.Window{From: "2024-03-04", To: "2024-03-04"}
+	late := trip(2, 3)
+	late.ArrivalDatetime = "2024-03-05 00:10:00"
+	tripSource := &tripSource{trips: []source.TripRow{trip(1, 2)}}
+
+	report, err := reconcile.Reconcile(tripSource, []source.TripRow{trip(1, 2), late}, window)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if tripSource.window != window || report.Window != window {
+		t.Errorf("janelas = %+v e %+v, quer %+v", tripSource.window, report.Window, window)
+	}
+	if report.LedgerRows != 1 || report.HasDiscrepancies() {
+		t.Errorf("relatório = %+v", report)
+	}
+
+	tripSource.err = errors.New("banco fora do ar")
+	if _, err := reconcile.Reconcile(tripSource, nil, window); err == nil {
+		t.Error("Reconcile não devolveu o erro da origem")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	ledger := trip(3, 2.5)
+	ledger.DepartureDatetime = "2024-03-04 07:00:00"
+	report := reconcile.Compare(
+		[]source.TripRow{trip(1, 2), trip(3, 2)},
+		[]source.TripRow{trip(2, 1), ledger},
+	)
+
+	var buffer bytes.Buffer
+	if err := report.WriteCSV(&buffer); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := strings.Join([]string{
+		"status,TripID,field,source,ledger",
+		"missing,1,,,",
+		"extra,2,,,",
+		"mismatch,3,totalDistance_km,2,2.5",
+		"mismatch,3,Departure_Datetime,2024-03-04 08:00:00,2024-03-04 07:00:00",
+		"",
+	}, "\n")
+	if buffer.String() != want {
+		t.Errorf("CSV =\n%s\nquer\n%s", buffer.String(), want)
+	}
+}
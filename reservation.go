@@ -0,0 +1,380 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipos de objeto das reservas e da trava de cada veículo (reservation-vehicle/<veículo>),
+// que guarda o ID da reserva ativa do veículo
+const (
+	reservationObjectType        = "reservation"
+	reservationVehicleObjectType = "reservation-vehicle"
+)
+
+// maxReservationMinutes limita a janela de uma reserva
+const maxReservationMinutes = 30
+
+// Estados de uma reserva: active → cancelled, expired ou fulfilled
+const (
+	ReservationActive    = "active"
+	ReservationCancelled = "cancelled"
+	ReservationExpired   = "expired"
+	ReservationFulfilled = "fulfilled"
+)
+
+// Reservation é a reserva de um veículo numa vaga de partida, de CreatedAt até ExpiresAt.
+// Uma reserva ativa trava o veículo: ninguém mais pode reservá-lo até ela terminar.
+type Reservation struct {
+	ID        string `json:"ID"`
+	RiderID   string `json:"RiderID"`
+	VehicleID string `json:"VehicleID"`
+	SlotID    string `json:"SlotID"`
+	Status    string `json:"Status"`
+	CreatedBy string `json:"CreatedBy"`
+	CreatedAt string `json:"CreatedAt"`
+	ExpiresAt string `json:"ExpiresAt"`
+	ClosedAt  string `json:"ClosedAt,omitempty" metadata:",optional"`
+	// TripDataID e OperatorID identificam a viagem que cumpriu a reserva
+	TripDataID string `json:"TripDataID,omitempty" metadata:",optional"`
+	OperatorID string `json:"OperatorID,omitempty" metadata:",optional"`
+}
+
+// CreateReservation reserva o veículo na vaga para o ciclista pelos próximos minutes
+// minutos, contados do carimbo de data/hora da transação. Falha se o veículo já tiver
+// uma reserva ativa; uma reserva vencida que ainda não foi expirada é expirada aqui.
+// Só o próprio ciclista (identidade com o atributo riderID) ou a operadora reservam.
+func (tc *TripContract) CreateReservation(ctx txcontext.TransactionContextInterface, id string, riderID string, vehicleID string, slotID string, minutes int) (*Reservation, error) {
+	if id == "" || riderID == "" || vehicleID == "" || slotID == "" {
+		return nil, txerror.Validation("o ID da reserva, o ciclista, o veículo e a vaga são obrigatórios")
+	}
+	if err := requireRiderOrOperator(ctx, riderID); err != nil {
+		return nil, err
+	}
+	if minutes < 1 || minutes > maxReservationMinutes {
+		return nil, txerror.Validation("a reserva deve durar entre 1 e %d minutos", maxReservationMinutes)
+	}
+	existing, err := readReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("a reserva %s já existe", id)
+	}
+
+	holder, err := vehicleReservation(ctx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	now := ctx.TxTime()
+	if holder != nil {
+		if !holder.expired(now) {
+			return nil, txerror.AlreadyExists("o veículo %s já está reservado (reserva %s, até %s)", vehicleID, holder.ID, holder.ExpiresAt)
+		}
+		if err := closeReservation(ctx, holder, ReservationExpired); err != nil {
+			return nil, err
+		}
+	}
+
+	reservation := &Reservation{
+		ID:        id,
+		RiderID:   riderID,
+		VehicleID: vehicleID,
+		SlotID:    slotID,
+		Status:    ReservationActive,
+		CreatedBy: ctx.CallerID(),
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339),
+	}
+	if err := putReservation(ctx, reservation); err != nil {
+		return nil, err
+	}
+	lockKey, err := ctx.GetStub().CreateCompositeKey(reservationVehicleObjectType, []string{vehicleID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a trava do veículo")
+	}
+	err = ctx.GetStub().PutState(lockKey, []byte(id))
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar a trava do veículo no estado mundial")
+	}
+
+	return reservation, nil
+}
+
+// ReadReservation retorna a reserva com o ID fornecido.
+func (tc *TripContract) ReadReservation(ctx txcontext.TransactionContextInterface, id string) (*Reservation, error) {
+	reservation, err := readReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, txerror.NotFound("a reserva %s não existe", id)
+	}
+
+	return reservation, nil
+}
+
+// GetReservationsByRider retorna as reservas do ciclista.
+func (tc *TripContract) GetReservationsByRider(ctx txcontext.TransactionContextInterface, riderID string) ([]*Reservation, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(reservationObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter reservas")
+	}
+	defer resultsIterator.Close()
+
+	var reservations []*Reservation
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var reservation Reservation
+		err = json.Unmarshal(queryResponse.Value, &reservation)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da reserva")
+		}
+		if reservation.RiderID == riderID {
+			reservations = append(reservations, &reservation)
+		}
+	}
+
+	return reservations, nil
+}
+
+// CancelReservation cancela a reserva ativa. Só quem a criou ou a operadora pode cancelá-la.
+func (tc *TripContract) CancelReservation(ctx txcontext.TransactionContextInterface, id string) (*Reservation, error) {
+	reservation, err := activeReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.CreatedBy != ctx.CallerID() {
+		if err := requireRole(ctx, operatorRole); err != nil {
+			return nil, err
+		}
+	}
+	if reservation.expired(ctx.TxTime()) {
+		return nil, txerror.Validation("a reserva %s venceu em %s e não pode mais ser cancelada", id, reservation.ExpiresAt)
+	}
+
+	if err := closeReservation(ctx, reservation, ReservationCancelled); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// ExpireReservation expira a reserva ativa cuja janela já terminou, liberando o veículo.
+// Qualquer um pode chamá-la; o vencimento é decidido pelo carimbo de data/hora da
+// transação, o mesmo em todos os peers.
+func (tc *TripContract) ExpireReservation(ctx txcontext.TransactionContextInterface, id string) (*Reservation, error) {
+	reservation, err := activeReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reservation.expired(ctx.TxTime()) {
+		return nil, txerror.Validation("a reserva %s só vence em %s", id, reservation.ExpiresAt)
+	}
+
+	if err := closeReservation(ctx, reservation, ReservationExpired); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// FulfilReservation marca a reserva como cumprida pela viagem da operadora com o ID
+// fornecido (com a operadora vazia, pela viagem gravada em chave simples). A viagem
+// precisa ter partido da vaga reservada dentro da janela da reserva. Só a operadora
+// cumpre reservas à mão; as viagens recebidas por IngestContract cumprem a reserva do
+// seu veículo automaticamente.
+func (tc *TripContract) FulfilReservation(ctx txcontext.TransactionContextInterface, id string, operatorID string, tripDataID string) (*Reservation, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	reservation, err := activeReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ref := tripDataID
+	if operatorID != "" {
+		ref = operatorID + tripRefSeparator + tripDataID
+	}
+	tripData, _, err := readTripData(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if tripData == nil {
+		return nil, txerror.NotFound("os dados de viagem %s não existem", ref)
+	}
+	if err := reservation.match(tripData, ctx.Config().Location()); err != nil {
+		return nil, err
+	}
+
+	reservation.TripDataID = tripData.ID
+	reservation.OperatorID = tripData.OperatorID
+	if err := closeReservation(ctx, reservation, ReservationFulfilled); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// fulfilVehicleReservation cumpre a reserva ativa do veículo da viagem, se a viagem
+// combinar com ela. Viagens sem veículo ou vaga de partida não cumprem reservas, e uma
+// viagem que não combina não é recusada: a reserva só continua ativa.
+func fulfilVehicleReservation(ctx txcontext.TransactionContextInterface, tripData *TripData) error {
+	if tripData.VehicleID == "" || tripData.DepartureSlot == "" {
+		return nil
+	}
+	reservation, err := vehicleReservation(ctx, tripData.VehicleID)
+	if err != nil || reservation == nil {
+		return err
+	}
+	if reservation.match(tripData, ctx.Config().Location()) != nil {
+		return nil
+	}
+
+	reservation.TripDataID = tripData.ID
+	reservation.OperatorID = tripData.OperatorID
+
+	return closeReservation(ctx, reservation, ReservationFulfilled)
+}
+
+// match falha se a viagem não partiu da vaga da reserva dentro da janela dela, ou se
+// o veículo ou o ciclista da viagem, quando informados, forem outros. A partida sem fuso
+// é lida no fuso fornecido, para ser comparada com a janela da reserva.
+func (r *Reservation) match(tripData *TripData, location *time.Location) error {
+	if tripData.DepartureSlot != r.SlotID {
+		return txerror.Validation("a viagem %s partiu da vaga %q, e a reserva %s é da vaga %s", tripData.ID, tripData.DepartureSlot, r.ID, r.SlotID)
+	}
+	if tripData.VehicleID != "" && tripData.VehicleID != r.VehicleID {
+		return txerror.Validation("a viagem %s usou o veículo %s, e a reserva %s é do veículo %s", tripData.ID, tripData.VehicleID, r.ID, r.VehicleID)
+	}
+	if tripData.RiderID != "" && tripData.RiderID != r.RiderID {
+		return txerror.Validation("a viagem %s é do ciclista %s, e a reserva %s é de %s", tripData.ID, tripData.RiderID, r.ID, r.RiderID)
+	}
+
+	departure, err := parseTripDatetimeIn(tripData.DepartureDatetime, location)
+	if err != nil {
+		return err
+	}
+	createdAt, err := time.Parse(time.RFC3339, r.CreatedAt)
+	if err != nil {
+		return txerror.Wrap(err, "data de criação inválida na reserva %s", r.ID)
+	}
+	if departure.Before(createdAt) || r.expired(departure) {
+		return txerror.Validation("a viagem %s partiu em %s, fora da janela da reserva %s (%s a %s)", tripData.ID, tripData.DepartureDatetime, r.ID, r.CreatedAt, r.ExpiresAt)
+	}
+
+	return nil
+}
+
+// expired informa se a janela da reserva já tinha terminado no instante fornecido
+func (r *Reservation) expired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, r.ExpiresAt)
+
+	return err != nil || !now.Before(expiresAt)
+}
+
+// activeReservation retorna a reserva, que precisa existir e estar ativa
+func activeReservation(ctx txcontext.TransactionContextInterface, id string) (*Reservation, error) {
+	reservation, err := readReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, txerror.NotFound("a reserva %s não existe", id)
+	}
+	if reservation.Status != ReservationActive {
+		return nil, txerror.Validation("a reserva %s está %s, não %s", id, reservation.Status, ReservationActive)
+	}
+
+	return reservation, nil
+}
+
+// vehicleReservation retorna a reserva ativa que trava o veículo, ou nil se ele está livre
+func vehicleReservation(ctx txcontext.TransactionContextInterface, vehicleID string) (*Reservation, error) {
+	lockKey, err := ctx.GetStub().CreateCompositeKey(reservationVehicleObjectType, []string{vehicleID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a trava do veículo")
+	}
+	reservationID, err := ctx.GetStub().GetState(lockKey)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if reservationID == nil {
+		return nil, nil
+	}
+
+	reservation, err := readReservation(ctx, string(reservationID))
+	if err != nil || reservation == nil || reservation.Status != ReservationActive {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// closeReservation encerra a reserva com o estado fornecido e solta a trava do veículo
+func closeReservation(ctx txcontext.TransactionContextInterface, reservation *Reservation, status string) error {
+	reservation.Status = status
+	reservation.ClosedAt = ctx.TxTime().Format(time.RFC3339)
+	if err := putReservation(ctx, reservation); err != nil {
+		return err
+	}
+
+	lockKey, err := ctx.GetStub().CreateCompositeKey(reservationVehicleObjectType, []string{reservation.VehicleID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a trava do veículo")
+	}
+	err = ctx.GetStub().DelState(lockKey)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao soltar a trava do veículo %s", reservation.VehicleID)
+	}
+
+	return nil
+}
+
+// readReservation retorna a reserva, ou nil se ela não existe
+func readReservation(ctx txcontext.TransactionContextInterface, id string) (*Reservation, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(reservationObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da reserva")
+	}
+	reservationJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if reservationJSON == nil {
+		return nil, nil
+	}
+
+	var reservation Reservation
+	err = json.Unmarshal(reservationJSON, &reservation)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da reserva")
+	}
+
+	return &reservation, nil
+}
+
+// putReservation grava a reserva no estado mundial
+func putReservation(ctx txcontext.TransactionContextInterface, reservation *Reservation) error {
+	key, err := ctx.GetStub().CreateCompositeKey(reservationObjectType, []string{reservation.ID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da reserva")
+	}
+	reservationJSON, err := json.Marshal(reservation)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter reserva para JSON")
+	}
+	err = ctx.GetStub().PutState(key, reservationJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a reserva no estado mundial")
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/txerror"
+)
+
+// ingestTrip envia pela operadora opa uma viagem que parte agora, dentro da janela das
+// reservas recém-criadas
+func ingestTrip(t *testing.T, contract *mock.Backend, trip chaincode.TripData) {
+	t.Helper()
+	now := time.Now().UTC()
+	trip.TripID = 1
+	trip.DepartureDatetime = now.Add(time.Minute).Format(time.RFC3339)
+	trip.ArrivalDatetime = now.Add(10 * time.Minute).Format(time.RFC3339)
+	trip.TotalDistanceKm = 2
+	submit(t, contract, nil, "IngestContract:IngestTrip", "opa", toJSON(t, trip))
+}
+
+func TestReservationFulfilledByTrip(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	var reservation chaincode.Reservation
+	submit(t, contract, &reservation, "CreateReservation", "r1", "rider1", "bike1", "s1", "15")
+	if reservation.Status != chaincode.ReservationActive {
+		t.Fatalf("reserva = %+v", reservation)
+	}
+	wantError(t, contract, txerror.CodeAlreadyExists, "CreateReservation", "r2", "rider2", "bike1", "s1", "15")
+
+	// A viagem do veículo reservado, partindo da vaga, cumpre a reserva e solta o veículo
+	ingestTrip(t, contract, chaincode.TripData{ID: "v1", RiderID: "rider1", VehicleID: "bike1", DepartureSlot: "s1"})
+	evaluate(t, contract, &reservation, "ReadReservation", "r1")
+	if reservation.Status != chaincode.ReservationFulfilled || reservation.TripDataID != "v1" || reservation.OperatorID != "opa" {
+		t.Errorf("reserva cumprida = %+v", reservation)
+	}
+	submit(t, contract, nil, "CreateReservation", "r2", "rider2", "bike1", "s1", "15")
+
+	// Uma viagem sem veículo só cumpre a reserva à mão, e precisa partir da vaga reservada
+	ingestTrip(t, contract, chaincode.TripData{ID: "v2", RiderID: "rider2", DepartureSlot: "s2"})
+	ingestTrip(t, contract, chaincode.TripData{ID: "v3", RiderID: "rider2", DepartureSlot: "s1"})
+	wantError(t, contract, txerror.CodeValidation, "FulfilReservation", "r2", "opa", "v2")
+	wantError(t, contract, txerror.CodeNotFound, "FulfilReservation", "r2", "opa", "v9")
+	submit(t, contract, &reservation, "FulfilReservation", "r2", "opa", "v3")
+	if reservation.Status != chaincode.ReservationFulfilled || reservation.TripDataID != "v3" {
+		t.Errorf("reserva cumprida à mão = %+v", reservation)
+	}
+	wantError(t, contract, txerror.CodeValidation, "FulfilReservation", "r2", "opa", "v3")
+
+	var reservations []*chaincode.Reservation
+	evaluate(t, contract, &reservations, "GetReservationsByRider", "rider2")
+	if len(reservations) != 1 || reservations[0].ID != "r2" {
+		t.Errorf("reservas de rider2 = %+v", reservations)
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	contract := newContract(t)
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	submit(t, contract, nil, "CreateReservation", "r1", "rider1", "bike1", "s1", "15")
+	// Um ciclista só reserva para si mesmo
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateReservation", "r2", "rider2", "bike2", "s1", "15")
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider2"})
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateReservation", "r2", "rider1", "bike2", "s1", "15")
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+
+	wantError(t, contract, txerror.CodeValidation, "ExpireReservation", "r1")
+	wantError(t, contract, txerror.CodeUnauthorized, "FulfilReservation", "r1", "", "t1")
+	setIdentity(t, contract, "Org2MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "CancelReservation", "r1")
+
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	var reservation chaincode.Reservation
+	submit(t, contract, &reservation, "CancelReservation", "r1")
+	if reservation.Status != chaincode.ReservationCancelled || reservation.ClosedAt == "" {
+		t.Errorf("reserva cancelada = %+v", reservation)
+	}
+	wantError(t, contract, txerror.CodeValidation, "CancelReservation", "r1")
+	submit(t, contract, nil, "CreateReservation", "r2", "rider1", "bike1", "s1", "15")
+}
+
+func TestCreateReservationErrors(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateReservation", "r1", "rider1", "bike1", "s1", "15")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "CreateReservation", "r1", "rider1", "bike2", "s1", "15")
+	wantError(t, contract, txerror.CodeValidation, "CreateReservation", "r2", "", "bike2", "s1", "15")
+	wantError(t, contract, txerror.CodeValidation, "CreateReservation", "r2", "rider1", "bike2", "s1", "0")
+	wantError(t, contract, txerror.CodeValidation, "CreateReservation", "r2", "rider1", "bike2", "s1", "31")
+	wantError(t, contract, txerror.CodeNotFound, "ReadReservation", "r9")
+	wantError(t, contract, txerror.CodeNotFound, "CancelReservation", "r9")
+}
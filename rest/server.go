@@ -0,0 +1,260 @@
+// Package rest expõe as transações do contrato de viagens como uma API HTTP/JSON,
+// para clientes que não falam gRPC com o Fabric (como o painel web).
+//
+//	GET  /trips                 lista as viagens (paginada com limit e bookmark)
+//	GET  /trips/{id}            lê uma viagem
+//	POST /trips                 cria uma viagem
+//	GET  /trips/{id}/history    histórico de versões de uma viagem
+//	GET  /blocks                blocos da aplicação
+//	POST /{contract}/{name}     qualquer transação, como descrita em api/openapi.json
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Chaincodemove/backend"
+	"Chaincodemove/txerror"
+)
+
+// Limites da paginação de GET /trips
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Server atende a API REST sobre um backend.Backend qualquer: o Fabric Gateway em
+// produção ou a rede simulada nos testes locais.
+type Server struct {
+	contract     backend.Backend
+	mux          *http.ServeMux
+	transactions transactions
+}
+
+// TripRequest é o corpo aceito por POST /trips, com os mesmos campos de TripData
+type TripRequest struct {
+	ID                string  `json:"ID"`
+	DepartureDatetime string  `json:"Departure_Datetime"`
+	TotalDistanceKm   float64 `json:"totalDistance_km"`
+	TripID            int     `json:"TripID"`
+	ArrivalDatetime   string  `json:"Arrival_Datetime"`
+}
+
+// Page é a resposta paginada de GET /trips, com as viagens das chaves simples e depois as
+// das operadoras. Bookmark é o valor do parâmetro bookmark que traz a próxima página;
+// fica vazio na última.
+type Page struct {
+	Items    []json.RawMessage `json:"items"`
+	Limit    int               `json:"limit"`
+	Bookmark string            `json:"bookmark,omitempty"`
+}
+
+// errorResponse é o corpo de todas as respostas de erro
+type errorResponse struct {
+	Error string `json:"error"`
+	// Code é o código de txerror do erro da transação, quando houver
+	Code string `json:"code,omitempty"`
+}
+
+// errBadRequest marca erros de validação da própria requisição
+var errBadRequest = errors.New("requisição inválida")
+
+// NewServer cria o servidor sobre o backend fornecido
+func NewServer(contract backend.Backend) *Server {
+	s := &Server{contract: contract, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/trips", s.handleTrips)
+	s.mux.HandleFunc("/trips/", s.handleTrip)
+	s.mux.HandleFunc("/blocks", s.handleBlocks)
+	s.mux.HandleFunc("/", s.handleTransaction)
+	return s
+}
+
+// ServeHTTP implementa http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleTrips atende GET /trips e POST /trips
+func (s *Server) handleTrips(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTrips(w, r)
+	case http.MethodPost:
+		s.createTrip(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleTrip atende GET /trips/{id} e GET /trips/{id}/history
+func (s *Server) handleTrip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/trips/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		s.evaluate(w, http.StatusOK, "ReadTripData", parts[0])
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "history":
+		s.evaluate(w, http.StatusOK, "GetTripDataHistory", parts[0])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("recurso %s não existe", r.URL.Path))
+	}
+}
+
+// handleBlocks atende GET /blocks
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	result, err := s.contract.Evaluate("BatchContract:GetBlocks")
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	// GetBlocks devolve o JSON dos blocos dentro de uma string
+	var blockchainJSON string
+	if err := json.Unmarshal(result, &blockchainJSON); err == nil {
+		result = []byte(blockchainJSON)
+	}
+	writeRaw(w, http.StatusOK, result)
+}
+
+func (s *Server) listTrips(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err == nil && (limit < 1 || limit > maxPageSize) {
+		err = fmt.Errorf("%w: limit deve estar entre 1 e %d", errBadRequest, maxPageSize)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// A página vem pronta do contrato, que lê só as viagens dela
+	result, err := s.contract.Evaluate("GetTripDataPage", r.URL.Query().Get("bookmark"), strconv.Itoa(limit))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	var tripDataPage struct {
+		Trips    []json.RawMessage `json:"Trips"`
+		Bookmark string            `json:"Bookmark"`
+	}
+	if err := json.Unmarshal(result, &tripDataPage); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("resposta inválida do contrato: %v", err))
+		return
+	}
+
+	page := Page{Items: tripDataPage.Trips, Limit: limit, Bookmark: tripDataPage.Bookmark}
+	if page.Items == nil {
+		page.Items = []json.RawMessage{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) createTrip(w http.ResponseWriter, r *http.Request) {
+	var trip TripRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&trip); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	if trip.ID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: o campo ID é obrigatório", errBadRequest))
+		return
+	}
+
+	_, err := s.contract.Submit("CreateTripData",
+		trip.ID,
+		trip.DepartureDatetime,
+		strconv.FormatFloat(trip.TotalDistanceKm, 'f', -1, 64),
+		strconv.Itoa(trip.TripID),
+		trip.ArrivalDatetime,
+	)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+
+	w.Header().Set("Location", "/trips/"+trip.ID)
+	s.evaluate(w, http.StatusCreated, "ReadTripData", trip.ID)
+}
+
+// evaluate executa a consulta e escreve o resultado, ou o erro mapeado para o status HTTP
+func (s *Server) evaluate(w http.ResponseWriter, status int, name string, args ...string) {
+	result, err := s.contract.Evaluate(name, args...)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeRaw(w, status, result)
+}
+
+// statusFor converte um erro do contrato em status HTTP pelo código de txerror que vem
+// na mensagem da transação. Erros sem código são falhas do gateway ou do peer.
+func statusFor(err error) int {
+	switch txerror.CodeOf(err) {
+	case txerror.CodeNotFound:
+		return http.StatusNotFound
+	case txerror.CodeAlreadyExists:
+		return http.StatusConflict
+	case txerror.CodeValidation:
+		return http.StatusBadRequest
+	case txerror.CodeUnauthorized:
+		return http.StatusForbidden
+	case txerror.CodeInternal:
+		return http.StatusInternalServerError
+	case txerror.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s deve ser um número inteiro", errBadRequest, name)
+	}
+	return n, nil
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("método não permitido"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error(), Code: string(txerror.CodeOf(err))})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body = []byte(`{"error":"falha ao serializar a resposta"}`)
+	}
+	writeRaw(w, status, body)
+}
+
+func writeRaw(w http.ResponseWriter, status int, body []byte) {
+	if len(body) == 0 {
+		body = []byte("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
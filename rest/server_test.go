@@ -0,0 +1,272 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/rest"
+	"Chaincodemove/txerror"
+)
+
+// newServer cria o servidor sobre o contrato em memória, com uma identidade de operadora
+func newServer(t *testing.T) *rest.Server {
+	t.Helper()
+	return rest.NewServer(newContract(t))
+}
+
+// newContract cria o contrato em memória com uma identidade de operadora
+func newContract(t *testing.T) *mock.Backend {
+	t.Helper()
+	contract, err := mock.New("Org1MSP")
+	if err != nil {
+		t.Fatalf("mock.New: %v", err)
+	}
+	if err := contract.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if err := contract.SetIdentity("Org1MSP", mock.OperatorAttributes); err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+	return contract
+}
+
+func do(t *testing.T, server http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+func tripBody(id string) string {
+	return `{"ID":"` + id + `","Departure_Datetime":"2024-03-04T08:00:00","totalDistance_km":2.5,"TripID":1,"Arrival_Datetime":"2024-03-04T08:20:00"}`
+}
+
+func TestCreateAndReadTrip(t *testing.T) {
+	server := newServer(t)
+
+	created := do(t, server, http.MethodPost, "/trips", tripBody("t1"))
+	if created.Code != http.StatusCreated {
+		t.Fatalf("POST /trips = %d %s", created.Code, created.Body)
+	}
+	if location := created.Header().Get("Location"); location != "/trips/t1" {
+		t.Errorf("Location = %q", location)
+	}
+
+	read := do(t, server, http.MethodGet, "/trips/t1", "")
+	if read.Code != http.StatusOK {
+		t.Fatalf("GET /trips/t1 = %d %s", read.Code, read.Body)
+	}
+	var trip rest.TripRequest
+	if err := json.Unmarshal(read.Body.Bytes(), &trip); err != nil {
+		t.Fatal(err)
+	}
+	if trip.ID != "t1" || trip.TotalDistanceKm != 2.5 {
+		t.Errorf("viagem = %+v", trip)
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	server := newServer(t)
+	if created := do(t, server, http.MethodPost, "/trips", tripBody("t1")); created.Code != http.StatusCreated {
+		t.Fatalf("POST /trips = %d %s", created.Code, created.Body)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   txerror.Code
+	}{
+		{"viagem inexistente", http.MethodGet, "/trips/t9", "", http.StatusNotFound, txerror.CodeNotFound},
+		{"recurso inexistente", http.MethodGet, "/trips/t1/outro", "", http.StatusNotFound, ""},
+		{"viagem repetida", http.MethodPost, "/trips", tripBody("t1"), http.StatusConflict, txerror.CodeAlreadyExists},
+		{"JSON inválido", http.MethodPost, "/trips", "{", http.StatusBadRequest, ""},
+		{"campo desconhecido", http.MethodPost, "/trips", `{"ID":"t2","Cor":"azul"}`, http.StatusBadRequest, ""},
+		{"sem ID", http.MethodPost, "/trips", `{"TripID":2}`, http.StatusBadRequest, ""},
+		{"limit zero", http.MethodGet, "/trips?limit=0", "", http.StatusBadRequest, ""},
+		{"limit acima do máximo", http.MethodGet, "/trips?limit=501", "", http.StatusBadRequest, ""},
+		{"limit não numérico", http.MethodGet, "/trips?limit=dez", "", http.StatusBadRequest, ""},
+		{"método não permitido", http.MethodDelete, "/trips/t1", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := do(t, server, tt.method, tt.target, tt.body)
+			if response.Code != tt.status {
+				t.Fatalf("%s %s = %d %s, quer %d", tt.method, tt.target, response.Code, response.Body, tt.status)
+			}
+			var body struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error == "" || body.Code != string(tt.code) {
+				t.Errorf("corpo = %+v, quer código %q", body, tt.code)
+			}
+		})
+	}
+}
+
+func TestListTripsPagination(t *testing.T) {
+	t.Setenv("MOVEUFF_OPERATORS", "moveuff,opa")
+	contract := newContract(t)
+	server := rest.NewServer(contract)
+	for _, id := range []string{"t1", "t2", "t3"} {
+		if created := do(t, server, http.MethodPost, "/trips", tripBody(id)); created.Code != http.StatusCreated {
+			t.Fatalf("POST /trips = %d %s", created.Code, created.Body)
+		}
+	}
+	// As viagens das operadoras também são listadas, depois das de chave simples
+	if _, err := contract.Submit("CreateOperatorTripData", "opa", "900", "2024-03-05 08:00:00", "5", "900", "2024-03-05 08:20:00"); err != nil {
+		t.Fatalf("CreateOperatorTripData: %v", err)
+	}
+
+	tests := []struct {
+		target   string
+		ids      []string
+		limit    int
+		bookmark string
+	}{
+		{"/trips", []string{"t1", "t2", "t3", "900"}, 50, ""},
+		{"/trips?limit=2", []string{"t1", "t2"}, 2, "t3"},
+		{"/trips?limit=2&bookmark=t3", []string{"t3", "900"}, 2, ""},
+		{"/trips?limit=3", []string{"t1", "t2", "t3"}, 3, ":"},
+		{"/trips?limit=3&bookmark=%3A", []string{"900"}, 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			response := do(t, server, http.MethodGet, tt.target, "")
+			if response.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d %s", tt.target, response.Code, response.Body)
+			}
+			var page struct {
+				Items    []rest.TripRequest `json:"items"`
+				Limit    int                `json:"limit"`
+				Bookmark string             `json:"bookmark"`
+			}
+			if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Limit != tt.limit || page.Bookmark != tt.bookmark {
+				t.Errorf("página = limit %d, bookmark %q; quer %d, %q", page.Limit, page.Bookmark, tt.limit, tt.bookmark)
+			}
+			ids := []string{}
+			for _, item := range page.Items {
+				ids = append(ids, item.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("itens = %v, quer %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	response := do(t, newServer(t), http.MethodGet, "/blocks", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /blocks = %d %s", response.Code, response.Body)
+	}
+	if strings.TrimSpace(response.Body.String()) != `{"blocks":[]}` {
+		t.Errorf("GET /blocks = %s", response.Body)
+	}
+}
+
+// Cada transação de api/openapi.json é um POST /{contrato}/{transação}, submetido ou só
+// avaliado conforme x-fabric-transaction-type
+func TestTransactions(t *testing.T) {
+	server := newServer(t)
+
+	created := do(t, server, http.MethodPost, "/TripContract/CreateTripData", `{"param0":"t1","param1":"2024-03-04T08:00:00","param2":2.5,"param3":1,"param4":"2024-03-04T08:20:00"}`)
+	if created.Code != http.StatusOK {
+		t.Fatalf("POST /TripContract/CreateTripData = %d %s", created.Code, created.Body)
+	}
+	read := do(t, server, http.MethodPost, "/TripContract/ReadTripData", `{"param0":"t1"}`)
+	if read.Code != http.StatusOK {
+		t.Fatalf("POST /TripContract/ReadTripData = %d %s", read.Code, read.Body)
+	}
+	var trip rest.TripRequest
+	if err := json.Unmarshal(read.Body.Bytes(), &trip); err != nil {
+		t.Fatal(err)
+	}
+	if trip.ID != "t1" || trip.TotalDistanceKm != 2.5 {
+		t.Errorf("viagem = %+v", trip)
+	}
+
+	// As strings devolvidas pelo contrato vão como strings JSON, como no documento
+	blocks := do(t, server, http.MethodPost, "/BatchContract/GetBlocks", "")
+	var blockchainJSON string
+	if err := json.Unmarshal(blocks.Body.Bytes(), &blockchainJSON); err != nil || blockchainJSON != `{"blocks":[]}` {
+		t.Errorf("POST /BatchContract/GetBlocks = %d %s", blocks.Code, blocks.Body)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/TripContract/ReadTripData", `{"param0":"t9"}`, http.StatusNotFound},
+		{http.MethodPost, "/TripContract/ReadTripData", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/TripContract/ReadTripData", `{"param0":"t1","extra":1}`, http.StatusBadRequest},
+		{http.MethodPost, "/TripContract/ReadTripData", `[`, http.StatusBadRequest},
+		{http.MethodPost, "/TripContract/CreateTripData", `{"param0":"t1","param1":"2024-03-04T08:00:00","param2":2.5,"param3":1,"param4":"2024-03-04T08:20:00"}`, http.StatusConflict},
+		{http.MethodGet, "/TripContract/ReadTripData", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/TripContract/Inexistente", "", http.StatusNotFound},
+		{http.MethodPost, "/org.hyperledger.fabric/GetMetadata", "", http.StatusNotFound},
+		{http.MethodPost, "/TripContract", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		response := do(t, server, tt.method, tt.target, tt.body)
+		if response.Code != tt.status {
+			t.Errorf("%s %s = %d %s, quer %d", tt.method, tt.target, response.Code, response.Body, tt.status)
+		}
+	}
+}
+
+// failingBackend falha todas as transações com o erro fornecido
+type failingBackend struct {
+	err error
+}
+
+func (b failingBackend) Submit(name string, args ...string) ([]byte, error) {
+	return nil, b.err
+}
+
+func (b failingBackend) Evaluate(name string, args ...string) ([]byte, error) {
+	return nil, b.err
+}
+
+func (b failingBackend) Close() error {
+	return nil
+}
+
+func TestContractErrorStatuses(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{txerror.NotFound("não existe"), http.StatusNotFound},
+		{txerror.AlreadyExists("já existe"), http.StatusConflict},
+		{txerror.Validation("inválido"), http.StatusBadRequest},
+		{txerror.Unauthorized("sem papel"), http.StatusForbidden},
+		{txerror.Internal("falha"), http.StatusInternalServerError},
+		{txerror.Unavailable("pausado"), http.StatusServiceUnavailable},
+		// O gateway devolve a mensagem da transação sem o tipo txerror.Error
+		{errors.New("[NOT_FOUND] não existe"), http.StatusNotFound},
+		{errors.New("falha de conexão com o peer"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		response := do(t, rest.NewServer(failingBackend{err: tt.err}), http.MethodGet, "/trips/t1", "")
+		if response.Code != tt.status {
+			t.Errorf("erro %q = %d, quer %d", tt.err, response.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// transactions guarda os metadados do chaincode, lidos na primeira chamada de
+// POST /{contrato}/{transação}. Uma falha na leitura não fica guardada: a próxima
+// chamada tenta de novo.
+type transactions struct {
+	mu        sync.Mutex
+	contracts map[string]metadata.ContractMetadata
+}
+
+// handleTransaction atende POST /{contrato}/{transação}, o caminho documentado em
+// api/openapi.json. O corpo é um objeto JSON com os parâmetros da transação pelo nome;
+// a transação é submetida ou só avaliada conforme a tag dela nos metadados, a mesma
+// que vai em x-fabric-transaction-type.
+func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || parts[0] == contractapi.SystemContractName {
+		writeError(w, http.StatusNotFound, fmt.Errorf("recurso %s não existe", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	transaction, ok, err := s.transaction(parts[0], parts[1])
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("a transação %s:%s não existe", parts[0], parts[1]))
+		return
+	}
+
+	args, err := transactionArgs(r, transaction.Parameters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	name := parts[0] + ":" + parts[1]
+	var result []byte
+	if isEvaluate(transaction.Tag) {
+		result, err = s.contract.Evaluate(name, args...)
+	} else {
+		result, err = s.contract.Submit(name, args...)
+	}
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+
+	// O contractapi devolve as strings sem aspas; o documento as descreve como JSON
+	if schema := transaction.Returns.Schema; schema != nil && schema.Type.Contains("string") {
+		writeJSON(w, http.StatusOK, string(result))
+		return
+	}
+	writeRaw(w, http.StatusOK, result)
+}
+
+// transaction retorna os metadados da transação do contrato, lendo os metadados do
+// chaincode se ainda não foram lidos
+func (s *Server) transaction(contractName string, name string) (metadata.TransactionMetadata, bool, error) {
+	s.transactions.mu.Lock()
+	defer s.transactions.mu.Unlock()
+
+	if s.transactions.contracts == nil {
+		metadataJSON, err := s.contract.Evaluate(contractapi.SystemContractName + ":GetMetadata")
+		if err != nil {
+			return metadata.TransactionMetadata{}, false, err
+		}
+		var chaincodeMetadata metadata.ContractChaincodeMetadata
+		if err := json.Unmarshal(metadataJSON, &chaincodeMetadata); err != nil {
+			return metadata.TransactionMetadata{}, false, fmt.Errorf("metadados inválidos do contrato: %v", err)
+		}
+		s.transactions.contracts = chaincodeMetadata.Contracts
+	}
+
+	for _, transaction := range s.transactions.contracts[contractName].Transactions {
+		if transaction.Name == name {
+			return transaction, true, nil
+		}
+	}
+	return metadata.TransactionMetadata{}, false, nil
+}
+
+// transactionArgs lê do corpo os argumentos da transação, na ordem dos parâmetros.
+// Strings vão como estão e os demais valores como o próprio JSON, que é como o
+// contractapi os converte.
+func transactionArgs(r *http.Request, parameters []metadata.ParameterMetadata) ([]string, error) {
+	body := map[string]json.RawMessage{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil && !(errors.Is(err, io.EOF) && len(parameters) == 0) {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	args := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		value, ok := body[parameter.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: o campo %s é obrigatório", errBadRequest, parameter.Name)
+		}
+		delete(body, parameter.Name)
+
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			args = append(args, text)
+		} else {
+			args = append(args, string(value))
+		}
+	}
+	if len(body) > 0 {
+		unknown := make([]string, 0, len(body))
+		for name := range body {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: %s não são parâmetros da transação", errBadRequest, strings.Join(unknown, ", "))
+	}
+
+	return args, nil
+}
+
+// isEvaluate informa se as tags do contractapi marcam a transação como consulta
+func isEvaluate(tags []string) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(tag, "evaluate") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Tipos de objeto usados nas chaves compostas do catálogo de recompensas.
+// Chaves compostas não aparecem em GetStateByRange("", ""), então
+// recompensas, saldos e recibos não se misturam com os dados de viagem.
+const (
+	rewardObjectType     = "reward"
+	creditObjectType     = "credit"
+	redemptionObjectType = "redemption"
+	tripCreditObjectType = "trip-credit"
+)
+
+// Reward representa um item do catálogo de recompensas que pode ser resgatado com créditos
+type Reward struct {
+	ID         string `json:"ID"`
+	Name       string `json:"Name"`
+	Cost       int    `json:"Cost"`
+	Stock      int    `json:"Stock"`
+	ValidFrom  string `json:"ValidFrom,omitempty" metadata:",optional"`
+	ValidUntil string `json:"ValidUntil,omitempty" metadata:",optional"`
+}
+
+// CreditBalance representa o saldo de créditos de um ciclista
+type CreditBalance struct {
+	RiderID string `json:"RiderID"`
+	Credits int    `json:"Credits"`
+}
+
+// Redemption é o recibo gravado a cada resgate de recompensa
+type Redemption struct {
+	ID         string `json:"ID"`
+	RiderID    string `json:"RiderID"`
+	RewardID   string `json:"RewardID"`
+	Cost       int    `json:"Cost"`
+	RedeemedAt string `json:"RedeemedAt"`
+}
+
+// TripCreditAward registra os créditos que uma viagem rendeu ao ciclista
+type TripCreditAward struct {
+	TripDataID string `json:"TripDataID"`
+	RiderID    string `json:"RiderID"`
+	Credits    int    `json:"Credits"`
+	AwardedAt  string `json:"AwardedAt"`
+}
+
+// CreateReward adiciona uma nova recompensa ao catálogo. Só a operadora mantém o catálogo.
+// ValidFrom e ValidUntil estão no formato RFC3339 e podem ficar vazios para não limitar a validade.
+func (tc *TripContract) CreateReward(ctx txcontext.TransactionContextInterface, id string, name string, cost int, stock int, validFrom string, validUntil string) error {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return err
+	}
+	if cost <= 0 {
+		return txerror.Validation("o custo da recompensa %s deve ser positivo", id)
+	}
+	if stock < 0 {
+		return txerror.Validation("o estoque da recompensa %s não pode ser negativo", id)
+	}
+	for _, value := range []string{validFrom, validUntil} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return txerror.Validation("data de validade inválida %q: %v", value, err)
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(rewardObjectType, []string{id})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da recompensa")
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if existing != nil {
+		return txerror.AlreadyExists("a recompensa %s já existe", id)
+	}
+
+	reward := Reward{
+		ID:         id,
+		Name:       name,
+		Cost:       cost,
+		Stock:      stock,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+	}
+	rewardJSON, err := json.Marshal(reward)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter recompensa para JSON")
+	}
+	err = ctx.GetStub().PutState(key, rewardJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a recompensa no estado mundial")
+	}
+
+	return nil
+}
+
+// ReadReward retorna a recompensa do catálogo com o ID fornecido.
+func (tc *TripContract) ReadReward(ctx txcontext.TransactionContextInterface, id string) (*Reward, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(rewardObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da recompensa")
+	}
+	rewardJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if rewardJSON == nil {
+		return nil, txerror.NotFound("a recompensa %s não existe", id)
+	}
+
+	var reward Reward
+	err = json.Unmarshal(rewardJSON, &reward)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal da recompensa")
+	}
+
+	return &reward, nil
+}
+
+// GetAllRewards retorna todas as recompensas do catálogo.
+func (tc *TripContract) GetAllRewards(ctx txcontext.TransactionContextInterface) ([]*Reward, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(rewardObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter recompensas")
+	}
+	defer resultsIterator.Close()
+
+	var rewards []*Reward
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var reward Reward
+		err = json.Unmarshal(queryResponse.Value, &reward)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da recompensa")
+		}
+		rewards = append(rewards, &reward)
+	}
+
+	return rewards, nil
+}
+
+// GetCreditBalance retorna o saldo de créditos do ciclista. Ciclistas sem saldo gravado têm zero créditos.
+func (tc *TripContract) GetCreditBalance(ctx txcontext.TransactionContextInterface, riderID string) (*CreditBalance, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(creditObjectType, []string{riderID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do saldo")
+	}
+	balanceJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if balanceJSON == nil {
+		return &CreditBalance{RiderID: riderID}, nil
+	}
+
+	var balance CreditBalance
+	err = json.Unmarshal(balanceJSON, &balance)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal do saldo")
+	}
+
+	return &balance, nil
+}
+
+// AddCredits credita créditos ao saldo do ciclista e retorna o novo saldo. Só a operadora
+// emite créditos avulsos.
+func (tc *TripContract) AddCredits(ctx txcontext.TransactionContextInterface, riderID string, amount int) (int, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return 0, err
+	}
+	if amount <= 0 {
+		return 0, txerror.Validation("a quantidade de créditos deve ser positiva")
+	}
+
+	balance, err := tc.GetCreditBalance(ctx, riderID)
+	if err != nil {
+		return 0, err
+	}
+	balance.Credits += amount
+
+	if err := putCreditBalance(ctx, balance); err != nil {
+		return 0, err
+	}
+
+	return balance.Credits, nil
+}
+
+// AwardTripCredits credita ao ciclista os créditos da viagem com o ID fornecido: a
+// distância vezes a taxa CreditsPerKm da configuração (veja AdminContract.SetConfig),
+// arredondada para baixo. Como em CalculateFare, a distância é a da versão mais recente
+// da viagem, com as correções das contestações aceitas. Cada viagem rende créditos uma
+// vez só. Se a viagem tiver RiderID, ele precisa ser o ciclista informado. Como
+// AddCredits, só a operadora credita.
+func (tc *TripContract) AwardTripCredits(ctx txcontext.TransactionContextInterface, id string, riderID string) (*TripCreditAward, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if riderID == "" {
+		return nil, txerror.Validation("o ciclista é obrigatório")
+	}
+	tripData, err := tc.ReadTripData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tripData, err = latestTripVersion(ctx, tripData)
+	if err != nil {
+		return nil, err
+	}
+	if tripData.RiderID != "" && tripData.RiderID != riderID {
+		return nil, txerror.Validation("os dados de viagem %s são do ciclista %s, não de %s", id, tripData.RiderID, riderID)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(tripCreditObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave dos créditos da viagem")
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if existing != nil {
+		return nil, txerror.AlreadyExists("os créditos da viagem %s já foram creditados", id)
+	}
+
+	credits := int(math.Floor(tripData.TotalDistanceKm * ctx.Config().CreditsPerKm))
+	if credits <= 0 {
+		return nil, txerror.Validation("a viagem %s não rende créditos com a taxa atual de %.2f por km", id, ctx.Config().CreditsPerKm)
+	}
+	balance, err := tc.GetCreditBalance(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+	balance.Credits += credits
+	if err := putCreditBalance(ctx, balance); err != nil {
+		return nil, err
+	}
+
+	award := TripCreditAward{
+		TripDataID: id,
+		RiderID:    riderID,
+		Credits:    credits,
+		AwardedAt:  ctx.TxTime().Format(time.RFC3339),
+	}
+	awardJSON, err := json.Marshal(award)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter os créditos da viagem para JSON")
+	}
+	err = ctx.GetStub().PutState(key, awardJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar os créditos da viagem no estado mundial")
+	}
+
+	return &award, nil
+}
+
+// RedeemReward consome os créditos do ciclista, baixa o estoque da recompensa e grava um recibo do resgate.
+// Só o próprio ciclista (identidade com o atributo riderID) ou a operadora resgatam.
+func (tc *TripContract) RedeemReward(ctx txcontext.TransactionContextInterface, rewardID string, riderID string) (*Redemption, error) {
+	if err := requireRiderOrOperator(ctx, riderID); err != nil {
+		return nil, err
+	}
+	reward, err := tc.ReadReward(ctx, rewardID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := ctx.TxTime()
+	if reward.ValidFrom != "" {
+		validFrom, err := time.Parse(time.RFC3339, reward.ValidFrom)
+		if err != nil {
+			return nil, txerror.Wrap(err, "data de validade inválida na recompensa %s", rewardID)
+		}
+		if now.Before(validFrom) {
+			return nil, txerror.Validation("a recompensa %s ainda não está disponível", rewardID)
+		}
+	}
+	if reward.ValidUntil != "" {
+		validUntil, err := time.Parse(time.RFC3339, reward.ValidUntil)
+		if err != nil {
+			return nil, txerror.Wrap(err, "data de validade inválida na recompensa %s", rewardID)
+		}
+		if now.After(validUntil) {
+			return nil, txerror.Validation("a recompensa %s expirou", rewardID)
+		}
+	}
+	if reward.Stock <= 0 {
+		return nil, txerror.Validation("a recompensa %s está sem estoque", rewardID)
+	}
+
+	balance, err := tc.GetCreditBalance(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Credits < reward.Cost {
+		return nil, txerror.Validation("créditos insuficientes: o ciclista %s tem %d e a recompensa %s custa %d", riderID, balance.Credits, rewardID, reward.Cost)
+	}
+
+	balance.Credits -= reward.Cost
+	if err := putCreditBalance(ctx, balance); err != nil {
+		return nil, err
+	}
+
+	reward.Stock--
+	rewardKey, err := ctx.GetStub().CreateCompositeKey(rewardObjectType, []string{rewardID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da recompensa")
+	}
+	rewardJSON, err := json.Marshal(reward)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter recompensa para JSON")
+	}
+	err = ctx.GetStub().PutState(rewardKey, rewardJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao atualizar a recompensa no estado mundial")
+	}
+
+	// O ID da transação identifica o recibo de forma única
+	redemption := Redemption{
+		ID:         ctx.GetStub().GetTxID(),
+		RiderID:    riderID,
+		RewardID:   rewardID,
+		Cost:       reward.Cost,
+		RedeemedAt: now.Format(time.RFC3339),
+	}
+	redemptionKey, err := ctx.GetStub().CreateCompositeKey(redemptionObjectType, []string{riderID, redemption.ID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do recibo")
+	}
+	redemptionJSON, err := json.Marshal(redemption)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao converter recibo para JSON")
+	}
+	err = ctx.GetStub().PutState(redemptionKey, redemptionJSON)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao gravar o recibo no estado mundial")
+	}
+
+	return &redemption, nil
+}
+
+// GetRedemptionsByRider retorna todos os recibos de resgate do ciclista.
+func (tc *TripContract) GetRedemptionsByRider(ctx txcontext.TransactionContextInterface, riderID string) ([]*Redemption, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(redemptionObjectType, []string{riderID})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter recibos")
+	}
+	defer resultsIterator.Close()
+
+	var redemptions []*Redemption
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var redemption Redemption
+		err = json.Unmarshal(queryResponse.Value, &redemption)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal do recibo")
+		}
+		redemptions = append(redemptions, &redemption)
+	}
+
+	return redemptions, nil
+}
+
+// putCreditBalance grava o saldo de créditos do ciclista no estado mundial
+func putCreditBalance(ctx txcontext.TransactionContextInterface, balance *CreditBalance) error {
+	key, err := ctx.GetStub().CreateCompositeKey(creditObjectType, []string{balance.RiderID})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave do saldo")
+	}
+	balanceJSON, err := json.Marshal(balance)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter saldo para JSON")
+	}
+	err = ctx.GetStub().PutState(key, balanceJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o saldo no estado mundial")
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestRedeemReward(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateReward", "r1", "Café", "30", "1", "", "")
+	submit(t, contract, nil, "AddCredits", "rider1", "50")
+
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	var redemption chaincode.Redemption
+	submit(t, contract, &redemption, "RedeemReward", "r1", "rider1")
+	if redemption.RiderID != "rider1" || redemption.RewardID != "r1" || redemption.Cost != 30 || redemption.ID == "" {
+		t.Errorf("recibo = %+v", redemption)
+	}
+
+	var balance chaincode.CreditBalance
+	evaluate(t, contract, &balance, "GetCreditBalance", "rider1")
+	if balance.Credits != 20 {
+		t.Errorf("saldo = %d, quer 20", balance.Credits)
+	}
+	var reward chaincode.Reward
+	evaluate(t, contract, &reward, "ReadReward", "r1")
+	if reward.Stock != 0 {
+		t.Errorf("estoque = %d, quer 0", reward.Stock)
+	}
+	var redemptions []*chaincode.Redemption
+	evaluate(t, contract, &redemptions, "GetRedemptionsByRider", "rider1")
+	if len(redemptions) != 1 || redemptions[0].ID != redemption.ID {
+		t.Errorf("recibos = %+v", redemptions)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "RedeemReward", "r1", "rider1")
+}
+
+func TestRedeemRewardErrors(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateReward", "r1", "Café", "30", "5", "", "")
+	submit(t, contract, nil, "CreateReward", "expirada", "Chá", "1", "5", "", "2020-01-01T00:00:00Z")
+	submit(t, contract, nil, "AddCredits", "rider1", "10")
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		args       []string
+		code       txerror.Code
+	}{
+		{"outro ciclista", map[string]string{"riderID": "rider2"}, []string{"r1", "rider1"}, txerror.CodeUnauthorized},
+		{"sem atributo", nil, []string{"r1", "rider1"}, txerror.CodeUnauthorized},
+		{"recompensa inexistente", operator, []string{"r9", "rider1"}, txerror.CodeNotFound},
+		{"recompensa expirada", operator, []string{"expirada", "rider1"}, txerror.CodeValidation},
+		{"créditos insuficientes", operator, []string{"r1", "rider1"}, txerror.CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setIdentity(t, contract, "Org1MSP", tt.attributes)
+			wantError(t, contract, tt.code, "RedeemReward", tt.args...)
+		})
+	}
+}
+
+func TestRewardCatalogueErrors(t *testing.T) {
+	contract := newContract(t)
+	submit(t, contract, nil, "CreateReward", "r1", "Café", "30", "1", "", "")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "CreateReward", "r1", "Café", "30", "1", "", "")
+	wantError(t, contract, txerror.CodeValidation, "CreateReward", "r2", "Café", "0", "1", "", "")
+	wantError(t, contract, txerror.CodeValidation, "CreateReward", "r2", "Café", "30", "-1", "", "")
+	wantError(t, contract, txerror.CodeValidation, "CreateReward", "r2", "Café", "30", "1", "amanhã", "")
+	wantError(t, contract, txerror.CodeValidation, "AddCredits", "rider1", "0")
+	wantError(t, contract, txerror.CodeNotFound, "ReadReward", "r9")
+
+	setIdentity(t, contract, "Org1MSP", map[string]string{"riderID": "rider1"})
+	wantError(t, contract, txerror.CodeUnauthorized, "CreateReward", "r2", "Café", "30", "1", "", "")
+	wantError(t, contract, txerror.CodeUnauthorized, "AddCredits", "rider1", "10")
+	wantError(t, contract, txerror.CodeUnauthorized, "AwardTripCredits", "t1", "rider1")
+}
+
+func TestAwardTripCredits(t *testing.T) {
+	contract := newContract(t)
+	var ledger map[string]interface{}
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger["CreditsPerKm"] = 2
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4.7", "2024-03-04T08:20:00Z")
+	createTrip(t, contract, "t2", "2024-03-04T09:00:00Z", "0.2", "2024-03-04T09:05:00Z")
+
+	var award chaincode.TripCreditAward
+	submit(t, contract, &award, "AwardTripCredits", "t1", "rider1")
+	if award.Credits != 9 || award.RiderID != "rider1" || award.TripDataID != "t1" {
+		t.Errorf("créditos = %+v", award)
+	}
+	var balance chaincode.CreditBalance
+	evaluate(t, contract, &balance, "GetCreditBalance", "rider1")
+	if balance.Credits != 9 {
+		t.Errorf("saldo = %d, quer 9", balance.Credits)
+	}
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "AwardTripCredits", "t1", "rider1")
+	wantError(t, contract, txerror.CodeValidation, "AwardTripCredits", "t2", "rider1")
+	wantError(t, contract, txerror.CodeValidation, "AwardTripCredits", "t2", "")
+	wantError(t, contract, txerror.CodeNotFound, "AwardTripCredits", "t9", "rider1")
+
+	// A distância corrigida por uma contestação aceita é a que rende créditos
+	createTrip(t, contract, "t3", "2024-03-04T10:00:00Z", "10", "2024-03-04T10:40:00Z")
+	submit(t, contract, nil, "OpenDispute", "d1", "t3", "distância errada", evidenceHash)
+	submit(t, contract, nil, "StartDisputeReview", "d1")
+	submit(t, contract, nil, "AcceptDispute", "d1", "3", "corrigida pelo GPS")
+	submit(t, contract, &award, "AwardTripCredits", "t3", "rider1")
+	if award.Credits != 6 {
+		t.Errorf("créditos da viagem corrigida = %d, quer 6", award.Credits)
+	}
+}
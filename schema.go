@@ -0,0 +1,232 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// Versões do esquema de TripData gravado no estado mundial.
+//
+// A versão 1 é o formato de Chaincodemove.go, sem o campo ID e sem SchemaVersion;
+// a viagem era identificada só pela chave. A versão 2 acrescenta ID, RiderID,
+// DepartureSlot e grava SchemaVersion em todos os registros.
+const (
+	tripSchemaV1 = 1
+	tripSchemaV2 = 2
+
+	// tripSchemaVersion é a versão gravada por todas as escritas
+	tripSchemaVersion = tripSchemaV2
+)
+
+// maxMigrationPageSize limita quantos registros MigrateTrips lê numa transação
+const maxMigrationPageSize = 1000
+
+// MigrationResult resume uma página da migração. Bookmark é por onde a próxima chamada
+// deve continuar: a chave simples do próximo registro ou, na seção das chaves das
+// operadoras, a referência <operadora>:<ID> da próxima viagem. Fica vazio quando não há
+// mais registros.
+type MigrationResult struct {
+	FromVersion int    `json:"FromVersion"`
+	ToVersion   int    `json:"ToVersion"`
+	Scanned     int    `json:"Scanned"`
+	Migrated    int    `json:"Migrated"`
+	Skipped     int    `json:"Skipped"`
+	Bookmark    string `json:"Bookmark"`
+}
+
+// MigrateTrips regrava na versão atual os dados de viagem gravados em fromVersion, lendo
+// no máximo pageSize registros a partir de bookmark. Percorre primeiro as chaves simples
+// e depois as chaves trip/<operadora>/<ID>, como GetTripDataPage. Para migrar tudo, chame
+// de novo com o Bookmark devolvido até que ele venha vazio. Como toda transação de
+// AdminContract, só a operadora pode migrar registros.
+//
+// A paginação é feita com GetStateByRange a partir do bookmark, e não com
+// GetStateByRangeWithPagination, que o Fabric só aceita em consultas somente leitura.
+// Pelo mesmo motivo, e porque as chaves compostas não aceitam consulta por faixa, as
+// viagens das operadoras anteriores ao bookmark são lidas e puladas.
+func (ac *AdminContract) MigrateTrips(ctx txcontext.TransactionContextInterface, fromVersion int, pageSize int, bookmark string) (*MigrationResult, error) {
+	if fromVersion < tripSchemaV1 || fromVersion >= tripSchemaVersion {
+		return nil, txerror.Validation("versão de origem inválida %d: deve estar entre %d e %d", fromVersion, tripSchemaV1, tripSchemaVersion-1)
+	}
+	if pageSize < 1 || pageSize > maxMigrationPageSize {
+		return nil, txerror.Validation("o tamanho da página deve estar entre 1 e %d", maxMigrationPageSize)
+	}
+
+	result := &MigrationResult{FromVersion: fromVersion, ToVersion: tripSchemaVersion}
+	if !strings.Contains(bookmark, tripRefSeparator) {
+		resultsIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+		}
+		defer resultsIterator.Close()
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+			}
+			if result.Scanned == pageSize {
+				result.Bookmark = queryResponse.Key
+				return result, nil
+			}
+			if err := migrateTripRecord(ctx, result, queryResponse.Key, queryResponse.Key, "", queryResponse.Value); err != nil {
+				return nil, err
+			}
+		}
+		bookmark = ""
+	}
+
+	if operatorID, id, ok := strings.Cut(bookmark, tripRefSeparator); ok && (operatorID == "" || id == "") {
+		return nil, txerror.Validation("marcador de página inválido %q", bookmark)
+	}
+	startKey, err := tripKey(ctx, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tripObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter dados de viagem")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+		if queryResponse.Key < startKey {
+			continue
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(keyParts) != 2 {
+			return nil, txerror.Internal("chave de dados de viagem inválida %q", queryResponse.Key)
+		}
+		if result.Scanned == pageSize {
+			result.Bookmark = keyParts[0] + tripRefSeparator + keyParts[1]
+			return result, nil
+		}
+		if err := migrateTripRecord(ctx, result, queryResponse.Key, keyParts[1], keyParts[0], queryResponse.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// migrateTripRecord regrava na versão atual o registro da chave key, se ele for dados de
+// viagem na versão result.FromVersion, e conta o registro em result. id é o ID da viagem
+// na chave e operatorID, a operadora da chave composta; vazio nas chaves simples.
+func migrateTripRecord(ctx txcontext.TransactionContextInterface, result *MigrationResult, key string, id string, operatorID string, value []byte) error {
+	result.Scanned++
+
+	version, err := storedTripSchemaVersion(value)
+	if err != nil || !isTripRecord(value) {
+		// Registros que não são dados de viagem ficam como estão
+		result.Skipped++
+		return nil
+	}
+	if version != result.FromVersion {
+		return nil
+	}
+
+	tripData, err := unmarshalTripData(id, value)
+	if err != nil {
+		return err
+	}
+	if tripData.OperatorID == "" {
+		tripData.OperatorID = operatorID
+	}
+	tripDataJSON, err := json.Marshal(tripData)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter dados de viagem para JSON")
+	}
+	err = ctx.GetStub().PutState(key, tripDataJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar os dados de viagem migrados")
+	}
+	result.Migrated++
+
+	return nil
+}
+
+// unmarshalTripData lê dados de viagem gravados em qualquer versão do esquema e os
+// atualiza para a versão atual. key é a chave do registro no estado mundial.
+func unmarshalTripData(key string, value []byte) (*TripData, error) {
+	version, err := storedTripSchemaVersion(value)
+	if err != nil {
+		return nil, err
+	}
+	if version > tripSchemaVersion {
+		return nil, txerror.Internal("os dados de viagem %s estão na versão %d, mais nova que a suportada (%d)", key, version, tripSchemaVersion)
+	}
+
+	var tripData TripData
+	err = json.Unmarshal(value, &tripData)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal dos dados de viagem")
+	}
+
+	if version == tripSchemaV1 {
+		// A versão 1 não tinha ID; a chave é o identificador da viagem
+		if tripData.ID == "" {
+			tripData.ID = key
+		}
+	}
+	tripData.SchemaVersion = tripSchemaVersion
+
+	return &tripData, nil
+}
+
+// plainTripData lê o registro de uma chave simples como dados de viagem. ok é false
+// quando o registro não é uma viagem, como os lotes e os blocos que ficavam em chaves
+// simples antes de irem para chaves compostas; quem percorre as chaves simples os pula.
+func plainTripData(key string, value []byte) (tripData *TripData, ok bool, err error) {
+	version, err := storedTripSchemaVersion(value)
+	if err != nil {
+		return nil, false, nil
+	}
+	// Registros de versões mais novas podem ter campos que esta versão não conhece
+	if version <= tripSchemaVersion && !isTripRecord(value) {
+		return nil, false, nil
+	}
+
+	tripData, err = unmarshalTripData(key, value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return tripData, true, nil
+}
+
+// isTripRecord informa se value é um objeto JSON só com campos de TripData e com o ID
+// ou o TripID da viagem
+func isTripRecord(value []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	var tripData TripData
+	if err := decoder.Decode(&tripData); err != nil {
+		return false
+	}
+
+	return tripData.ID != "" || tripData.TripID != 0
+}
+
+// storedTripSchemaVersion retorna a versão do esquema gravada no registro.
+// Registros sem SchemaVersion são da versão 1.
+func storedTripSchemaVersion(value []byte) (int, error) {
+	var stamp struct {
+		SchemaVersion int `json:"SchemaVersion"`
+	}
+	if err := json.Unmarshal(value, &stamp); err != nil {
+		return 0, txerror.Wrap(err, "falha ao fazer unmarshal dos dados de viagem")
+	}
+	if stamp.SchemaVersion == 0 {
+		return tripSchemaV1, nil
+	}
+
+	return stamp.SchemaVersion, nil
+}
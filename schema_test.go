@@ -0,0 +1,181 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/txerror"
+)
+
+func TestMigrateTrips(t *testing.T) {
+	contract := newContract(t)
+	// Os registros da versão 1 não têm ID nem SchemaVersion
+	state := map[string][]byte{
+		"a": []byte(`{"Departure_Datetime":"2024-03-04 08:00:00","totalDistance_km":2,"TripID":1,"Arrival_Datetime":"2024-03-04 08:10:00"}`),
+		"b": []byte(`{"Departure_Datetime":"2024-03-04 09:00:00","totalDistance_km":3,"TripID":2,"Arrival_Datetime":"2024-03-04 09:10:00"}`),
+		"c": []byte(`{"ID":"c","Departure_Datetime":"2024-03-04 10:00:00","totalDistance_km":4,"TripID":3,"Arrival_Datetime":"2024-03-04 10:10:00","SchemaVersion":2}`),
+	}
+	path := loadState(t, contract, state)
+
+	var result chaincode.MigrationResult
+	submit(t, contract, &result, "AdminContract:MigrateTrips", "1", "2", "")
+	if result.Scanned != 2 || result.Migrated != 2 || result.Bookmark != "c" {
+		t.Errorf("primeira página = %+v", result)
+	}
+	submit(t, contract, &result, "AdminContract:MigrateTrips", "1", "2", result.Bookmark)
+	if result.Scanned != 1 || result.Migrated != 0 || result.Bookmark != "" {
+		t.Errorf("segunda página = %+v", result)
+	}
+
+	if err := contract.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	stateJSON, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		t.Fatal(err)
+	}
+	var migrated chaincode.TripData
+	if err := json.Unmarshal(state["a"], &migrated); err != nil {
+		t.Fatal(err)
+	}
+	if migrated.ID != "a" || migrated.SchemaVersion != 2 || migrated.TotalDistanceKm != 2 {
+		t.Errorf("registro migrado = %+v", migrated)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:MigrateTrips", "2", "10", "")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:MigrateTrips", "1", "0", "")
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:MigrateTrips", "1", "1001", "")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "AdminContract:MigrateTrips", "1", "10", "")
+}
+
+// As viagens das operadoras ficam em chaves compostas; a migração as percorre depois das
+// chaves simples, e o bookmark passa a ser a referência <operadora>:<ID>
+func TestMigrateOperatorTrips(t *testing.T) {
+	contract := newContract(t)
+	state := map[string][]byte{
+		"a":                          []byte(`{"Departure_Datetime":"2024-03-04 08:00:00","totalDistance_km":2,"TripID":1,"Arrival_Datetime":"2024-03-04 08:10:00"}`),
+		"\x00trip\x00opa\x00900\x00": []byte(`{"Departure_Datetime":"2024-03-04 09:00:00","totalDistance_km":3,"TripID":900,"Arrival_Datetime":"2024-03-04 09:10:00"}`),
+		"\x00trip\x00opa\x00901\x00": []byte(`{"Departure_Datetime":"2024-03-04 10:00:00","totalDistance_km":4,"TripID":901,"Arrival_Datetime":"2024-03-04 10:10:00"}`),
+	}
+	path := loadState(t, contract, state)
+
+	var result chaincode.MigrationResult
+	submit(t, contract, &result, "AdminContract:MigrateTrips", "1", "2", "")
+	if result.Scanned != 2 || result.Migrated != 2 || result.Bookmark != "opa:901" {
+		t.Errorf("primeira página = %+v", result)
+	}
+	submit(t, contract, &result, "AdminContract:MigrateTrips", "1", "2", result.Bookmark)
+	if result.Scanned != 1 || result.Migrated != 1 || result.Bookmark != "" {
+		t.Errorf("segunda página = %+v", result)
+	}
+
+	if err := contract.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	stateJSON, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		t.Fatal(err)
+	}
+	var migrated chaincode.TripData
+	if err := json.Unmarshal(state["\x00trip\x00opa\x00901\x00"], &migrated); err != nil {
+		t.Fatal(err)
+	}
+	if migrated.ID != "901" || migrated.OperatorID != "opa" || migrated.SchemaVersion != 2 {
+		t.Errorf("registro migrado = %+v", migrated)
+	}
+
+	wantError(t, contract, txerror.CodeValidation, "AdminContract:MigrateTrips", "1", "2", "opa:")
+}
+
+// Os registros das chaves simples que não são viagens, como os lotes e os blocos gravados
+// ali por versões anteriores, não são migrados nem listados
+func TestMigrateTripsSkipsOtherRecords(t *testing.T) {
+	contract := newContract(t)
+	blockchain := []byte(`{"blocks":[{"transactions":[]}]}`)
+	state := map[string][]byte{
+		"a":          []byte(`{"Departure_Datetime":"2024-03-04 08:00:00","totalDistance_km":2,"TripID":1,"Arrival_Datetime":"2024-03-04 08:10:00"}`),
+		"blockchain": blockchain,
+		"tx1":        []byte(`[{"TripID":1,"totalDistance_km":3}]`),
+		"tx2":        []byte(`{"totalDistance_km":3}`),
+	}
+	path := loadState(t, contract, state)
+
+	var result chaincode.MigrationResult
+	submit(t, contract, &result, "AdminContract:MigrateTrips", "1", "10", "")
+	if result.Scanned != 4 || result.Migrated != 1 || result.Skipped != 3 || result.Bookmark != "" {
+		t.Errorf("migração = %+v", result)
+	}
+
+	var trips []*chaincode.TripData
+	evaluate(t, contract, &trips, "GetAllTripData")
+	if len(trips) != 1 || trips[0].ID != "a" {
+		t.Errorf("viagens = %+v", trips)
+	}
+	blocks, err := contract.Evaluate("BatchContract:GetBlocks")
+	if err != nil {
+		t.Fatalf("GetBlocks: %v", err)
+	}
+	if string(blocks) != string(blockchain) {
+		t.Errorf("blocos = %s, quer %s", blocks, blockchain)
+	}
+
+	if err := contract.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	stateJSON, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string][]byte
+	if err := json.Unmarshal(stateJSON, &saved); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"blockchain", "tx1", "tx2"} {
+		if string(saved[key]) != string(state[key]) {
+			t.Errorf("registro %s = %s, quer %s", key, saved[key], state[key])
+		}
+	}
+}
+
+// loadState acrescenta state ao estado do contrato, que guarda a configuração gravada por
+// newContract, e o carrega de novo pelo arquivo; devolve o caminho do arquivo
+func loadState(t *testing.T, contract *mock.Backend, state map[string][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := contract.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	stateJSON, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := map[string][]byte{}
+	if err := json.Unmarshal(stateJSON, &merged); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range state {
+		merged[key] = value
+	}
+	if stateJSON, err = json.Marshal(merged); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, stateJSON, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := contract.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	return path
+}
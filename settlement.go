@@ -0,0 +1,331 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// settlementObjectType é o tipo de objeto das chaves compostas dos acertos entre organizações
+const settlementObjectType = "settlement"
+
+// Estados de um acerto: pending → approved, quando todas as organizações aprovam, ou
+// disputed, quando alguma contesta. Um acerto contestado pode ser gerado de novo.
+const (
+	SettlementPending  = "pending"
+	SettlementApproved = "approved"
+	SettlementDisputed = "disputed"
+)
+
+// SettlementLine soma as cobranças das viagens de uma organização no período
+type SettlementLine struct {
+	Organisation string  `json:"Organisation"`
+	Charges      int     `json:"Charges"`
+	DistanceKm   float64 `json:"DistanceKm"`
+	AmountCents  int64   `json:"AmountCents"`
+}
+
+// SettlementSignoff é a aprovação ou contestação do acerto por uma organização
+type SettlementSignoff struct {
+	MSP      string `json:"MSP"`
+	Decision string `json:"Decision"`
+	Reason   string `json:"Reason,omitempty" metadata:",optional"`
+	SignedBy string `json:"SignedBy"`
+	SignedAt string `json:"SignedAt"`
+}
+
+// Settlement é o demonstrativo do acerto entre as organizações num período: as cobranças
+// e a distância agregadas por organização dona da viagem, o hash das cobranças que o
+// compõem e as assinaturas de cada organização participante (Parties).
+type Settlement struct {
+	Period      string              `json:"Period"`
+	Revision    int                 `json:"Revision"`
+	Status      string              `json:"Status"`
+	Lines       []SettlementLine    `json:"Lines"`
+	Charges     int                 `json:"Charges"`
+	DistanceKm  float64             `json:"DistanceKm"`
+	AmountCents int64               `json:"AmountCents"`
+	Hash        string              `json:"Hash"`
+	Parties     []string            `json:"Parties"`
+	Signoffs    []SettlementSignoff `json:"Signoffs"`
+	GeneratedBy string              `json:"GeneratedBy"`
+	GeneratedAt string              `json:"GeneratedAt"`
+	FinalizedAt string              `json:"FinalizedAt,omitempty" metadata:",optional"`
+}
+
+// GenerateSettlement gera o demonstrativo do acerto das cobranças das viagens que
+// partiram no período (AAAA, AAAA-MM ou AAAA-MM-DD). Só a operadora gera acertos. Cada
+// período tem um acerto; ele só pode ser gerado de novo depois de contestado, e a nova
+// revisão descarta as assinaturas da anterior. Um período que se sobrepõe ao de outro
+// acerto não contestado (2024 e 2024-05, por exemplo) é recusado, para que nenhuma
+// cobrança seja acertada duas vezes.
+//
+// Participam do acerto as organizações donas das viagens cobradas e a organização que o
+// gerou. Cobranças de viagens sem dono entram na linha "desconhecido", que não assina.
+func (tc *TripContract) GenerateSettlement(ctx txcontext.TransactionContextInterface, period string) (*Settlement, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	previous, err := readSettlement(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	revision := 1
+	if previous != nil {
+		if previous.Status != SettlementDisputed {
+			return nil, txerror.AlreadyExists("o acerto do período %s já foi gerado e está %s", period, previous.Status)
+		}
+		revision = previous.Revision + 1
+	}
+	overlapping, err := overlappingSettlement(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping != nil {
+		return nil, txerror.AlreadyExists("o período %s se sobrepõe ao do acerto %s, que está %s", period, overlapping.Period, overlapping.Status)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(chargeObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as cobranças")
+	}
+	defer resultsIterator.Close()
+
+	settlement := &Settlement{
+		Period:      period,
+		Revision:    revision,
+		Status:      SettlementPending,
+		Signoffs:    []SettlementSignoff{},
+		GeneratedBy: ctx.CallerID(),
+		GeneratedAt: ctx.TxTime().Format(time.RFC3339),
+	}
+	lines := map[string]*SettlementLine{}
+	parties := map[string]bool{ctx.MSPID(): true}
+	// O iterador devolve as chaves ordenadas, então o hash do acerto é determinístico
+	hasher := sha256.New()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var charge Charge
+		err = json.Unmarshal(queryResponse.Value, &charge)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da cobrança")
+		}
+		departure, err := parseTripDatetime(charge.DepartureDatetime)
+		if err != nil || !strings.HasPrefix(departure.Format(tariffDateLayout), period) {
+			continue
+		}
+
+		organisation := charge.OwnerMSP
+		if organisation == "" {
+			organisation = unknownGroup
+		} else {
+			parties[organisation] = true
+		}
+		line, ok := lines[organisation]
+		if !ok {
+			line = &SettlementLine{Organisation: organisation}
+			lines[organisation] = line
+		}
+		line.Charges++
+		line.DistanceKm += charge.DistanceKm
+		line.AmountCents += charge.AmountCents
+
+		chargeHash := sha256.Sum256(queryResponse.Value)
+		hasher.Write([]byte(charge.TripDataID))
+		hasher.Write(chargeHash[:])
+		settlement.Charges++
+		settlement.DistanceKm += charge.DistanceKm
+		settlement.AmountCents += charge.AmountCents
+	}
+	if settlement.Charges == 0 {
+		return nil, txerror.NotFound("nenhuma cobrança encontrada no período %s", period)
+	}
+	settlement.Hash = hex.EncodeToString(hasher.Sum(nil))
+
+	for _, line := range lines {
+		settlement.Lines = append(settlement.Lines, *line)
+	}
+	sort.Slice(settlement.Lines, func(i, j int) bool { return settlement.Lines[i].Organisation < settlement.Lines[j].Organisation })
+	for party := range parties {
+		settlement.Parties = append(settlement.Parties, party)
+	}
+	sort.Strings(settlement.Parties)
+
+	if err := putSettlement(ctx, settlement); err != nil {
+		return nil, err
+	}
+
+	return settlement, nil
+}
+
+// ReadSettlement retorna o acerto do período fornecido.
+func (tc *TripContract) ReadSettlement(ctx txcontext.TransactionContextInterface, period string) (*Settlement, error) {
+	settlement, err := readSettlement(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		return nil, txerror.NotFound("o acerto do período %s não existe", period)
+	}
+
+	return settlement, nil
+}
+
+// ApproveSettlement registra a aprovação do acerto pela organização de quem assinou a
+// transação. O acerto fica aprovado, e passa a ser o demonstrativo final do período,
+// quando todas as organizações participantes aprovam.
+func (tc *TripContract) ApproveSettlement(ctx txcontext.TransactionContextInterface, period string) (*Settlement, error) {
+	return signSettlement(ctx, period, SettlementApproved, "")
+}
+
+// DisputeSettlement registra a contestação do acerto pela organização de quem assinou a
+// transação. O acerto fica contestado até ser gerado de novo.
+func (tc *TripContract) DisputeSettlement(ctx txcontext.TransactionContextInterface, period string, reason string) (*Settlement, error) {
+	if reason == "" {
+		return nil, txerror.Validation("o motivo da contestação é obrigatório")
+	}
+
+	return signSettlement(ctx, period, SettlementDisputed, reason)
+}
+
+// signSettlement grava a decisão da organização de quem assinou a transação sobre o
+// acerto pendente do período e atualiza o estado do acerto. Só as identidades de
+// operadora das organizações participantes podem decidir.
+func signSettlement(ctx txcontext.TransactionContextInterface, period string, decision string, reason string) (*Settlement, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+
+	settlement, err := readSettlement(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		return nil, txerror.NotFound("o acerto do período %s não existe", period)
+	}
+	if settlement.Status != SettlementPending {
+		return nil, txerror.Validation("o acerto do período %s está %s, não %s", period, settlement.Status, SettlementPending)
+	}
+
+	mspID := ctx.MSPID()
+	party := false
+	for _, candidate := range settlement.Parties {
+		party = party || candidate == mspID
+	}
+	if !party {
+		return nil, txerror.Unauthorized("a organização %s não participa do acerto do período %s", mspID, period)
+	}
+	for _, signoff := range settlement.Signoffs {
+		if signoff.MSP == mspID {
+			return nil, txerror.AlreadyExists("a organização %s já assinou o acerto do período %s", mspID, period)
+		}
+	}
+
+	now := ctx.TxTime().Format(time.RFC3339)
+	settlement.Signoffs = append(settlement.Signoffs, SettlementSignoff{
+		MSP:      mspID,
+		Decision: decision,
+		Reason:   reason,
+		SignedBy: ctx.CallerID(),
+		SignedAt: now,
+	})
+	if decision == SettlementDisputed {
+		settlement.Status = SettlementDisputed
+	} else if len(settlement.Signoffs) == len(settlement.Parties) {
+		settlement.Status = SettlementApproved
+		settlement.FinalizedAt = now
+	}
+
+	if err := putSettlement(ctx, settlement); err != nil {
+		return nil, err
+	}
+
+	return settlement, nil
+}
+
+// readSettlement retorna o acerto do período, ou nil se ele não foi gerado
+func readSettlement(ctx txcontext.TransactionContextInterface, period string) (*Settlement, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(settlementObjectType, []string{period})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave do acerto")
+	}
+	settlementJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+	if settlementJSON == nil {
+		return nil, nil
+	}
+
+	var settlement Settlement
+	err = json.Unmarshal(settlementJSON, &settlement)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao fazer unmarshal do acerto")
+	}
+
+	return &settlement, nil
+}
+
+// overlappingSettlement retorna o acerto não contestado de outro período que se sobrepõe
+// ao período fornecido, ou nil se não houver. Como em overlappingCertificate, dois
+// períodos se sobrepõem quando um é prefixo do outro.
+func overlappingSettlement(ctx txcontext.TransactionContextInterface, period string) (*Settlement, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(settlementObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter os acertos")
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var settlement Settlement
+		err = json.Unmarshal(queryResponse.Value, &settlement)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal do acerto")
+		}
+		if settlement.Period == period || settlement.Status == SettlementDisputed {
+			continue
+		}
+		if strings.HasPrefix(period, settlement.Period) || strings.HasPrefix(settlement.Period, period) {
+			return &settlement, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// putSettlement grava o acerto no estado mundial
+func putSettlement(ctx txcontext.TransactionContextInterface, settlement *Settlement) error {
+	key, err := ctx.GetStub().CreateCompositeKey(settlementObjectType, []string{settlement.Period})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave do acerto")
+	}
+	settlementJSON, err := json.Marshal(settlement)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter acerto para JSON")
+	}
+	err = ctx.GetStub().PutState(key, settlementJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar o acerto no estado mundial")
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/backend/mock"
+	"Chaincodemove/config"
+	"Chaincodemove/txerror"
+)
+
+// newSettlementContract aceita o Org2MSP e o Org3MSP como MSPs de operadora e grava e
+// cobra uma viagem do Org1MSP e outra do Org2MSP em 2024-03-04
+func newSettlementContract(t *testing.T) *mock.Backend {
+	t.Helper()
+	contract := newContract(t)
+	var ledger config.LedgerConfig
+	evaluate(t, contract, &ledger, "AdminContract:GetConfig")
+	ledger.OperatorMSPs = []string{"Org1MSP", "Org2MSP", "Org3MSP"}
+	submit(t, contract, nil, "AdminContract:SetConfig", toJSON(t, ledger))
+	submit(t, contract, nil, "CreateTariff", tariffJSON)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	setIdentity(t, contract, "Org2MSP", operator)
+	createTrip(t, contract, "t2", "2024-03-04T09:00:00Z", "2", "2024-03-04T09:10:00Z")
+	setIdentity(t, contract, "Org1MSP", operator)
+	submit(t, contract, nil, "CalculateFare", "t1")
+	submit(t, contract, nil, "CalculateFare", "t2")
+	return contract
+}
+
+func TestApproveSettlement(t *testing.T) {
+	contract := newSettlementContract(t)
+
+	var settlement chaincode.Settlement
+	submit(t, contract, &settlement, "GenerateSettlement", "2024-03")
+	if settlement.Status != chaincode.SettlementPending || settlement.Charges != 2 || settlement.AmountCents != 1000 || len(settlement.Lines) != 2 || settlement.Hash == "" {
+		t.Fatalf("acerto = %+v", settlement)
+	}
+	if len(settlement.Parties) != 2 || settlement.Parties[0] != "Org1MSP" || settlement.Parties[1] != "Org2MSP" {
+		t.Errorf("organizações = %v", settlement.Parties)
+	}
+
+	submit(t, contract, &settlement, "ApproveSettlement", "2024-03")
+	if settlement.Status != chaincode.SettlementPending {
+		t.Errorf("estado depois da primeira aprovação = %s", settlement.Status)
+	}
+	wantError(t, contract, txerror.CodeAlreadyExists, "ApproveSettlement", "2024-03")
+	setIdentity(t, contract, "Org3MSP", operator)
+	wantError(t, contract, txerror.CodeUnauthorized, "ApproveSettlement", "2024-03")
+
+	// Uma identidade de uma organização participante sem o papel de operadora não decide
+	setIdentity(t, contract, "Org2MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "ApproveSettlement", "2024-03")
+	wantError(t, contract, txerror.CodeUnauthorized, "DisputeSettlement", "2024-03", "valores errados")
+
+	setIdentity(t, contract, "Org2MSP", operator)
+	submit(t, contract, &settlement, "ApproveSettlement", "2024-03")
+	if settlement.Status != chaincode.SettlementApproved || settlement.FinalizedAt == "" {
+		t.Errorf("acerto aprovado = %+v", settlement)
+	}
+	wantError(t, contract, txerror.CodeValidation, "DisputeSettlement", "2024-03", "valores errados")
+
+	setIdentity(t, contract, "Org1MSP", operator)
+	wantError(t, contract, txerror.CodeAlreadyExists, "GenerateSettlement", "2024-03")
+}
+
+func TestDisputeSettlement(t *testing.T) {
+	contract := newSettlementContract(t)
+	submit(t, contract, nil, "GenerateSettlement", "2024-03")
+
+	setIdentity(t, contract, "Org2MSP", operator)
+	wantError(t, contract, txerror.CodeValidation, "DisputeSettlement", "2024-03", "")
+	var settlement chaincode.Settlement
+	submit(t, contract, &settlement, "DisputeSettlement", "2024-03", "valores errados")
+	if settlement.Status != chaincode.SettlementDisputed {
+		t.Errorf("acerto contestado = %+v", settlement)
+	}
+	setIdentity(t, contract, "Org2MSP", nil)
+	wantError(t, contract, txerror.CodeUnauthorized, "GenerateSettlement", "2024-03")
+
+	setIdentity(t, contract, "Org1MSP", operator)
+	submit(t, contract, &settlement, "GenerateSettlement", "2024-03")
+	if settlement.Revision != 2 || settlement.Status != chaincode.SettlementPending || len(settlement.Signoffs) != 0 {
+		t.Errorf("nova revisão = %+v", settlement)
+	}
+
+	wantError(t, contract, txerror.CodeNotFound, "GenerateSettlement", "2025")
+	wantError(t, contract, txerror.CodeValidation, "GenerateSettlement", "março")
+	wantError(t, contract, txerror.CodeNotFound, "ReadSettlement", "2025")
+	wantError(t, contract, txerror.CodeNotFound, "ApproveSettlement", "2025")
+}
+
+func TestGenerateSettlementRejectsOverlappingPeriods(t *testing.T) {
+	contract := newSettlementContract(t)
+	submit(t, contract, nil, "GenerateSettlement", "2024-03")
+
+	wantError(t, contract, txerror.CodeAlreadyExists, "GenerateSettlement", "2024")
+	wantError(t, contract, txerror.CodeAlreadyExists, "GenerateSettlement", "2024-03-04")
+
+	// Contestado, o acerto do mês não impede o do ano, que depois impede o do dia
+	setIdentity(t, contract, "Org2MSP", operator)
+	submit(t, contract, nil, "DisputeSettlement", "2024-03", "valores errados")
+	setIdentity(t, contract, "Org1MSP", operator)
+	var settlement chaincode.Settlement
+	submit(t, contract, &settlement, "GenerateSettlement", "2024")
+	if settlement.Charges != 2 || settlement.Revision != 1 {
+		t.Errorf("acerto do ano = %+v", settlement)
+	}
+	wantError(t, contract, txerror.CodeAlreadyExists, "GenerateSettlement", "2024-03-04")
+	wantError(t, contract, txerror.CodeAlreadyExists, "GenerateSettlement", "2024-03")
+}
@@ -0,0 +1,166 @@
+// Package source lê as viagens do banco MySQL moveuff e calcula o hash canônico
+// que ancora cada lote lido no livro-razão.
+package source
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// DateLayout é o formato das datas que delimitam a janela de consulta
+const DateLayout = "2006-01-02"
+
+// tripsQuery seleciona as viagens cuja partida e chegada caem dentro da janela
+const tripsQuery = `
+	SELECT
+		departure.id AS Departure_Datetime,
+		trips.totalDistance_km,
+		trips.id AS TripID,
+		arrival.id AS Arrival_Datetime
+	FROM trip_x_parkingslot_departures AS departure
+	JOIN trips ON departure.Trips_id = trips.id
+	JOIN trip_x_parkingslot_arrivals AS arrival ON arrival.Trips_id = trips.id
+	WHERE DATE(departure.id) BETWEEN ? AND ? AND DATE(arrival.id) BETWEEN ? AND ?
+`
+
+// TripRow representa uma linha lida do banco, com os mesmos nomes de campo do JSON gravado no livro-razão
+type TripRow struct {
+	DepartureDatetime string  `json:"Departure_Datetime"`
+	TotalDistanceKm   float64 `json:"totalDistance_km"`
+	TripID            int     `json:"TripID"`
+	ArrivalDatetime   string  `json:"Arrival_Datetime"`
+}
+
+// Window delimita as datas (inclusivas, no formato AAAA-MM-DD) de uma consulta
+type Window struct {
+	From string `json:"From"`
+	To   string `json:"To"`
+}
+
+// LastDaysWindow retorna a janela dos últimos days dias, terminando no dia de t
+func LastDaysWindow(t time.Time, days int) Window {
+	return Window{
+		From: t.AddDate(0, 0, -(days - 1)).Format(DateLayout),
+		To:   t.Format(DateLayout),
+	}
+}
+
+// Validate verifica se as datas da janela são válidas e estão em ordem
+func (w Window) Validate() error {
+	from, err := time.Parse(DateLayout, w.From)
+	if err != nil {
+		return fmt.Errorf("data inicial inválida %q: %v", w.From, err)
+	}
+	to, err := time.Parse(DateLayout, w.To)
+	if err != nil {
+		return fmt.Errorf("data final inválida %q: %v", w.To, err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("a data final %s é anterior à inicial %s", w.To, w.From)
+	}
+
+	return nil
+}
+
+// Contains informa se a data/hora fornecida (AAAA-MM-DD...) cai dentro da janela
+func (w Window) Contains(datetime string) bool {
+	if len(datetime) < len(DateLayout) {
+		return false
+	}
+	day := datetime[:len(DateLayout)]
+	if _, err := time.Parse(DateLayout, day); err != nil {
+		return false
+	}
+
+	return day >= w.From && day <= w.To
+}
+
+// ContainsTrip informa se a partida e a chegada da viagem caem dentro da janela, o mesmo
+// critério com que QueryTrips seleciona as viagens do banco
+func (w Window) ContainsTrip(trip TripRow) bool {
+	return w.Contains(trip.DepartureDatetime) && w.Contains(trip.ArrivalDatetime)
+}
+
+// TripSource é a origem das viagens fora do livro-razão
+type TripSource interface {
+	Trips(w Window) ([]TripRow, error)
+}
+
+// MySQLSource lê as viagens do banco moveuff
+type MySQLSource struct {
+	DB *sql.DB
+}
+
+// Trips lê do banco as viagens da janela fornecida
+func (s *MySQLSource) Trips(w Window) ([]TripRow, error) {
+	return QueryTrips(s.DB, w)
+}
+
+// Anchor registra no livro-razão o hash canônico de um lote lido do banco
+type Anchor struct {
+	TxID      string `json:"TxID"`
+	Hash      string `json:"Hash"`
+	RowCount  int    `json:"RowCount"`
+	Window    Window `json:"Window"`
+	CreatedAt string `json:"CreatedAt"`
+}
+
+// QueryTrips lê do banco as viagens da janela fornecida
+func QueryTrips(db *sql.DB, w Window) ([]TripRow, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(tripsQuery, w.From, w.To, w.From, w.To)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao executar a query: %v", err)
+	}
+	defer rows.Close()
+
+	var trips []TripRow
+	for rows.Next() {
+		var trip TripRow
+		err := rows.Scan(&trip.DepartureDatetime, &trip.TotalDistanceKm, &trip.TripID, &trip.ArrivalDatetime)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler os valores do resultado: %v", err)
+		}
+		trips = append(trips, trip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("falha ao iterar sobre as linhas: %v", err)
+	}
+
+	return trips, nil
+}
+
+// CanonicalHash calcula o SHA-256 da forma canônica das linhas: ordenadas por
+// TripID e datas, serializadas como JSON compacto com campos em ordem fixa.
+// A ordem em que o banco devolve as linhas não altera o hash.
+func CanonicalHash(trips []TripRow) (string, error) {
+	sorted := make([]TripRow, len(trips))
+	copy(sorted, trips)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TripID != sorted[j].TripID {
+			return sorted[i].TripID < sorted[j].TripID
+		}
+		if sorted[i].DepartureDatetime != sorted[j].DepartureDatetime {
+			return sorted[i].DepartureDatetime < sorted[j].DepartureDatetime
+		}
+		return sorted[i].ArrivalDatetime < sorted[j].ArrivalDatetime
+	})
+
+	canonicalJSON, err := json.Marshal(sorted)
+	if err != nil {
+		return "", fmt.Errorf("falha ao serializar as linhas na forma canônica: %v", err)
+	}
+	hash := sha256.Sum256(canonicalJSON)
+
+	return hex.EncodeToString(hash[:]), nil
+}
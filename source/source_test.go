@@ -0,0 +1,122 @@
+package source_test
+
+import (
+	"testing"
+	"time"
+
+	"Chaincodemove/source"
+)
+
+func TestCanonicalHash(t *testing.T) {
+	rows := []source.TripRow{
+		{DepartureDatetime: "2024-03-04 08:00:00", TotalDistanceKm: 4.5, TripID: 2, ArrivalDatetime: "2024-03-04 08:20:00"},
+		{DepartureDatetime: "2024-03-04 09:00:00", TotalDistanceKm: 2, TripID: 1, ArrivalDatetime: "2024-03-04 09:10:00"},
+		{DepartureDatetime: "2024-03-04 07:00:00", TotalDistanceKm: 3, TripID: 1, ArrivalDatetime: "2024-03-04 07:10:00"},
+	}
+	want, err := source.CanonicalHash(rows)
+	if err != nil {
+		t.Fatalf("CanonicalHash: %v", err)
+	}
+	if len(want) != 64 {
+		t.Fatalf("hash = %q, quer 64 dígitos hexadecimais", want)
+	}
+
+	reordered := []source.TripRow{rows[2], rows[0], rows[1]}
+	if got, _ := source.CanonicalHash(reordered); got != want {
+		t.Errorf("hash das linhas reordenadas = %s, quer %s", got, want)
+	}
+	if rows[0].TripID != 2 {
+		t.Error("CanonicalHash alterou a ordem das linhas recebidas")
+	}
+
+	changed := append([]source.TripRow{}, rows...)
+	changed[1].TotalDistanceKm = 2.1
+	if got, _ := source.CanonicalHash(changed); got == want {
+		t.Error("o hash não mudou com a distância alterada")
+	}
+	if got, _ := source.CanonicalHash(rows[:2]); got == want {
+		t.Error("o hash não mudou sem uma das linhas")
+	}
+
+	empty, err := source.CanonicalHash(nil)
+	if err != nil {
+		t.Fatalf("CanonicalHash(nil): %v", err)
+	}
+	if got, _ := source.CanonicalHash([]source.TripRow{}); got != empty {
+		t.Errorf("hash da lista vazia = %s, quer o de nil (%s)", got, empty)
+	}
+}
+
+func TestLastDaysWindow(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 01:30 em UTC ainda é o dia anterior em São Paulo
+	txTime := time.Date(2024, 3, 5, 1, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    time.Time
+		days int
+		want source.Window
+	}{
+		{"um dia", txTime, 1, source.Window{From: "2024-03-05", To: "2024-03-05"}},
+		{"sete dias", txTime, 7, source.Window{From: "2024-02-28", To: "2024-03-05"}},
+		{"fuso", txTime.In(saoPaulo), 1, source.Window{From: "2024-03-04", To: "2024-03-04"}},
+	}
+	for _, tt := range tests {
+		if got := source.LastDaysWindow(tt.t, tt.days); got != tt.want {
+			t.Errorf("%s: janela = %+v, quer %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindowValidate(t *testing.T) {
+	tests := []struct {
+		window source.Window
+		ok     bool
+	}{
+		{source.Window{From: "2024-03-01", To: "2024-03-31"}, true},
+		{source.Window{From: "2024-03-01", To: "2024-03-01"}, true},
+		{source.Window{From: "2024-03-31", To: "2024-03-01"}, false},
+		{source.Window{From: "01/03/2024", To: "2024-03-31"}, false},
+		{source.Window{From: "2024-03-01", To: ""}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.window.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v): %v", tt.window, err)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	window := source.Window{From: "2024-03-01", To: "2024-03-31"}
+	tests := []struct {
+		datetime string
+		want     bool
+	}{
+		{"2024-03-01 00:00:00", true},
+		{"2024-03-31 23:59:59", true},
+		{"2024-03-15T08:00:00Z", true},
+		{"2024-03-15", true},
+		{"2024-02-29 23:59:59", false},
+		{"2024-04-01 00:00:00", false},
+		{"2024-03", false},
+		{"março de 2024", false},
+	}
+	for _, tt := range tests {
+		if got := window.Contains(tt.datetime); got != tt.want {
+			t.Errorf("Contains(%q) = %v, quer %v", tt.datetime, got, tt.want)
+		}
+	}
+
+	// A viagem só está na janela se partir e chegar dentro dela
+	trip := source.TripRow{DepartureDatetime: "2024-03-31 23:50:00", ArrivalDatetime: "2024-04-01 00:10:00"}
+	if window.ContainsTrip(trip) {
+		t.Errorf("ContainsTrip(%+v) = true, quer false", trip)
+	}
+	trip.ArrivalDatetime = "2024-03-31 23:59:00"
+	if !window.ContainsTrip(trip) {
+		t.Errorf("ContainsTrip(%+v) = false, quer true", trip)
+	}
+}
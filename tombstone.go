@@ -0,0 +1,144 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"time"
+
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// tombstoneObjectType é o tipo da chave composta das lápides. Excluir dados de viagem
+// grava uma lápide em vez de apagar o registro, que continua no estado mundial para auditoria.
+const tombstoneObjectType = "tombstone"
+
+// TripTombstone registra a exclusão de dados de viagem: quem excluiu, quando e por quê.
+// ID é a referência da viagem (veja ReadTripData).
+type TripTombstone struct {
+	ID        string   `json:"ID"`
+	DeletedBy string   `json:"DeletedBy"`
+	Reason    string   `json:"Reason"`
+	DeletedAt string   `json:"DeletedAt"`
+	TripData  TripData `json:"TripData"`
+}
+
+// ListDeletedTrips retorna as lápides de todos os dados de viagem excluídos.
+// Só a operadora pode listá-las, como só ela pode restaurar as viagens.
+func (tc *TripContract) ListDeletedTrips(ctx txcontext.TransactionContextInterface) ([]*TripTombstone, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tombstoneObjectType, []string{})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao obter as viagens excluídas")
+	}
+	defer resultsIterator.Close()
+
+	var tombstones []*TripTombstone
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao iterar sobre resultados de consulta")
+		}
+
+		var tombstone TripTombstone
+		err = json.Unmarshal(queryResponse.Value, &tombstone)
+		if err != nil {
+			return nil, txerror.Wrap(err, "falha ao fazer unmarshal da lápide")
+		}
+		tombstones = append(tombstones, &tombstone)
+	}
+
+	return tombstones, nil
+}
+
+// RestoreTripData remove a lápide e devolve os dados de viagem às consultas e à revisão
+// de anomalias.
+// Só a operadora pode restaurar viagens; o histórico da lápide continua no livro-razão.
+func (tc *TripContract) RestoreTripData(ctx txcontext.TransactionContextInterface, id string) (*TripData, error) {
+	if err := requireRole(ctx, operatorRole); err != nil {
+		return nil, err
+	}
+	deleted, err := tripDataDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, txerror.Validation("os dados de viagem %s não foram excluídos", id)
+	}
+
+	// A viagem é lida sem olhar a lápide, porque as leituras do Fabric não veem a remoção
+	// feita por esta mesma transação
+	tripData, _, err := readStoredTripData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tripData == nil {
+		return nil, txerror.NotFound("os dados de viagem %s não existem", id)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(tombstoneObjectType, []string{id})
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao criar a chave da lápide")
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return nil, txerror.Wrap(err, "falha ao remover a lápide do estado mundial")
+	}
+
+	latest, err := latestTripVersion(ctx, tripData)
+	if err != nil {
+		return nil, err
+	}
+	if err := aggregateTripData(ctx, latest); err != nil {
+		return nil, err
+	}
+	if err := indexTripFlags(ctx, latest); err != nil {
+		return nil, err
+	}
+
+	return tripData, nil
+}
+
+// putTombstone grava a lápide dos dados de viagem com a identidade que os excluiu
+func putTombstone(ctx txcontext.TransactionContextInterface, tripData *TripData, reason string) error {
+	deletedBy := ctx.CallerID()
+	now := ctx.TxTime()
+
+	tombstone := TripTombstone{
+		ID:        tripRef(tripData),
+		DeletedBy: deletedBy,
+		Reason:    reason,
+		DeletedAt: now.Format(time.RFC3339),
+		TripData:  *tripData,
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(tombstoneObjectType, []string{tripRef(tripData)})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da lápide")
+	}
+	tombstoneJSON, err := json.Marshal(tombstone)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter lápide para JSON")
+	}
+	err = ctx.GetStub().PutState(key, tombstoneJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a lápide no estado mundial")
+	}
+
+	return nil
+}
+
+// tripDataDeleted retorna true quando os dados de viagem com a referência fornecida têm lápide
+func tripDataDeleted(ctx txcontext.TransactionContextInterface, ref string) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(tombstoneObjectType, []string{ref})
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao criar a chave da lápide")
+	}
+	tombstoneJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, txerror.Wrap(err, "falha ao ler do estado mundial")
+	}
+
+	return tombstoneJSON != nil, nil
+}
@@ -0,0 +1,52 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	chaincode "Chaincodemove"
+	"Chaincodemove/txerror"
+)
+
+func TestDeleteAndRestoreTripData(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+	submit(t, contract, nil, "DeleteTripData", "t1", "duplicada")
+
+	wantError(t, contract, txerror.CodeNotFound, "ReadTripData", "t1")
+	var tombstones []*chaincode.TripTombstone
+	evaluate(t, contract, &tombstones, "ListDeletedTrips")
+	if len(tombstones) != 1 || tombstones[0].ID != "t1" || tombstones[0].Reason != "duplicada" || tombstones[0].TripData.TotalDistanceKm != 4 {
+		t.Fatalf("lápides = %+v", tombstones)
+	}
+
+	var restored chaincode.TripData
+	submit(t, contract, &restored, "RestoreTripData", "t1")
+	if restored.ID != "t1" || restored.TotalDistanceKm != 4 {
+		t.Errorf("viagem restaurada = %+v", restored)
+	}
+	evaluate(t, contract, nil, "ReadTripData", "t1")
+	var remaining []*chaincode.TripTombstone
+	evaluate(t, contract, &remaining, "ListDeletedTrips")
+	if len(remaining) != 0 {
+		t.Errorf("lápides depois de restaurar = %+v", remaining)
+	}
+}
+
+func TestDeleteAndRestoreTripDataErrors(t *testing.T) {
+	contract := newContract(t)
+	createTrip(t, contract, "t1", "2024-03-04T08:00:00Z", "4", "2024-03-04T08:20:00Z")
+
+	wantError(t, contract, txerror.CodeValidation, "DeleteTripData", "t1", "")
+	wantError(t, contract, txerror.CodeNotFound, "DeleteTripData", "t9", "duplicada")
+	wantError(t, contract, txerror.CodeValidation, "RestoreTripData", "t1")
+
+	setIdentity(t, contract, "Org2MSP", operator)
+	wantError(t, contract, txerror.CodeUnauthorized, "DeleteTripData", "t1", "duplicada")
+
+	setIdentity(t, contract, "Org1MSP", nil)
+	submit(t, contract, nil, "DeleteTripData", "t1", "duplicada")
+	wantError(t, contract, txerror.CodeUnauthorized, "RestoreTripData", "t1")
+	wantError(t, contract, txerror.CodeUnauthorized, "ListDeletedTrips")
+	setIdentity(t, contract, "Org2MSP", operator)
+	wantError(t, contract, txerror.CodeUnauthorized, "ListDeletedTrips")
+}
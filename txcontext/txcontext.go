@@ -0,0 +1,349 @@
+// Package txcontext define o contexto de transação compartilhado pelos contratos do
+// chaincode (TripContract, BatchContract, IngestContract e AdminContract).
+//
+// Cada contrato registrado em contractapi.NewChaincode recebe um contexto novo por
+// transação, criado a partir do TransactionContextHandler do contrato. Todos usam o
+// mesmo tipo, para que as funções auxiliares e as verificações feitas antes das
+// transações valham igualmente em qualquer contrato.
+//
+// O BeforeTransaction dos contratos chama Resolve uma vez por transação: a identidade
+// de quem assinou, o carimbo de data/hora, a configuração (a do ambiente com a do
+// estado mundial por cima) e a pausa de emergência ficam guardados no contexto e as
+// transações não precisam lê-los de novo nem tratar os erros de cada leitura.
+package txcontext
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"Chaincodemove/config"
+	"Chaincodemove/txerror"
+)
+
+// RoleAttribute é o atributo da Fabric CA que guarda o papel da identidade
+// (hf.Registrar.Attributes=role ao registrar o usuário).
+const RoleAttribute = "role"
+
+// RiderIDAttribute é o atributo da Fabric CA que guarda o ID do ciclista dono da
+// identidade, para as transações que o ciclista faz em nome próprio.
+const RiderIDAttribute = "riderID"
+
+// Chaves compostas config/<nome> em que AdminContract grava a configuração e a pausa.
+// Resolve as lê em todas as transações de todos os contratos.
+const (
+	ConfigObjectType = "config"
+	LedgerConfigKey  = "ledger"
+	PauseKey         = "pause"
+)
+
+// PauseState é a pausa de emergência gravada por AdminContract.Pause e Resume. Enquanto
+// Paused for true, as transações que gravam no estado mundial falham logo no início.
+type PauseState struct {
+	Paused    bool   `json:"Paused"`
+	Reason    string `json:"Reason"`
+	ChangedBy string `json:"ChangedBy"`
+	ChangedAt string `json:"ChangedAt"`
+}
+
+// TransactionContextInterface é o contexto recebido pelas transações dos contratos
+type TransactionContextInterface interface {
+	contractapi.TransactionContextInterface
+
+	// Resolve lê a identidade, o carimbo de data/hora e a pausa e guarda cfg com a
+	// configuração do estado mundial por cima
+	Resolve(cfg *config.Config) error
+	// MSPID retorna o MSP da identidade que assinou a transação
+	MSPID() string
+	// CallerID retorna a identidade que assinou a transação no formato MSP/ID do certificado
+	CallerID() string
+	// Role retorna o atributo role da identidade, vazio se ela não tiver um
+	Role() string
+	// RiderID retorna o atributo riderID da identidade, vazio se ela não tiver um
+	RiderID() string
+	// TxTime retorna o carimbo de data/hora da transação em UTC
+	TxTime() time.Time
+	// Config retorna a configuração do contrato
+	Config() *config.Config
+	// Pause retorna o estado da pausa de emergência
+	Pause() PauseState
+}
+
+// TransactionContext implementa TransactionContextInterface sobre o contexto padrão do contractapi
+type TransactionContext struct {
+	contractapi.TransactionContext
+
+	mspID    string
+	callerID string
+	role     string
+	riderID  string
+	txTime   time.Time
+	cfg      *config.Config
+	pause    PauseState
+}
+
+// Resolve lê a identidade, o carimbo de data/hora e a pausa e guarda cfg com a
+// configuração do estado mundial por cima
+func (c *TransactionContext) Resolve(cfg *config.Config) error {
+	identity := c.GetClientIdentity()
+	if identity == nil {
+		return txerror.Unauthorized("a identidade que assinou a transação não pôde ser lida")
+	}
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler o MSP da identidade")
+	}
+	id, err := identity.GetID()
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler a identidade")
+	}
+	role, _, err := identity.GetAttributeValue(RoleAttribute)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler o papel da identidade")
+	}
+	riderID, _, err := identity.GetAttributeValue(RiderIDAttribute)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler o ciclista da identidade")
+	}
+	timestamp, err := c.GetStub().GetTxTimestamp()
+	if err != nil {
+		return txerror.Wrap(err, "falha ao obter o carimbo de data/hora da transação")
+	}
+
+	var ledger *config.LedgerConfig
+	if err := c.readConfig(LedgerConfigKey, &ledger); err != nil {
+		return err
+	}
+	if ledger != nil {
+		cfg = cfg.WithLedger(*ledger)
+	}
+	var pause PauseState
+	if err := c.readConfig(PauseKey, &pause); err != nil {
+		return err
+	}
+
+	c.mspID = mspID
+	c.callerID = mspID + "/" + id
+	c.role = role
+	c.riderID = riderID
+	c.txTime = timestamp.AsTime().UTC()
+	c.cfg = cfg
+	c.pause = pause
+
+	return nil
+}
+
+// readConfig lê o registro config/<name> em value; registros ausentes deixam value como está
+func (c *TransactionContext) readConfig(name string, value interface{}) error {
+	key, err := c.GetStub().CreateCompositeKey(ConfigObjectType, []string{name})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da configuração %s", name)
+	}
+	valueJSON, err := c.GetStub().GetState(key)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao ler a configuração %s do estado mundial", name)
+	}
+	if valueJSON == nil {
+		return nil
+	}
+	if err := json.Unmarshal(valueJSON, value); err != nil {
+		return txerror.Wrap(err, "falha ao fazer unmarshal da configuração %s", name)
+	}
+
+	return nil
+}
+
+// MSPID retorna o MSP da identidade que assinou a transação
+func (c *TransactionContext) MSPID() string {
+	return c.mspID
+}
+
+// CallerID retorna a identidade que assinou a transação no formato MSP/ID do certificado
+func (c *TransactionContext) CallerID() string {
+	return c.callerID
+}
+
+// Role retorna o atributo role da identidade, vazio se ela não tiver um
+func (c *TransactionContext) Role() string {
+	return c.role
+}
+
+// RiderID retorna o atributo riderID da identidade, vazio se ela não tiver um
+func (c *TransactionContext) RiderID() string {
+	return c.riderID
+}
+
+// TxTime retorna o carimbo de data/hora da transação em UTC. Ao contrário de time.Now,
+// ele é o mesmo em todos os peers que endossam a transação.
+func (c *TransactionContext) TxTime() time.Time {
+	return c.txTime
+}
+
+// Config retorna a configuração do contrato
+func (c *TransactionContext) Config() *config.Config {
+	return c.cfg
+}
+
+// Pause retorna o estado da pausa de emergência
+func (c *TransactionContext) Pause() PauseState {
+	return c.pause
+}
+
+// SetLedgerConfig valida e grava no estado mundial a configuração fornecida em JSON,
+// no formato de config.LedgerConfig. Ela vale a partir da próxima transação. Os MSPs de
+// operadora precisam incluir o de quem grava, para que a operadora não perca o acesso.
+func SetLedgerConfig(ctx TransactionContextInterface, configJSON string) (*config.LedgerConfig, error) {
+	var ledger config.LedgerConfig
+	err := json.Unmarshal([]byte(configJSON), &ledger)
+	if err != nil {
+		return nil, txerror.Validation("falha ao fazer unmarshal da configuração: %v", err)
+	}
+	if err := ledger.Validate(); err != nil {
+		return nil, txerror.Validation("%v", err)
+	}
+	if !ctx.Config().WithLedger(ledger).IsOperatorMSP(ctx.MSPID()) {
+		return nil, txerror.Validation("os MSPs de operadora %v não incluem o MSP %s de quem grava a configuração", ledger.OperatorMSPs, ctx.MSPID())
+	}
+	if err := putConfig(ctx, LedgerConfigKey, ledger); err != nil {
+		return nil, err
+	}
+
+	return &ledger, nil
+}
+
+// Pause liga a pausa de emergência a partir da próxima transação
+func Pause(ctx TransactionContextInterface, reason string) (*PauseState, error) {
+	if reason == "" {
+		return nil, txerror.Validation("o motivo da pausa é obrigatório")
+	}
+	if ctx.Pause().Paused {
+		return nil, txerror.Validation("o chaincode já está pausado")
+	}
+
+	return setPause(ctx, true, reason)
+}
+
+// Resume desliga a pausa de emergência a partir da próxima transação
+func Resume(ctx TransactionContextInterface) (*PauseState, error) {
+	if !ctx.Pause().Paused {
+		return nil, txerror.Validation("o chaincode não está pausado")
+	}
+
+	return setPause(ctx, false, "")
+}
+
+// setPause grava o estado da pausa com a identidade e o momento da mudança
+func setPause(ctx TransactionContextInterface, paused bool, reason string) (*PauseState, error) {
+	pause := PauseState{
+		Paused:    paused,
+		Reason:    reason,
+		ChangedBy: ctx.CallerID(),
+		ChangedAt: ctx.TxTime().Format(time.RFC3339),
+	}
+	if err := putConfig(ctx, PauseKey, pause); err != nil {
+		return nil, err
+	}
+
+	return &pause, nil
+}
+
+// putConfig grava value no registro de configuração config/<name>
+func putConfig(ctx TransactionContextInterface, name string, value interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(ConfigObjectType, []string{name})
+	if err != nil {
+		return txerror.Wrap(err, "falha ao criar a chave da configuração %s", name)
+	}
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao converter a configuração %s para JSON", name)
+	}
+	err = ctx.GetStub().PutState(key, valueJSON)
+	if err != nil {
+		return txerror.Wrap(err, "falha ao gravar a configuração %s no estado mundial", name)
+	}
+
+	return nil
+}
+
+// Transactions lista as transações de um contrato, para que uma chamada a uma
+// função inexistente devolva os nomes válidos em vez do erro genérico do contractapi.
+//
+// Guarda também quais delas o contrato declara como consultas em GetEvaluateTransactions,
+// que continuam aceitas durante a pausa de emergência.
+type Transactions struct {
+	Contract string
+	Names    []string
+	evaluate map[string]bool
+}
+
+// NewTransactions lê por reflexão as transações públicas de contract, ignorando os
+// métodos herdados de contractapi.Contract.
+func NewTransactions(contract contractapi.ContractInterface) Transactions {
+	ignored := map[string]bool{}
+	for _, ignoredType := range []reflect.Type{
+		reflect.TypeOf(&contractapi.Contract{}),
+		reflect.TypeOf((*contractapi.EvaluationContractInterface)(nil)).Elem(),
+		reflect.TypeOf((*contractapi.IgnoreContractInterface)(nil)).Elem(),
+	} {
+		for i := 0; i < ignoredType.NumMethod(); i++ {
+			ignored[ignoredType.Method(i).Name] = true
+		}
+	}
+
+	transactions := Transactions{Contract: contract.GetName(), evaluate: map[string]bool{}}
+	if evaluation, ok := contract.(contractapi.EvaluationContractInterface); ok {
+		for _, name := range evaluation.GetEvaluateTransactions() {
+			transactions.evaluate[name] = true
+		}
+	}
+	if transactions.Contract == "" {
+		transactions.Contract = reflect.TypeOf(contract).Elem().Name()
+	}
+	methods := reflect.TypeOf(contract)
+	for i := 0; i < methods.NumMethod(); i++ {
+		if name := methods.Method(i).Name; !ignored[name] {
+			transactions.Names = append(transactions.Names, name)
+		}
+	}
+	sort.Strings(transactions.Names)
+
+	return transactions
+}
+
+// Called retorna o nome da transação chamada em ctx, sem o prefixo do contrato
+func (t Transactions) Called(ctx TransactionContextInterface) string {
+	return functionName(ctx)
+}
+
+// Mutating informa se a transação chamada em ctx grava no estado mundial, isto é, se
+// não foi declarada como consulta pelo contrato
+func (t Transactions) Mutating(ctx TransactionContextInterface) bool {
+	return !t.evaluate[functionName(ctx)]
+}
+
+// Unknown é o UnknownTransaction dos contratos: falha com a lista das transações válidas
+func (t Transactions) Unknown(ctx TransactionContextInterface) error {
+	return txerror.NotFound("a transação %s não existe no contrato %s; transações válidas: %s",
+		functionName(ctx), t.Contract, strings.Join(t.Names, ", "))
+}
+
+// functionName retorna o nome da transação chamada, sem o prefixo do contrato e com a
+// primeira letra maiúscula, como o contractapi faz ao procurar a função
+func functionName(ctx TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+	if function == "" {
+		return function
+	}
+	first, size := utf8.DecodeRuneInString(function)
+
+	return string(unicode.ToUpper(first)) + function[size:]
+}
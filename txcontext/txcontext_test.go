@@ -0,0 +1,193 @@
+package txcontext_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"Chaincodemove/config"
+	"Chaincodemove/txcontext"
+	"Chaincodemove/txerror"
+)
+
+// identity é a identidade que assinou a transação, sem certificado
+type identity struct {
+	mspID      string
+	id         string
+	attributes map[string]string
+	err        error
+}
+
+func (i *identity) GetID() (string, error)    { return i.id, i.err }
+func (i *identity) GetMSPID() (string, error) { return i.mspID, i.err }
+func (i *identity) GetAttributeValue(name string) (string, bool, error) {
+	value, ok := i.attributes[name]
+	return value, ok, i.err
+}
+func (i *identity) AssertAttributeValue(name string, value string) error {
+	if i.attributes[name] != value {
+		return errors.New("atributo diferente")
+	}
+	return nil
+}
+func (i *identity) GetX509Certificate() (*x509.Certificate, error) { return nil, i.err }
+
+// stub é o MockStub com a função chamada, que o MockStub só define em MockInvoke
+type stub struct {
+	*shimtest.MockStub
+	function string
+}
+
+func (s *stub) GetFunctionAndParameters() (string, []string) {
+	return s.function, nil
+}
+
+// txTime é o carimbo de data/hora das transações dos testes, fora de UTC
+var txTime = time.Date(2024, 3, 4, 21, 30, 0, 0, time.FixedZone("BRT", -3*3600))
+
+// newContext cria o contexto de uma transação de function sobre um estado mundial vazio
+func newContext(t *testing.T, caller *identity, function string) (*txcontext.TransactionContext, *stub) {
+	t.Helper()
+	s := &stub{MockStub: shimtest.NewMockStub("txcontext", nil), function: function}
+	s.MockTransactionStart("tx1")
+	timestamp, err := ptypes.TimestampProto(txTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.TxTimestamp = timestamp
+
+	ctx := new(txcontext.TransactionContext)
+	ctx.SetStub(s)
+	if caller != nil {
+		ctx.SetClientIdentity(caller)
+	}
+	return ctx, s
+}
+
+// putConfig grava value em config/<name>, como AdminContract
+func putConfig(t *testing.T, s *stub, name string, value interface{}) {
+	t.Helper()
+	key, err := s.CreateCompositeKey(txcontext.ConfigObjectType, []string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutState(key, valueJSON); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	caller := &identity{mspID: "Org1MSP", id: "x509::CN=ana", attributes: map[string]string{"role": "operator", "riderID": "rider1"}}
+	ctx, s := newContext(t, caller, "CreateTripData")
+	ledger := config.Default().Ledger()
+	ledger.MaxTripDistanceKm = 50
+	putConfig(t, s, txcontext.LedgerConfigKey, ledger)
+	putConfig(t, s, txcontext.PauseKey, txcontext.PauseState{Paused: true, Reason: "incidente"})
+
+	cfg := config.Default()
+	if err := ctx.Resolve(cfg); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if ctx.MSPID() != "Org1MSP" || ctx.CallerID() != "Org1MSP/x509::CN=ana" || ctx.Role() != "operator" || ctx.RiderID() != "rider1" {
+		t.Errorf("identidade = %s, %s, %s, %s", ctx.MSPID(), ctx.CallerID(), ctx.Role(), ctx.RiderID())
+	}
+	if !ctx.TxTime().Equal(txTime) || ctx.TxTime().Location() != time.UTC {
+		t.Errorf("TxTime = %v, quer %v em UTC", ctx.TxTime(), txTime)
+	}
+	// A configuração do estado mundial vale por cima da recebida, que não é alterada
+	if ctx.Config().MaxTripDistanceKm != 50 || cfg.MaxTripDistanceKm != 0 || ctx.Config().DatabaseDSN != cfg.DatabaseDSN {
+		t.Errorf("configuração = %+v", ctx.Config())
+	}
+	if pause := ctx.Pause(); !pause.Paused || pause.Reason != "incidente" {
+		t.Errorf("pausa = %+v", pause)
+	}
+
+	// Os valores ficam guardados: o que a transação grava depois não muda o contexto
+	putConfig(t, s, txcontext.PauseKey, txcontext.PauseState{})
+	if !ctx.Pause().Paused {
+		t.Error("a pausa resolvida mudou depois de Resolve")
+	}
+}
+
+func TestResolveWithoutLedgerConfig(t *testing.T) {
+	ctx, _ := newContext(t, &identity{mspID: "Org2MSP", id: "x509::CN=bia"}, "ReadTripData")
+	cfg := config.Default()
+	if err := ctx.Resolve(cfg); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if ctx.Config() != cfg || ctx.Pause().Paused || ctx.Role() != "" || ctx.RiderID() != "" {
+		t.Errorf("contexto = %+v, %+v, %q, %q", ctx.Config(), ctx.Pause(), ctx.Role(), ctx.RiderID())
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	ctx, _ := newContext(t, nil, "ReadTripData")
+	if err := ctx.Resolve(config.Default()); txerror.CodeOf(err) != txerror.CodeUnauthorized {
+		t.Errorf("Resolve sem identidade: %v, quer %s", err, txerror.CodeUnauthorized)
+	}
+
+	ctx, _ = newContext(t, &identity{err: errors.New("certificado inválido")}, "ReadTripData")
+	if err := ctx.Resolve(config.Default()); txerror.CodeOf(err) != txerror.CodeInternal {
+		t.Errorf("Resolve com identidade ilegível: %v, quer %s", err, txerror.CodeInternal)
+	}
+
+	ctx, s := newContext(t, &identity{mspID: "Org1MSP", id: "x509::CN=ana"}, "ReadTripData")
+	key, _ := s.CreateCompositeKey(txcontext.ConfigObjectType, []string{txcontext.PauseKey})
+	if err := s.PutState(key, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Resolve(config.Default()); txerror.CodeOf(err) != txerror.CodeInternal {
+		t.Errorf("Resolve com pausa ilegível: %v, quer %s", err, txerror.CodeInternal)
+	}
+}
+
+// tripContract tem uma consulta e uma transação que grava
+type tripContract struct {
+	contractapi.Contract
+}
+
+func (tc *tripContract) ReadTrip(ctx txcontext.TransactionContextInterface) error  { return nil }
+func (tc *tripContract) WriteTrip(ctx txcontext.TransactionContextInterface) error { return nil }
+
+func (tc *tripContract) GetEvaluateTransactions() []string {
+	return []string{"ReadTrip"}
+}
+
+func TestTransactions(t *testing.T) {
+	transactions := txcontext.NewTransactions(new(tripContract))
+	if transactions.Contract != "tripContract" || strings.Join(transactions.Names, ",") != "ReadTrip,WriteTrip" {
+		t.Errorf("transações = %s: %v", transactions.Contract, transactions.Names)
+	}
+
+	// O prefixo do contrato é ignorado e a primeira letra vai para maiúscula, como no contractapi
+	for function, mutating := range map[string]bool{
+		"ReadTrip":               false,
+		"tripContract:ReadTrip":  false,
+		"readTrip":               false,
+		"WriteTrip":              true,
+		"tripContract:writeTrip": true,
+		"Unknown":                true,
+	} {
+		ctx, _ := newContext(t, nil, function)
+		if got := transactions.Mutating(ctx); got != mutating {
+			t.Errorf("Mutating(%s) = %v, quer %v", function, got, mutating)
+		}
+	}
+
+	ctx, _ := newContext(t, nil, "tripContract:deleteTrip")
+	err := transactions.Unknown(ctx)
+	if txerror.CodeOf(err) != txerror.CodeNotFound || !strings.Contains(err.Error(), "DeleteTrip") || !strings.Contains(err.Error(), "ReadTrip, WriteTrip") {
+		t.Errorf("Unknown: %v", err)
+	}
+}
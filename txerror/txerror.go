@@ -0,0 +1,138 @@
+// Package txerror define os erros devolvidos pelas transações do contrato.
+//
+// O Fabric só entrega ao cliente a mensagem do erro, então o código vai no começo da
+// mensagem, entre colchetes:
+//
+//	[NOT_FOUND] os dados de viagem asset9 não existem
+//
+// Os códigos são estáveis; o texto depois deles pode mudar. Os clientes usam CodeOf
+// para decidir o que fazer sem depender do texto em português.
+package txerror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Code é o código de um erro de transação
+type Code string
+
+// Códigos de erro
+const (
+	// CodeNotFound indica que o registro pedido não existe
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeAlreadyExists indica que o registro já existe ou conflita com outro em andamento
+	CodeAlreadyExists Code = "ALREADY_EXISTS"
+	// CodeValidation indica argumentos inválidos ou uma operação fora de hora
+	CodeValidation Code = "VALIDATION"
+	// CodeUnauthorized indica que a identidade não pode executar a transação
+	CodeUnauthorized Code = "UNAUTHORIZED"
+	// CodeInternal indica falha do estado mundial, do banco ou de serialização
+	CodeInternal Code = "INTERNAL"
+	// CodeUnavailable indica que o chaincode está pausado e só aceita consultas
+	CodeUnavailable Code = "UNAVAILABLE"
+)
+
+var codes = []Code{CodeNotFound, CodeAlreadyExists, CodeValidation, CodeUnauthorized, CodeInternal, CodeUnavailable}
+
+// Error é um erro de transação com código
+type Error struct {
+	Code    Code
+	Message string
+	cause   error
+}
+
+// Error retorna a mensagem no formato "[CÓDIGO] mensagem"
+func (e *Error) Error() string {
+	return "[" + string(e.Code) + "] " + e.Message
+}
+
+// Unwrap retorna o erro original, quando o erro foi criado por Wrap
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// New cria um erro com o código e a mensagem formatada
+func New(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// NotFound cria um erro CodeNotFound
+func NotFound(format string, args ...interface{}) error {
+	return New(CodeNotFound, format, args...)
+}
+
+// AlreadyExists cria um erro CodeAlreadyExists
+func AlreadyExists(format string, args ...interface{}) error {
+	return New(CodeAlreadyExists, format, args...)
+}
+
+// Validation cria um erro CodeValidation
+func Validation(format string, args ...interface{}) error {
+	return New(CodeValidation, format, args...)
+}
+
+// Unauthorized cria um erro CodeUnauthorized
+func Unauthorized(format string, args ...interface{}) error {
+	return New(CodeUnauthorized, format, args...)
+}
+
+// Internal cria um erro CodeInternal
+func Internal(format string, args ...interface{}) error {
+	return New(CodeInternal, format, args...)
+}
+
+// Unavailable cria um erro CodeUnavailable
+func Unavailable(format string, args ...interface{}) error {
+	return New(CodeUnavailable, format, args...)
+}
+
+// Wrap acrescenta contexto a err, no formato "contexto: mensagem de err". O código de
+// err é mantido; erros sem código (do stub, do banco, do JSON) viram CodeInternal.
+func Wrap(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	code := CodeInternal
+	message := err.Error()
+	var txErr *Error
+	if errors.As(err, &txErr) {
+		code = txErr.Code
+		message = txErr.Message
+	}
+
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...) + ": " + message, cause: err}
+}
+
+// CodeOf retorna o código do erro. Erros que vieram do Fabric só como texto (pelo
+// Gateway, por exemplo) têm o código lido da mensagem. Retorna "" se não houver código.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var txErr *Error
+	if errors.As(err, &txErr) {
+		return txErr.Code
+	}
+	code, _ := Parse(err.Error())
+	return code
+}
+
+// Parse procura o primeiro código conhecido entre colchetes na mensagem e retorna o
+// código e o texto que vem depois dele.
+func Parse(message string) (Code, string) {
+	best := -1
+	var found Code
+	for _, code := range codes {
+		if i := strings.Index(message, "["+string(code)+"] "); i >= 0 && (best < 0 || i < best) {
+			best = i
+			found = code
+		}
+	}
+	if best < 0 {
+		return "", message
+	}
+
+	return found, message[best+len(found)+3:]
+}
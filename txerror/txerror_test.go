@@ -0,0 +1,97 @@
+package txerror_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"Chaincodemove/txerror"
+)
+
+func TestError(t *testing.T) {
+	err := txerror.NotFound("os dados de viagem %s não existem", "t9")
+	if got := err.Error(); got != "[NOT_FOUND] os dados de viagem t9 não existem" {
+		t.Errorf("mensagem = %q", got)
+	}
+	if code := txerror.CodeOf(err); code != txerror.CodeNotFound {
+		t.Errorf("código = %q, quer %s", code, txerror.CodeNotFound)
+	}
+	if code := txerror.CodeOf(nil); code != "" {
+		t.Errorf("código de nil = %q, quer vazio", code)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if txerror.Wrap(nil, "contexto") != nil {
+		t.Error("Wrap(nil) não é nil")
+	}
+
+	// O código de um erro de transação é mantido, e o contexto vai antes da mensagem
+	cause := txerror.Validation("distância negativa")
+	err := txerror.Wrap(cause, "falha ao gravar %s", "t1")
+	if got := err.Error(); got != "[VALIDATION] falha ao gravar t1: distância negativa" {
+		t.Errorf("mensagem = %q", got)
+	}
+	if code := txerror.CodeOf(err); code != txerror.CodeValidation {
+		t.Errorf("código = %q, quer %s", code, txerror.CodeValidation)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Wrap não mantém o erro original")
+	}
+
+	// Envolvido de novo, o código continua um só, no começo
+	twice := txerror.Wrap(err, "falha na transação")
+	if got := twice.Error(); got != "[VALIDATION] falha na transação: falha ao gravar t1: distância negativa" {
+		t.Errorf("mensagem = %q", got)
+	}
+
+	// Erros sem código viram CodeInternal
+	plain := errors.New("conexão recusada")
+	internal := txerror.Wrap(plain, "falha ao ler do estado mundial")
+	if got := internal.Error(); got != "[INTERNAL] falha ao ler do estado mundial: conexão recusada" {
+		t.Errorf("mensagem = %q", got)
+	}
+	if !errors.Is(internal, plain) {
+		t.Error("Wrap não mantém o erro original")
+	}
+
+	// Um erro de transação envolvido com fmt.Errorf continua com o seu código
+	wrapped := fmt.Errorf("gateway: %w", txerror.Unauthorized("só a operadora"))
+	if code := txerror.CodeOf(wrapped); code != txerror.CodeUnauthorized {
+		t.Errorf("código = %q, quer %s", code, txerror.CodeUnauthorized)
+	}
+}
+
+func TestCodeOfMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		code    txerror.Code
+	}{
+		// O Gateway entrega só o texto, com o seu próprio prefixo
+		{"rpc error: code = Aborted desc = failed to endorse transaction: chaincode response 500, [NOT_FOUND] a reserva r9 não existe", txerror.CodeNotFound},
+		{"evaluate call to endorser returned error: [UNAVAILABLE] o chaincode está pausado", txerror.CodeUnavailable},
+		// O primeiro código da mensagem prevalece, mesmo que outro apareça no texto
+		{"[ALREADY_EXISTS] a contestação d1 já existe: [INTERNAL] sem importância", txerror.CodeAlreadyExists},
+		{"falha: [UNKNOWN] código desconhecido", ""},
+		{"[NOT_FOUND]sem espaço", ""},
+		{"timeout", ""},
+	}
+	for _, test := range tests {
+		if code := txerror.CodeOf(errors.New(test.message)); code != test.code {
+			t.Errorf("CodeOf(%q) = %q, quer %q", test.message, code, test.code)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	code, text := txerror.Parse("chaincode response 500, [VALIDATION] período inválido")
+	if code != txerror.CodeValidation || text != "período inválido" {
+		t.Errorf("Parse = %q, %q", code, text)
+	}
+
+	// Mensagens sem código voltam inteiras
+	code, text = txerror.Parse("context deadline exceeded")
+	if code != "" || text != "context deadline exceeded" {
+		t.Errorf("Parse de mensagem sem código = %q, %q", code, text)
+	}
+}